@@ -7,6 +7,7 @@ package at
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -27,10 +28,14 @@ import (
 //
 // Once closed the AT cannot be re-opened - it must be recreated.
 type AT struct {
-	// channel for commands issued to the modem
+	// channels for commands issued to the modem, indexed by priority
 	//
 	// Handled by the cmdLoop.
-	cmdCh chan func()
+	cmdChs [numPriorities]chan func()
+
+	// the number of commands issued before lower priority commands are given
+	// precedence
+	fairness int
 
 	// channel for changes to inds
 	//
@@ -61,6 +66,18 @@ type AT struct {
 	// time to wait for individual commands to complete
 	cmdTimeout time.Duration
 
+	// default timeouts for particular commands, overriding cmdTimeout
+	cmdTimeouts map[string]time.Duration
+
+	// default policy for retrying failed commands
+	retry RetryPolicy
+
+	// interceptors applied to Command and SMSCommand, outermost first
+	interceptors []Interceptor
+
+	// the head of the interceptor chain
+	invoker Invoker
+
 	// indications mapped by prefix
 	//
 	// Only accessed from the indLoop
@@ -85,6 +102,14 @@ type CommandOption interface {
 	applyCommandOption(*commandConfig)
 }
 
+// NullOption is a CommandOption that has no effect on the command.
+//
+// It may be embedded in options defined by drivers built on the AT, such as
+// gsm, so those options may be passed alongside the CommandOptions.
+type NullOption struct{}
+
+func (NullOption) applyCommandOption(*commandConfig) {}
+
 // InitOption defines a behaviouralk option for Init.
 type InitOption interface {
 	applyInitOption(*initConfig)
@@ -93,15 +118,19 @@ type InitOption interface {
 // New creates a new AT modem.
 func New(modem io.ReadWriter, options ...Option) *AT {
 	a := &AT{
-		modem:      modem,
-		cmdCh:      make(chan func()),
-		indCh:      make(chan func()),
-		iLines:     make(chan string),
-		cLines:     make(chan string),
-		closed:     make(chan struct{}),
-		escTime:    20 * time.Millisecond,
-		cmdTimeout: time.Second,
-		inds:       make(map[string]Indication),
+		modem:       modem,
+		fairness:    8,
+		indCh:       make(chan func()),
+		iLines:      make(chan string),
+		cLines:      make(chan string),
+		closed:      make(chan struct{}),
+		escTime:     20 * time.Millisecond,
+		cmdTimeout:  time.Second,
+		cmdTimeouts: DefaultCmdTimeouts,
+		inds:        make(map[string]Indication),
+	}
+	for i := range a.cmdChs {
+		a.cmdChs[i] = make(chan func())
 	}
 	for _, option := range options {
 		option.applyOption(a)
@@ -112,9 +141,10 @@ func New(modem io.ReadWriter, options ...Option) *AT {
 			"E0", // disable echo
 		}
 	}
+	a.invoker = chain(a.interceptors, a.invoke)
 	go lineReader(a.modem, a.iLines)
 	go a.indLoop(a.indCh, a.iLines, a.cLines)
-	go cmdLoop(a.cmdCh, a.cLines, a.closed)
+	go cmdLoop(a.cmdChs, a.fairness, a.cLines, a.closed)
 	return a
 }
 
@@ -192,6 +222,88 @@ func (o TimeoutOption) applyCommandOption(c *commandConfig) {
 	c.timeout = time.Duration(o)
 }
 
+// WithCmdTimeouts specifies the default timeouts for particular commands.
+//
+// The keys may be a complete command, such as "+COPS=?", a command
+// identifier, such as "+CMGS", or a prefix terminated with a '*', such as
+// "D*". A complete command match takes precedence over a command identifier
+// match, which takes precedence over the longest matching prefix.
+//
+// Commands that match none of the keys use the timeout set by WithTimeout,
+// while a timeout passed to Command or SMSCommand via WithTimeout overrides
+// any default.
+//
+// The timeouts replace the DefaultCmdTimeouts, which are applied unless
+// overridden by this option.
+// WithCmdTimeouts(nil) disables the command timeouts, so all commands use the
+// timeout set by WithTimeout.
+func WithCmdTimeouts(timeouts map[string]time.Duration) CmdTimeoutsOption {
+	return CmdTimeoutsOption(timeouts)
+}
+
+// CmdTimeoutsOption specifies the default timeouts for particular commands.
+type CmdTimeoutsOption map[string]time.Duration
+
+func (o CmdTimeoutsOption) applyOption(a *AT) {
+	a.cmdTimeouts = make(map[string]time.Duration, len(o))
+	for k, v := range o {
+		a.cmdTimeouts[k] = v
+	}
+}
+
+// DefaultCmdTimeouts contains default timeouts for commands known to take
+// longer than the default command timeout.
+//
+// These are applied by default, unless overridden by WithCmdTimeouts.
+//
+// The values are derived from the maximum response times specified in 3GPP
+// TS 27.007 and 27.005, or commonly documented by modem vendors where the
+// specifications are silent.
+var DefaultCmdTimeouts = map[string]time.Duration{
+	"A":      60 * time.Second,  // answer
+	"D*":     60 * time.Second,  // dial
+	"H":      20 * time.Second,  // hangup
+	"+CHUP":  20 * time.Second,  // hangup
+	"+CFUN":  15 * time.Second,  // set phone functionality
+	"+CLCK":  15 * time.Second,  // facility lock
+	"+CPWD":  15 * time.Second,  // change password
+	"+CPIN":  5 * time.Second,   // enter PIN
+	"+COPS":  180 * time.Second, // operator selection
+	"+CGATT": 75 * time.Second,  // PS attach
+	"+CGACT": 150 * time.Second, // PDP context activate
+	"+CUSD":  60 * time.Second,  // USSD
+	"+CMGS":  60 * time.Second,  // send message
+	"+CMSS":  60 * time.Second,  // send message from storage
+	"+CMGC":  60 * time.Second,  // send command
+	"+CMGW":  5 * time.Second,   // write message to storage
+	"+CMGR":  5 * time.Second,   // read message
+	"+CMGL":  20 * time.Second,  // list messages
+	"+CMGD":  25 * time.Second,  // delete messages
+	"+CPMS":  5 * time.Second,   // preferred message storage
+	"+CSCA":  5 * time.Second,   // service centre address
+	"+CPBR":  15 * time.Second,  // read phonebook
+	"+CPBW":  5 * time.Second,   // write phonebook
+	"+CPBS":  5 * time.Second,   // select phonebook
+}
+
+// WithContext provides a context for a command.
+//
+// If the context is cancelled before the command completes then the command
+// is abandoned and the context error returned. Any pending retries of the
+// command are also abandoned.
+func WithContext(ctx context.Context) ContextOption {
+	return ContextOption{ctx}
+}
+
+// ContextOption provides a context for a command.
+type ContextOption struct {
+	ctx context.Context
+}
+
+func (o ContextOption) applyCommandOption(c *commandConfig) {
+	c.ctx = o.ctx
+}
+
 // AddIndication adds a handler for a set of lines beginning with the prefixed
 // line and the following trailing lines.
 func (a *AT) AddIndication(prefix string, handler InfoHandler, options ...IndicationOption) (err error) {
@@ -245,19 +357,21 @@ func (a *AT) Closed() <-chan struct{} {
 // the command and the status line), or an error if the command did not
 // complete successfully.
 func (a *AT) Command(cmd string, options ...CommandOption) ([]string, error) {
-	cfg := commandConfig{timeout: a.cmdTimeout}
-	for _, option := range options {
-		option.applyCommandOption(&cfg)
-	}
+	return a.invoker(Request{Cmd: cmd, Options: options})
+}
+
+func (a *AT) command(cmd string, cfg commandConfig) ([]string, error) {
 	done := make(chan response)
 	cmdf := func() {
-		info, err := a.processReq(cmd, cfg.timeout)
+		info, err := a.processReq(cmd, cfg)
 		done <- response{info: info, err: err}
 	}
 	select {
 	case <-a.closed:
 		return nil, ErrClosed
-	case a.cmdCh <- cmdf:
+	case <-cfg.ctx.Done():
+		return nil, cfg.ctx.Err()
+	case a.cmdChs[cfg.priority] <- cmdf:
 		rsp := <-done
 		return rsp.info, rsp.err
 	}
@@ -270,6 +384,8 @@ func (a *AT) Command(cmd string, options ...CommandOption) ([]string, error) {
 //
 // The escape sequence is "\x1b\r\n".  Additional characters may be added to
 // the sequence using the b parameter.
+//
+// The escape is issued with PriorityHigh.
 func (a *AT) Escape(b ...byte) {
 	done := make(chan struct{})
 	cmdf := func() {
@@ -278,7 +394,7 @@ func (a *AT) Escape(b ...byte) {
 	}
 	select {
 	case <-a.closed:
-	case a.cmdCh <- cmdf:
+	case a.cmdChs[PriorityHigh] <- cmdf:
 		<-done
 	}
 }
@@ -291,15 +407,23 @@ func (a *AT) Escape(b ...byte) {
 // also be used subsequently to return the modem to a known state.
 //
 // The default init commands can be overridden by the options parameter.
+//
+// If the WithSync option is provided then the modem is synchronised, using
+// Sync, before the init commands are issued.
 func (a *AT) Init(options ...InitOption) error {
-	// escape any outstanding SMS operations then CR to flush the command
-	// buffer
-	a.Escape([]byte("\r\n")...)
-
 	cfg := initConfig{cmds: a.initCmds}
 	for _, option := range options {
 		option.applyInitOption(&cfg)
 	}
+	if cfg.syncOpts != nil {
+		if _, err := a.Sync(cfg.syncOpts...); err != nil {
+			return err
+		}
+	} else {
+		// escape any outstanding SMS operations then CR to flush the command
+		// buffer
+		a.Escape([]byte("\r\n")...)
+	}
 	for _, cmd := range cfg.cmds {
 		_, err := a.Command(cmd, cfg.cmdOpts...)
 		switch err {
@@ -330,41 +454,103 @@ func (a *AT) Init(options ...InitOption) error {
 // The format of the sms may be a text message or a hex coded SMS PDU,
 // depending on the configuration of the modem (text or PDU mode).
 func (a *AT) SMSCommand(cmd string, sms string, options ...CommandOption) (info []string, err error) {
-	cfg := commandConfig{timeout: a.cmdTimeout}
-	for _, option := range options {
-		option.applyCommandOption(&cfg)
+	return a.invoker(Request{Cmd: cmd, SMS: sms, IsSMS: true, Options: options})
+}
+
+// invoke performs the request on the modem.
+//
+// This is the end of the interceptor chain.
+func (a *AT) invoke(req Request) ([]string, error) {
+	cfg := a.commandConfig(req.Cmd, req.Options)
+	if req.IsSMS {
+		return a.withRetries(cfg, func() ([]string, error) {
+			return a.smsCommand(req.Cmd, req.SMS, cfg)
+		})
 	}
+	return a.withRetries(cfg, func() ([]string, error) {
+		return a.command(req.Cmd, cfg)
+	})
+}
+
+func (a *AT) smsCommand(cmd string, sms string, cfg commandConfig) ([]string, error) {
 	done := make(chan response)
 	cmdf := func() {
-		info, err := a.processSmsReq(cmd, sms, cfg.timeout)
+		info, err := a.processSmsReq(cmd, sms, cfg)
+		if err != nil && err != ErrDeadlineExceeded && cfg.retry.retryable(err) {
+			// ensure the modem is not left awaiting the remainder of the
+			// PDU before the command is retried.
+			a.escape()
+		}
 		done <- response{info: info, err: err}
 	}
 	select {
 	case <-a.closed:
 		return nil, ErrClosed
-	case a.cmdCh <- cmdf:
+	case <-cfg.ctx.Done():
+		return nil, cfg.ctx.Err()
+	case a.cmdChs[cfg.priority] <- cmdf:
 		rsp := <-done
 		return rsp.info, rsp.err
 	}
 }
 
+// commandConfig returns the configuration for a command, being the defaults
+// for the command modified by the options.
+func (a *AT) commandConfig(cmd string, options []CommandOption) commandConfig {
+	cfg := commandConfig{
+		timeout:  a.timeout(cmd),
+		retry:    a.retry,
+		ctx:      context.Background(),
+		priority: PriorityNormal,
+	}
+	for _, option := range options {
+		option.applyCommandOption(&cfg)
+	}
+	return cfg
+}
+
 // cmdLoop is responsible for the interface to the modem.
 //
 // It serialises the issuing of commands and awaits the responses.
 // If no command is pending then any lines received are discarded.
 //
+// Pending commands are issued in priority order, except that after every
+// fairness commands lower priority commands are given precedence.
+//
 // The cmdLoop terminates when the downstream closes.
-func cmdLoop(cmds chan func(), in <-chan string, out chan struct{}) {
+func cmdLoop(cmds [numPriorities]chan func(), fairness int, in <-chan string, out chan struct{}) {
+	issued := 0
+	turn := 0
 	for {
+		order := priorityOrder
+		fair := fairness > 0 && issued >= fairness
+		if fair {
+			order = fairOrder[turn%len(fairOrder)]
+			issued = 0
+			turn++
+		}
+		if cmd := pendingCmd(cmds, order); cmd != nil {
+			cmd()
+			if !fair {
+				issued++
+			}
+			continue
+		}
 		select {
-		case cmd := <-cmds:
+		case cmd := <-cmds[PriorityHigh]:
+			cmd()
+		case cmd := <-cmds[PriorityNormal]:
+			cmd()
+		case cmd := <-cmds[PriorityLow]:
 			cmd()
 		case _, ok := <-in:
 			if !ok {
 				close(out)
 				return
 			}
+			continue
 		}
+		issued++
 	}
 }
 
@@ -417,6 +603,32 @@ func (a *AT) indLoop(cmds chan func(), in <-chan string, out chan string) {
 	}
 }
 
+// timeout returns the default timeout for the command.
+func (a *AT) timeout(cmd string) time.Duration {
+	if len(a.cmdTimeouts) == 0 {
+		return a.cmdTimeout
+	}
+	if d, ok := a.cmdTimeouts[cmd]; ok {
+		return d
+	}
+	if d, ok := a.cmdTimeouts[parseCmdID(cmd)]; ok {
+		return d
+	}
+	d := a.cmdTimeout
+	plen := -1
+	for k, v := range a.cmdTimeouts {
+		if !strings.HasSuffix(k, "*") {
+			continue
+		}
+		prefix := k[:len(k)-1]
+		if len(prefix) > plen && strings.HasPrefix(cmd, prefix) {
+			d = v
+			plen = len(prefix)
+		}
+	}
+	return d
+}
+
 // issue an escape command
 //
 // This should only be called from within the cmdLoop.
@@ -427,7 +639,7 @@ func (a *AT) escape(b ...byte) {
 }
 
 // perform a request  - issuing the command and awaiting the response.
-func (a *AT) processReq(cmd string, timeout time.Duration) (info []string, err error) {
+func (a *AT) processReq(cmd string, cfg commandConfig) (info []string, err error) {
 	a.waitEscGuard()
 	err = a.writeCommand(cmd)
 	if err != nil {
@@ -436,8 +648,8 @@ func (a *AT) processReq(cmd string, timeout time.Duration) (info []string, err e
 
 	cmdID := parseCmdID(cmd)
 	var expChan <-chan time.Time
-	if timeout >= 0 {
-		expiry := time.NewTimer(timeout)
+	if cfg.timeout >= 0 {
+		expiry := time.NewTimer(cfg.timeout)
 		expChan = expiry.C
 		defer expiry.Stop()
 	}
@@ -446,6 +658,9 @@ func (a *AT) processReq(cmd string, timeout time.Duration) (info []string, err e
 		case <-expChan:
 			err = ErrDeadlineExceeded
 			return
+		case <-cfg.ctx.Done():
+			err = cfg.ctx.Err()
+			return
 		case line, ok := <-a.cLines:
 			if !ok {
 				return nil, ErrClosed
@@ -471,7 +686,7 @@ func (a *AT) processReq(cmd string, timeout time.Duration) (info []string, err e
 
 // perform a SMS request  - issuing the command, awaiting the prompt, sending
 // the data and awaiting the response.
-func (a *AT) processSmsReq(cmd string, sms string, timeout time.Duration) (info []string, err error) {
+func (a *AT) processSmsReq(cmd string, sms string, cfg commandConfig) (info []string, err error) {
 	a.waitEscGuard()
 	err = a.writeSMSCommand(cmd)
 	if err != nil {
@@ -479,8 +694,8 @@ func (a *AT) processSmsReq(cmd string, sms string, timeout time.Duration) (info
 	}
 	cmdID := parseCmdID(cmd)
 	var expChan <-chan time.Time
-	if timeout >= 0 {
-		expiry := time.NewTimer(timeout)
+	if cfg.timeout >= 0 {
+		expiry := time.NewTimer(cfg.timeout)
 		expChan = expiry.C
 		defer expiry.Stop()
 	}
@@ -491,6 +706,11 @@ func (a *AT) processSmsReq(cmd string, sms string, timeout time.Duration) (info
 			a.escape()
 			err = ErrDeadlineExceeded
 			return
+		case <-cfg.ctx.Done():
+			// cancel outstanding SMS request
+			a.escape()
+			err = cfg.ctx.Err()
+			return
 		case line, ok := <-a.cLines:
 			if !ok {
 				err = ErrClosed
@@ -658,6 +878,9 @@ var (
 	// ErrIndicationExists indicates there is already a indication registered
 	// for a prefix.
 	ErrIndicationExists = errors.New("indication exists")
+
+	// ErrNotSynced indicates the modem could not be synchronised.
+	ErrNotSynced = errors.New("not synchronised")
 )
 
 // newError parses a line and creates an error corresponding to the content.
@@ -810,10 +1033,14 @@ func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
 }
 
 type commandConfig struct {
-	timeout time.Duration
+	timeout  time.Duration
+	retry    RetryPolicy
+	ctx      context.Context
+	priority Priority
 }
 
 type initConfig struct {
-	cmds    []string
-	cmdOpts []CommandOption
+	cmds     []string
+	cmdOpts  []CommandOption
+	syncOpts []SyncOption
 }
@@ -219,6 +219,84 @@ func TestCloseInInitTimeout(t *testing.T) {
 	assert.Equal(t, at.ErrDeadlineExceeded, err)
 }
 
+func TestWithCmdTimeouts(t *testing.T) {
+	// The modem responds after a delay that only the selected timeout, which
+	// is short, expires before.  Any other selection, including the default
+	// timeout, is long, so the command would succeed.
+	short := 10 * time.Millisecond
+	long := 10 * time.Second
+	cmdSet := map[string][]string{
+		"ATSLOW\r\n":   {"\r\nOK\r\n"},
+		"ATSLOW=1\r\n": {"\r\nOK\r\n"},
+		"ATSLUG\r\n":   {"\r\nOK\r\n"},
+		"ATSNAIL\r\n":  {"\r\nOK\r\n"},
+		"ATFAST\r\n":   {"\r\nOK\r\n"},
+	}
+	patterns := []struct {
+		name     string
+		timeouts map[string]time.Duration
+		options  []at.CommandOption
+		cmd      string
+		err      error
+	}{
+		{
+			"id",
+			map[string]time.Duration{"SLOW": short, "S*": long, "SL*": long},
+			nil,
+			"SLOW",
+			at.ErrDeadlineExceeded,
+		},
+		{
+			"cmd",
+			map[string]time.Duration{"SLOW=1": short, "SLOW": long, "S*": long, "SL*": long},
+			nil,
+			"SLOW=1",
+			at.ErrDeadlineExceeded,
+		},
+		{
+			"prefix",
+			map[string]time.Duration{"S*": short, "SL*": long, "SLOW": long},
+			nil,
+			"SNAIL",
+			at.ErrDeadlineExceeded,
+		},
+		{
+			"longest prefix",
+			map[string]time.Duration{"SL*": short, "S*": long},
+			nil,
+			"SLUG",
+			at.ErrDeadlineExceeded,
+		},
+		{
+			"override",
+			map[string]time.Duration{"SLOW": long},
+			[]at.CommandOption{at.WithTimeout(short)},
+			"SLOW",
+			at.ErrDeadlineExceeded,
+		},
+		{
+			"unmatched",
+			map[string]time.Duration{"S*": short},
+			nil,
+			"FAST",
+			nil,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			mm := &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10), readDelay: 200 * time.Millisecond}
+			defer teardownModem(mm)
+			m := at.New(mm, at.WithTimeout(long), at.WithCmdTimeouts(p.timeouts))
+			info, err := m.Command(p.cmd, p.options...)
+			assert.Equal(t, p.err, err)
+			if p.err != nil {
+				assert.Nil(t, info)
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
 func TestCommand(t *testing.T) {
 	cmdSet := map[string][]string{
 		"AT\r\n":       {"OK\r\n"},
@@ -428,6 +506,17 @@ func TestCommandClosedPreWrite(t *testing.T) {
 	assert.Nil(t, info)
 }
 
+func TestNullOption(t *testing.T) {
+	cmdSet := map[string][]string{
+		"ATINFO=1\r\n": {"info1\r\n", "OK\r\n"},
+	}
+	m, mm := setupModem(t, cmdSet)
+	defer teardownModem(mm)
+	info, err := m.Command("INFO=1", at.NullOption{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"info1"}, info)
+}
+
 func TestSMSCommand(t *testing.T) {
 	cmdSet := map[string][]string{
 		"ATCMS\r":    {"\r\n+CMS ERROR: 204\r\n"},
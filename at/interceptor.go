@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at
+
+// Request describes a command issued to the modem via Command or SMSCommand.
+type Request struct {
+	// Cmd is the command, excluding the AT prefix.
+	Cmd string
+
+	// SMS is the text or PDU sent after the prompt of an SMS command.
+	//
+	// Only applies to SMS commands.
+	SMS string
+
+	// IsSMS indicates the request was issued by SMSCommand.
+	IsSMS bool
+
+	// Options are the options applied to the command.
+	Options []CommandOption
+}
+
+// Invoker performs a request and returns the result.
+type Invoker func(Request) ([]string, error)
+
+// Interceptor intercepts the commands issued via Command and SMSCommand.
+//
+// The interceptor is passed the request and the next Invoker in the chain.
+// It may inspect or modify the request before passing it to next, and may
+// inspect or modify the result returned by next.  It may also short circuit
+// the request by returning without calling next, in which case the command
+// is not issued to the modem.
+type Interceptor interface {
+	Intercept(req Request, next Invoker) ([]string, error)
+}
+
+// InterceptorFunc adapts a function to the Interceptor interface.
+type InterceptorFunc func(req Request, next Invoker) ([]string, error)
+
+// Intercept calls the function.
+func (f InterceptorFunc) Intercept(req Request, next Invoker) ([]string, error) {
+	return f(req, next)
+}
+
+// WithInterceptors adds interceptors to the commands issued via Command and
+// SMSCommand.
+//
+// The interceptors are composed in order, so the first is the outermost and
+// sees the request first and the result last.  Interceptors from multiple
+// WithInterceptors options are appended.
+//
+// Interceptors wrap the complete command, including any retries.
+func WithInterceptors(interceptors ...Interceptor) InterceptorsOption {
+	return InterceptorsOption(interceptors)
+}
+
+// InterceptorsOption specifies interceptors for the commands issued via
+// Command and SMSCommand.
+type InterceptorsOption []Interceptor
+
+func (o InterceptorsOption) applyOption(a *AT) {
+	a.interceptors = append(a.interceptors, o...)
+}
+
+// chain composes the interceptors around the invoker.
+func chain(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ic := interceptors[i]
+		next := invoker
+		invoker = func(req Request) ([]string, error) {
+			return ic.Intercept(req, next)
+		}
+	}
+	return invoker
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+)
+
+func TestWithInterceptors(t *testing.T) {
+	cmdSet := map[string][]string{
+		"ATPASS\r\n":   {"OK\r\n"},
+		"ATINFO=1\r\n": {"info1\r\n", "OK\r\n"},
+		"ATSLOW\r\n":   {""},
+		"ATSMS\r":      {"\n>"},
+		"sms+" + sub:   {"\r\n", "info4\r\n", "\r\n", "OK\r\n"},
+	}
+	var log []string
+	audit := func(name string) at.Interceptor {
+		return at.InterceptorFunc(func(req at.Request, next at.Invoker) ([]string, error) {
+			log = append(log, name+">"+req.Cmd)
+			info, err := next(req)
+			log = append(log, name+"<"+req.Cmd)
+			return info, err
+		})
+	}
+	errForbidden := errors.New("forbidden")
+	allow := at.InterceptorFunc(func(req at.Request, next at.Invoker) ([]string, error) {
+		if req.Cmd == "&F" {
+			return nil, errForbidden
+		}
+		return next(req)
+	})
+	rewrite := at.InterceptorFunc(func(req at.Request, next at.Invoker) ([]string, error) {
+		switch req.Cmd {
+		case "INFO":
+			req.Cmd = "INFO=1"
+		case "QUICK":
+			req.Cmd = "SLOW"
+			req.Options = append(req.Options, at.WithTimeout(10*time.Millisecond))
+		}
+		info, err := next(req)
+		for i, l := range info {
+			info[i] = strings.ToUpper(l)
+		}
+		return info, err
+	})
+	var smsReq at.Request
+	sms := at.InterceptorFunc(func(req at.Request, next at.Invoker) ([]string, error) {
+		if req.IsSMS {
+			smsReq = req
+		}
+		return next(req)
+	})
+	m, mm := setupModem(t, cmdSet,
+		at.WithInterceptors(audit("a"), allow),
+		at.WithInterceptors(rewrite, sms, audit("b")))
+	defer teardownModem(mm)
+
+	// pass through
+	info, err := m.Command("PASS")
+	assert.Nil(t, err)
+	assert.Nil(t, info)
+	assert.Equal(t, []string{"a>PASS", "b>PASS", "b<PASS", "a<PASS"}, log)
+
+	// short circuit
+	log = nil
+	info, err = m.Command("&F")
+	assert.Equal(t, errForbidden, err)
+	assert.Nil(t, info)
+	assert.Equal(t, []string{"a>&F", "a<&F"}, log)
+
+	// rewrite command and result
+	log = nil
+	info, err = m.Command("INFO")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"INFO1"}, info)
+	assert.Equal(t, []string{"a>INFO", "b>INFO=1", "b<INFO=1", "a<INFO"}, log)
+
+	// modify options
+	start := time.Now()
+	_, err = m.Command("QUICK")
+	assert.Equal(t, at.ErrDeadlineExceeded, err)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+
+	// SMS
+	log = nil
+	info, err = m.SMSCommand("SMS", "sms+")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"INFO4"}, info)
+	assert.Equal(t, []string{"a>SMS", "b>SMS", "b<SMS", "a<SMS"}, log)
+	assert.True(t, smsReq.IsSMS)
+	assert.Equal(t, "SMS", smsReq.Cmd)
+	assert.Equal(t, "sms+", smsReq.SMS)
+}
+
+func TestInterceptorDryRun(t *testing.T) {
+	var cmds []string
+	dryRun := at.InterceptorFunc(func(req at.Request, next at.Invoker) ([]string, error) {
+		cmds = append(cmds, req.Cmd)
+		return nil, nil
+	})
+	m, mm := setupModem(t, nil, at.WithInterceptors(dryRun))
+	defer teardownModem(mm)
+	mm.errOnWrite = true
+
+	err := m.Init()
+	require.Nil(t, err)
+	_, err = m.Command("+CFUN=0")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"Z", "E0", "+CFUN=0"}, cmds)
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at
+
+// Priority determines the order in which pending commands are issued to the
+// modem.
+type Priority int
+
+const (
+	// PriorityLow is intended for bulk work that can be delayed by other
+	// commands.
+	PriorityLow Priority = iota
+
+	// PriorityNormal is the default priority.
+	PriorityNormal
+
+	// PriorityHigh is intended for urgent commands, such as acknowledgements,
+	// hangups and escapes, that must be issued ahead of other work.
+	PriorityHigh
+
+	numPriorities
+)
+
+// WithPriority specifies the priority of a command.
+//
+// Pending commands are issued in priority order, highest first, and in no
+// particular order within a priority.  To ensure lower priority commands
+// still make progress, they periodically take precedence over higher
+// priority commands, as determined by WithFairness.
+//
+// The default is PriorityNormal.
+func WithPriority(p Priority) PriorityOption {
+	return PriorityOption(p)
+}
+
+// PriorityOption specifies the priority of a command.
+type PriorityOption Priority
+
+func (o PriorityOption) applyCommandOption(c *commandConfig) {
+	p := Priority(o)
+	switch {
+	case p < PriorityLow:
+		p = PriorityLow
+	case p > PriorityHigh:
+		p = PriorityHigh
+	}
+	c.priority = p
+}
+
+// WithFairness specifies how many commands may be issued before pending lower
+// priority commands are given precedence.
+//
+// Each time precedence is granted it rotates through the lower priorities, so
+// every priority makes progress while the modem is saturated with higher
+// priority commands.
+//
+// A value of zero provides strict priority ordering, and may starve lower
+// priority commands.
+//
+// The default is 8.
+func WithFairness(n int) FairnessOption {
+	return FairnessOption(n)
+}
+
+// FairnessOption specifies how many commands may be issued before pending
+// lower priority commands are given precedence.
+type FairnessOption int
+
+func (o FairnessOption) applyOption(a *AT) {
+	a.fairness = int(o)
+}
+
+// priorityOrder is the normal order in which priorities are serviced.
+var priorityOrder = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// fairOrder contains the orders in which priorities are serviced when lower
+// priorities are given precedence.
+var fairOrder = [][]Priority{
+	{PriorityNormal, PriorityLow, PriorityHigh},
+	{PriorityLow, PriorityNormal, PriorityHigh},
+}
+
+// pendingCmd returns the first pending command from the channels, in the
+// order provided, or nil if no command is pending.
+func pendingCmd(cmds [numPriorities]chan func(), order []Priority) func() {
+	for _, p := range order {
+		select {
+		case cmd := <-cmds[p]:
+			return cmd
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/warthog618/modem/at"
+)
+
+func TestWithPriority(t *testing.T) {
+	cmdSet := map[string][]string{
+		"ATSLOW\r\n": {""},
+		"ATH\r\n":    {"OK\r\n"},
+		"ATN\r\n":    {"OK\r\n"},
+		"ATL\r\n":    {"OK\r\n"},
+	}
+	patterns := []struct {
+		name     string
+		options  []at.Option
+		high     int
+		normal   int
+		low      int
+		expected []string
+	}{
+		{
+			"priority",
+			nil,
+			1,
+			2,
+			2,
+			[]string{"H", "N", "N", "L", "L"},
+		},
+		{
+			"strict",
+			[]at.Option{at.WithFairness(0)},
+			3,
+			1,
+			1,
+			[]string{"H", "H", "H", "N", "L"},
+		},
+		{
+			"fair",
+			[]at.Option{at.WithFairness(2)},
+			5,
+			1,
+			1,
+			// SLOW counts towards fairness, then normal and low take turns
+			[]string{"H", "N", "H", "H", "L", "H", "H"},
+		},
+		{
+			"fair low",
+			[]at.Option{at.WithFairness(1)},
+			3,
+			0,
+			2,
+			[]string{"L", "H", "L", "H", "H"},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			rm := &flakyModem{
+				mockModem: &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10)},
+			}
+			defer teardownModem(rm.mockModem)
+			a := at.New(rm, p.options...)
+
+			// block the cmdLoop while the other commands are queued
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				a.Command("SLOW", at.WithTimeout(100*time.Millisecond))
+				wg.Done()
+			}()
+			time.Sleep(20 * time.Millisecond)
+			queue := func(cmd string, n int, prio at.Priority) {
+				for i := 0; i < n; i++ {
+					wg.Add(1)
+					go func() {
+						_, err := a.Command(cmd, at.WithPriority(prio))
+						assert.Nil(t, err)
+						wg.Done()
+					}()
+				}
+			}
+			queue("L", p.low, at.PriorityLow)
+			queue("N", p.normal, at.PriorityNormal)
+			queue("H", p.high, at.PriorityHigh)
+			wg.Wait()
+			expected := []string{"ATSLOW\r\n"}
+			for _, cmd := range p.expected {
+				expected = append(expected, "AT"+cmd+"\r\n")
+			}
+			assert.Equal(t, expected, rm.writes)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEscapePriority(t *testing.T) {
+	cmdSet := map[string][]string{
+		"ATSLOW\r\n": {""},
+		"ATL\r\n":    {"OK\r\n"},
+	}
+	rm := &flakyModem{
+		mockModem: &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10)},
+	}
+	defer teardownModem(rm.mockModem)
+	a := at.New(rm)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		a.Command("SLOW", at.WithTimeout(50*time.Millisecond))
+		wg.Done()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		a.Command("L", at.WithPriority(at.PriorityLow))
+		wg.Done()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	a.Escape()
+	wg.Wait()
+	assert.Equal(t, []string{"ATSLOW\r\n", esc + "\r\n", "ATL\r\n"}, rm.writes)
+}
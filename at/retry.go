@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at
+
+import (
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy determines if, and how, failed commands are retried.
+//
+// A command that fails with a retryable error is reissued, up to a total of
+// Attempts times, with an exponential backoff between attempts.
+//
+// SMS commands are escaped before being retried, so the modem is not left
+// awaiting the remainder of a partially sent PDU.
+//
+// The policy may be applied as an Option, to set the default policy for all
+// commands, or as a CommandOption, to override the default for a particular
+// command.  The default is to not retry commands.
+type RetryPolicy struct {
+	// Attempts is the maximum number of times the command is issued.
+	//
+	// Values less than 2 disable retries.
+	Attempts int
+
+	// Backoff is the delay before the first retry.
+	//
+	// The delay is doubled for each subsequent retry.
+	Backoff time.Duration
+
+	// MaxBackoff is the upper limit for the delay between retries.
+	//
+	// Zero means no limit.
+	MaxBackoff time.Duration
+
+	// Jitter is the proportion of the delay that is randomised, in the range
+	// 0 to 1.
+	//
+	// e.g. a Jitter of 0.2 randomises the delay within 20% either side of the
+	// nominal delay.
+	Jitter float64
+
+	// Retryable determines if a command that failed with the error should be
+	// retried.
+	//
+	// If nil, IsTransient is used.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy is a policy suitable for retrying the transient failures
+// identified by IsTransient.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+	Jitter:     0.2,
+}
+
+// WithRetryPolicy specifies the policy for retrying failed commands.
+func WithRetryPolicy(p RetryPolicy) RetryPolicy {
+	return p
+}
+
+func (o RetryPolicy) applyOption(a *AT) {
+	a.retry = o
+}
+
+func (o RetryPolicy) applyCommandOption(c *commandConfig) {
+	c.retry = o
+}
+
+// retryable returns true if the command that returned the error should be
+// retried, assuming it has not exhausted its attempts.
+func (o RetryPolicy) retryable(err error) bool {
+	if o.Attempts < 2 {
+		return false
+	}
+	if o.Retryable != nil {
+		return o.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// backoff returns the delay before the retry following the attempt.
+func (o RetryPolicy) backoff(attempt int) time.Duration {
+	d := o.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if o.MaxBackoff != 0 && d >= o.MaxBackoff {
+			break
+		}
+	}
+	if o.MaxBackoff != 0 && d > o.MaxBackoff {
+		d = o.MaxBackoff
+	}
+	if o.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * o.Jitter * float64(d))
+	}
+	return d
+}
+
+// transientErrors are the CME and CMS errors, in both numeric and textual
+// forms, that are considered transient.
+var transientErrors = map[string]bool{
+	"14":              true, // CME SIM busy
+	"31":              true, // CME network timeout
+	"314":             true, // CMS SIM busy
+	"332":             true, // CMS network timeout
+	"500":             true, // CMS unknown error
+	"sim busy":        true,
+	"network timeout": true,
+	"unknown error":   true,
+}
+
+// IsTransient returns true if the error is likely to be transient, and so the
+// command that returned it may succeed if retried.
+//
+// The transient errors are the CME and CMS errors indicating the SIM is busy
+// or the network has timed out, and the CMS unknown error.
+func IsTransient(err error) bool {
+	var cme CMEError
+	if errors.As(err, &cme) {
+		return transientErrors[strings.ToLower(string(cme))]
+	}
+	var cms CMSError
+	if errors.As(err, &cms) {
+		return transientErrors[strings.ToLower(string(cms))]
+	}
+	return false
+}
+
+// withRetries calls the command function, and retries it as required by the
+// retry policy in the config.
+func (a *AT) withRetries(cfg commandConfig, cmdf func() ([]string, error)) (info []string, err error) {
+	for attempt := 1; ; attempt++ {
+		info, err = cmdf()
+		if err == nil || attempt >= cfg.retry.Attempts || !cfg.retry.retryable(err) {
+			return
+		}
+		backoff := time.NewTimer(cfg.retry.backoff(attempt))
+		select {
+		case <-backoff.C:
+		case <-cfg.ctx.Done():
+			backoff.Stop()
+			return nil, cfg.ctx.Err()
+		case <-a.closed:
+			backoff.Stop()
+			return nil, ErrClosed
+		}
+	}
+}
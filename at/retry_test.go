@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	cmdSet := map[string][]string{
+		"ATFLAKY\r\n": {"OK\r\n"},
+	}
+	fast := at.RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	patterns := []struct {
+		name     string
+		options  []at.Option
+		cOptions []at.CommandOption
+		failRsp  string
+		fails    int
+		writes   int
+		err      error
+	}{
+		{
+			"no policy",
+			nil,
+			nil,
+			"+CME ERROR: 14\r\n",
+			1,
+			1,
+			at.CMEError("14"),
+		},
+		{
+			"transient",
+			[]at.Option{at.WithRetryPolicy(fast)},
+			nil,
+			"+CME ERROR: SIM busy\r\n",
+			2,
+			3,
+			nil,
+		},
+		{
+			"exhausted",
+			[]at.Option{at.WithRetryPolicy(fast)},
+			nil,
+			"+CMS ERROR: 500\r\n",
+			3,
+			3,
+			at.CMSError("500"),
+		},
+		{
+			"permanent",
+			[]at.Option{at.WithRetryPolicy(fast)},
+			nil,
+			"+CMS ERROR: 302\r\n",
+			1,
+			1,
+			at.CMSError("302"),
+		},
+		{
+			"command policy",
+			nil,
+			[]at.CommandOption{at.WithRetryPolicy(fast)},
+			"+CMS ERROR: 332\r\n",
+			1,
+			2,
+			nil,
+		},
+		{
+			"command disable",
+			[]at.Option{at.WithRetryPolicy(fast)},
+			[]at.CommandOption{at.WithRetryPolicy(at.RetryPolicy{})},
+			"+CMS ERROR: 332\r\n",
+			1,
+			1,
+			at.CMSError("332"),
+		},
+		{
+			"retryable",
+			[]at.Option{at.WithRetryPolicy(at.RetryPolicy{
+				Attempts:  2,
+				Retryable: func(err error) bool { return err == at.ErrError },
+			})},
+			nil,
+			"ERROR\r\n",
+			1,
+			2,
+			nil,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			fm := &flakyModem{
+				mockModem: &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10)},
+				cmd:       "ATFLAKY\r\n",
+				failRsp:   p.failRsp,
+				fails:     p.fails,
+			}
+			defer teardownModem(fm.mockModem)
+			a := at.New(fm, p.options...)
+			require.NotNil(t, a)
+			_, err := a.Command("FLAKY", p.cOptions...)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.writes, len(fm.writes))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestRetryPolicySMS(t *testing.T) {
+	cmdSet := map[string][]string{
+		"ATSMS\r":   {"\n>"},
+		"pdu" + sub: {"\r\n", "+CMGS: 42\r\n", "\r\n", "OK\r\n"},
+	}
+	fm := &flakyModem{
+		mockModem: &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10)},
+		cmd:       "pdu" + sub,
+		failRsp:   "\r\n+CMS ERROR: 500\r\n",
+		fails:     1,
+	}
+	defer teardownModem(fm.mockModem)
+	a := at.New(fm, at.WithRetryPolicy(at.RetryPolicy{Attempts: 2}))
+	info, err := a.SMSCommand("SMS", "pdu")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"+CMGS: 42"}, info)
+	// PDU must be escaped before being retried
+	assert.Equal(t, []string{
+		"ATSMS\r",
+		"pdu" + sub,
+		esc + "\r\n",
+		"ATSMS\r",
+		"pdu" + sub,
+	}, fm.writes)
+}
+
+func TestRetryPolicyContext(t *testing.T) {
+	fm := &flakyModem{
+		mockModem: &mockModem{r: make(chan []byte, 10)},
+		cmd:       "ATFLAKY\r\n",
+		failRsp:   "+CME ERROR: 14\r\n",
+		fails:     3,
+	}
+	defer teardownModem(fm.mockModem)
+	a := at.New(fm, at.WithRetryPolicy(at.RetryPolicy{Attempts: 3, Backoff: time.Minute}))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := a.Command("FLAKY", at.WithContext(ctx))
+	assert.Equal(t, context.Canceled, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, 1, len(fm.writes))
+}
+
+func TestWithContext(t *testing.T) {
+	cmdSet := map[string][]string{
+		"ATSLOW\r\n": {""},
+		"ATSMS\r":    {"\n>"},
+		"sms" + sub:  {""},
+	}
+	m, mm := setupModem(t, cmdSet)
+	defer teardownModem(mm)
+	mm.echo = false
+
+	// cancelled before the command is issued
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	info, err := m.Command("SLOW", at.WithContext(ctx))
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, info)
+
+	// cancelled while awaiting the response
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	info, err = m.Command("SLOW", at.WithContext(ctx))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, info)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+
+	// cancelled while awaiting the SMS response
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	info, err = m.SMSCommand("SMS", "sms", at.WithContext(ctx))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, info)
+}
+
+func TestIsTransient(t *testing.T) {
+	patterns := []struct {
+		err       error
+		transient bool
+	}{
+		{at.CMEError("14"), true},
+		{at.CMEError("SIM busy"), true},
+		{at.CMEError("31"), true},
+		{at.CMEError("network timeout"), true},
+		{at.CMEError("10"), false},
+		{at.CMSError("314"), true},
+		{at.CMSError("332"), true},
+		{at.CMSError("500"), true},
+		{at.CMSError("unknown error"), true},
+		{at.CMSError("302"), false},
+		{fmt.Errorf("wrapped: %w", at.CMSError("500")), true},
+		{at.ErrError, false},
+		{at.ErrDeadlineExceeded, false},
+		{errors.New("500"), false},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.transient, at.IsTransient(p.err))
+		}
+		t.Run(p.err.Error(), f)
+	}
+}
+
+// flakyModem fails a command a number of times before passing it to the
+// mockModem.
+type flakyModem struct {
+	*mockModem
+	cmd     string
+	failRsp string
+	fails   int
+	writes  []string
+}
+
+func (m *flakyModem) Write(p []byte) (n int, err error) {
+	m.writes = append(m.writes, string(p))
+	if string(p) == m.cmd && m.fails > 0 {
+		m.fails--
+		m.r <- []byte(m.failRsp)
+		return len(p), nil
+	}
+	return m.mockModem.Write(p)
+}
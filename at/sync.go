@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at
+
+import (
+	"time"
+)
+
+// SyncOption defines a behavioural option for Sync.
+type SyncOption interface {
+	applySyncOption(*syncConfig)
+}
+
+// Sync synchronises with the modem.
+//
+// The modem is escaped, then AT commands are repeatedly issued until two
+// consecutive commands return a clean OK, i.e. an OK with no other lines
+// other than the echoed command.  Stale lines received from the modem are
+// flushed between attempts.
+//
+// If a baud rate setter is provided via WithBaudRates then each of the rates
+// is tried in turn until the modem is synchronised.  The rate detected is
+// returned, or 0 if no setter is provided.
+//
+// Returns ErrNotSynced if the modem could not be synchronised.
+func (a *AT) Sync(options ...SyncOption) (int, error) {
+	cfg := syncConfig{
+		attempts: 10,
+		timeout:  200 * time.Millisecond,
+		quiet:    50 * time.Millisecond,
+	}
+	for _, option := range options {
+		option.applySyncOption(&cfg)
+	}
+	rates := cfg.rates
+	if cfg.setRate == nil || len(rates) == 0 {
+		rates = []int{0}
+	}
+	for _, rate := range rates {
+		if cfg.setRate != nil {
+			if err := cfg.setRate(rate); err != nil {
+				return 0, err
+			}
+		}
+		err := a.sync(cfg)
+		switch err {
+		case nil:
+			return rate, nil
+		case ErrNotSynced:
+		default:
+			return 0, err
+		}
+	}
+	return 0, ErrNotSynced
+}
+
+// sync attempts to synchronise with the modem at the current baud rate.
+func (a *AT) sync(cfg syncConfig) error {
+	a.Escape([]byte("\r\n")...)
+	clean := 0
+	for i := 0; i < cfg.attempts; i++ {
+		info, err := a.Command("", WithTimeout(cfg.timeout), WithRetryPolicy(RetryPolicy{}))
+		if err == ErrClosed {
+			return err
+		}
+		if err == nil && len(info) == 0 {
+			clean++
+			if clean == 2 {
+				return nil
+			}
+			continue
+		}
+		clean = 0
+		a.flush(cfg.quiet, cfg.timeout)
+	}
+	return ErrNotSynced
+}
+
+// flush discards lines received from the modem until none have been received
+// for the quiet period, or the limit has elapsed.
+func (a *AT) flush(quiet, limit time.Duration) {
+	done := make(chan struct{})
+	cmdf := func() {
+		defer close(done)
+		expiry := time.NewTimer(limit)
+		defer expiry.Stop()
+		for {
+			select {
+			case _, ok := <-a.cLines:
+				if !ok {
+					return
+				}
+			case <-time.After(quiet):
+				return
+			case <-expiry.C:
+				return
+			}
+		}
+	}
+	select {
+	case <-a.closed:
+	case a.cmdChs[PriorityHigh] <- cmdf:
+		<-done
+	}
+}
+
+// WithSync specifies that Init synchronises with the modem, using Sync,
+// rather than just escaping the modem, before issuing the init commands.
+//
+// The options are passed to Sync.
+func WithSync(options ...SyncOption) SyncInitOption {
+	if options == nil {
+		options = []SyncOption{}
+	}
+	return SyncInitOption(options)
+}
+
+// SyncInitOption specifies that Init synchronises with the modem.
+type SyncInitOption []SyncOption
+
+func (o SyncInitOption) applyInitOption(i *initConfig) {
+	i.syncOpts = []SyncOption(o)
+}
+
+// WithBaudRates specifies a function to set the baud rate of the underlying
+// serial port, and the rates to try, in order, when synchronising with the
+// modem.
+//
+// e.g. for a go.bug.st/serial port:
+//
+//	setter := func(rate int) error {
+//		return port.SetMode(&serial.Mode{BaudRate: rate})
+//	}
+//	rate, err := a.Sync(at.WithBaudRates(setter, 115200, 57600, 9600))
+func WithBaudRates(setter func(int) error, rates ...int) BaudRatesOption {
+	return BaudRatesOption{setter, rates}
+}
+
+// BaudRatesOption specifies the baud rates to try when synchronising with the
+// modem.
+type BaudRatesOption struct {
+	setter func(int) error
+	rates  []int
+}
+
+func (o BaudRatesOption) applySyncOption(c *syncConfig) {
+	c.setRate = o.setter
+	c.rates = o.rates
+}
+
+// WithSyncAttempts specifies the maximum number of AT commands issued when
+// synchronising with the modem at each baud rate.
+//
+// The default is 10.
+func WithSyncAttempts(n int) SyncAttemptsOption {
+	return SyncAttemptsOption(n)
+}
+
+// SyncAttemptsOption specifies the maximum number of AT commands issued when
+// synchronising with the modem at each baud rate.
+type SyncAttemptsOption int
+
+func (o SyncAttemptsOption) applySyncOption(c *syncConfig) {
+	c.attempts = int(o)
+}
+
+// WithSyncTimeout specifies the time allowed for the modem to respond to each
+// AT command issued while synchronising.
+//
+// This is also the limit on the time spent flushing stale lines after a
+// failed attempt.
+//
+// The default is 200msec.
+func WithSyncTimeout(d time.Duration) SyncTimeoutOption {
+	return SyncTimeoutOption(d)
+}
+
+// SyncTimeoutOption specifies the time allowed for the modem to respond to
+// each AT command issued while synchronising.
+type SyncTimeoutOption time.Duration
+
+func (o SyncTimeoutOption) applySyncOption(c *syncConfig) {
+	c.timeout = time.Duration(o)
+}
+
+type syncConfig struct {
+	attempts int
+	timeout  time.Duration
+	quiet    time.Duration
+	setRate  func(int) error
+	rates    []int
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package at_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+)
+
+func TestSync(t *testing.T) {
+	cmdSet := map[string][]string{
+		esc + "\r\n\r\n": {"\r\n"},
+		"AT\r\n":         {"OK\r\n"},
+	}
+	patterns := []struct {
+		name    string
+		failRsp string
+		fails   int
+		writes  int
+		err     error
+	}{
+		{
+			"clean",
+			"",
+			0,
+			3,
+			nil,
+		},
+		{
+			"echo",
+			"AT\r\nOK\r\n",
+			1,
+			3,
+			nil,
+		},
+		{
+			"stale",
+			"junk\r\nOK\r\n",
+			2,
+			5,
+			nil,
+		},
+		{
+			"unsynced",
+			"junk\r\nOK\r\n",
+			4,
+			5,
+			at.ErrNotSynced,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			fm := &flakyModem{
+				mockModem: &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10)},
+				cmd:       "AT\r\n",
+				failRsp:   p.failRsp,
+				fails:     p.fails,
+			}
+			defer teardownModem(fm.mockModem)
+			a := at.New(fm)
+			rate, err := a.Sync(at.WithSyncAttempts(4), at.WithSyncTimeout(20*time.Millisecond))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, 0, rate)
+			assert.Equal(t, p.writes, len(fm.writes))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestSyncBaudRates(t *testing.T) {
+	cmdSet := map[string][]string{
+		esc + "\r\n\r\n": {"\r\n"},
+		"AT\r\n":         {"OK\r\n"},
+	}
+	errSetter := errors.New("bad rate")
+	patterns := []struct {
+		name  string
+		rates []int
+		rate  int
+		tried []int
+		err   error
+	}{
+		{
+			"first",
+			[]int{9600, 115200},
+			9600,
+			[]int{9600},
+			nil,
+		},
+		{
+			"second",
+			[]int{115200, 9600},
+			9600,
+			[]int{115200, 9600},
+			nil,
+		},
+		{
+			"none",
+			[]int{115200, 57600},
+			0,
+			[]int{115200, 57600},
+			at.ErrNotSynced,
+		},
+		{
+			"setter error",
+			[]int{115200, 1200},
+			0,
+			[]int{115200, 1200},
+			errSetter,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			bm := &baudModem{
+				mockModem: &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10)},
+				modemRate: 9600,
+			}
+			defer teardownModem(bm.mockModem)
+			a := at.New(bm)
+			var tried []int
+			setter := func(rate int) error {
+				tried = append(tried, rate)
+				if rate == 1200 {
+					return errSetter
+				}
+				bm.setRate(rate)
+				return nil
+			}
+			rate, err := a.Sync(
+				at.WithBaudRates(setter, p.rates...),
+				at.WithSyncAttempts(3),
+				at.WithSyncTimeout(10*time.Millisecond))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.rate, rate)
+			assert.Equal(t, p.tried, tried)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestInitWithSync(t *testing.T) {
+	cmdSet := map[string][]string{
+		esc + "\r\n\r\n": {"\r\n"},
+		"AT\r\n":         {"OK\r\n"},
+		"ATZ\r\n":        {"OK\r\n"},
+		"ATE0\r\n":       {"OK\r\n"},
+	}
+	fm := &flakyModem{
+		mockModem: &mockModem{cmdSet: cmdSet, r: make(chan []byte, 10)},
+		cmd:       "AT\r\n",
+		failRsp:   "junk\r\nOK\r\n",
+		fails:     1,
+	}
+	defer teardownModem(fm.mockModem)
+	a := at.New(fm)
+	require.NotNil(t, a)
+	err := a.Init(at.WithSync(at.WithSyncTimeout(20 * time.Millisecond)))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		esc + "\r\n\r\n",
+		"AT\r\n",
+		"AT\r\n",
+		"AT\r\n",
+		"ATZ\r\n",
+		"ATE0\r\n",
+	}, fm.writes)
+
+	// unsynced
+	fm.fails = 10
+	err = a.Init(at.WithSync(at.WithSyncAttempts(2), at.WithSyncTimeout(10*time.Millisecond)))
+	assert.Equal(t, at.ErrNotSynced, err)
+}
+
+// baudModem only responds sensibly when the host and modem baud rates match.
+type baudModem struct {
+	*mockModem
+	modemRate int
+	rate      int
+}
+
+func (m *baudModem) setRate(rate int) {
+	m.rate = rate
+}
+
+func (m *baudModem) Write(p []byte) (n int, err error) {
+	if m.rate != m.modemRate {
+		m.r <- []byte("\xf0\x80\xfe\r\n")
+		return len(p), nil
+	}
+	return m.mockModem.Write(p)
+}
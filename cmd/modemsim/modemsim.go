@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+//go:build linux
+
+// modemsim serves a simulated GSM modem on a pseudo-terminal.
+//
+// The path to the terminal, e.g. /dev/pts/3, is printed to stdout, and may be
+// passed to the other commands, or any other application, as the modem device.
+//
+// The initial state of the modem and the events that occur once it is
+// running, such as incoming SMSs, changes in network registration and injected
+// errors, are described by an optional JSON scenario file, e.g.
+//
+//	{
+//	  "smsc": "+61411000000",
+//	  "phonebook": [{"index": 1, "number": "+61412345678", "name": "Alice"}],
+//	  "events": [
+//	    {"at": "5s", "sms": {"from": "+61412345678", "text": "hello"}},
+//	    {"at": "10s", "registration": 0},
+//	    {"at": "15s", "fail": {"command": "+CMGS", "error": "+CMS ERROR: 500"}}
+//	  ]
+//	}
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/warthog618/modem/sim"
+	"github.com/warthog618/modem/trace"
+)
+
+var version = "undefined"
+
+func main() {
+	scenario := flag.String("s", "", "path to scenario file")
+	link := flag.String("l", "", "path of a symlink to create to the modem device, replacing any existing symlink")
+	latency := flag.Duration("latency", 0, "command response latency, overriding the scenario")
+	verbose := flag.Bool("v", false, "log modem interactions")
+	vsn := flag.Bool("version", false, "report version and exit")
+	flag.Parse()
+	if *vsn {
+		fmt.Printf("%s %s\n", os.Args[0], version)
+		os.Exit(0)
+	}
+	s := &Scenario{}
+	if *scenario != "" {
+		var err error
+		if s, err = loadScenario(*scenario); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	if *latency != 0 {
+		s.Latency = Duration(*latency)
+	}
+	m := sim.New(s.options()...)
+	defer m.Close()
+	if err := s.store(m); err != nil {
+		log.Println(err)
+		return
+	}
+	pty, path, err := openPTY()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer pty.Close()
+	if *link != "" {
+		if err = createLink(path, *link); err != nil {
+			log.Println(err)
+			return
+		}
+		defer os.Remove(*link)
+	}
+	fmt.Println(path)
+
+	var mio io.ReadWriter = m
+	if *verbose {
+		mio = trace.New(m, trace.WithLineMode())
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go serve(pty, mio)
+	go s.run(m, done)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+}
+
+// createLink creates a symlink to the target, replacing any existing symlink
+// at the link path, but not any other type of file.
+func createLink(target, link string) error {
+	fi, err := os.Lstat(link)
+	switch {
+	case err == nil:
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			return fmt.Errorf("%s already exists and is not a symlink", link)
+		}
+		if err = os.Remove(link); err != nil {
+			return err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	return os.Symlink(target, link)
+}
+
+// serve passes data between the pseudo-terminal and the modem until either
+// is closed.
+func serve(pty *os.File, m io.ReadWriter) {
+	go func() {
+		b := make([]byte, 1024)
+		for {
+			n, err := m.Read(b)
+			if err != nil {
+				return
+			}
+			// discarded if there is no client
+			pty.Write(b[:n])
+		}
+	}()
+	b := make([]byte, 1024)
+	for {
+		n, err := pty.Read(b)
+		if n > 0 {
+			m.Write(b[:n])
+		}
+		if errors.Is(err, syscall.EIO) {
+			// no client currently has the terminal open
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return
+		}
+	}
+}
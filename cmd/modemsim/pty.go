@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY creates a pseudo-terminal, returning the master and the path to the
+// slave.
+//
+// The terminal is placed in raw mode, so data is passed through unaltered.
+func openPTY() (*os.File, string, error) {
+	f, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	var n int
+	var cerr error
+	err = rc.Control(func(fd uintptr) {
+		if cerr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); cerr != nil {
+			return
+		}
+		if n, cerr = unix.IoctlGetInt(int(fd), unix.TIOCGPTN); cerr != nil {
+			return
+		}
+		cerr = makeRaw(int(fd))
+	})
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		f.Close()
+		return nil, "", err
+	}
+	return f, fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+// makeRaw disables the line discipline processing of the terminal, as per
+// cfmakeraw.
+func makeRaw(fd int) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP |
+		unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+//go:build linux
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/sim"
+)
+
+// Scenario describes the initial state of the simulated modem, and the events
+// that occur once the simulation starts.
+type Scenario struct {
+	// PIN locks the SIM with the PIN.
+	PIN string `json:"pin,omitempty"`
+
+	// SMSC is the number of the SMSC.
+	SMSC string `json:"smsc,omitempty"`
+
+	// Latency is the delay before the modem responds to a command.
+	Latency Duration `json:"latency,omitempty"`
+
+	// Identity overrides the default identity of the modem.
+	Identity *sim.Identity `json:"identity,omitempty"`
+
+	// Phonebook contains the entries in the SIM phonebook.
+	Phonebook []PhonebookEntry `json:"phonebook,omitempty"`
+
+	// Messages contains the messages in the SIM message storage.
+	Messages []SMS `json:"messages,omitempty"`
+
+	// Events are the events that occur once the simulation starts.
+	Events []Event `json:"events,omitempty"`
+}
+
+// PhonebookEntry is an entry in the SIM phonebook.
+type PhonebookEntry struct {
+	Index  int    `json:"index"`
+	Number string `json:"number"`
+	Name   string `json:"name"`
+}
+
+// SMS is a message received from the network.
+type SMS struct {
+	// From is the number of the sender.
+	From string `json:"from"`
+
+	// Text is the message, which is split into multiple parts if necessary.
+	Text string `json:"text"`
+
+	// Status is the status of a stored message, e.g. "REC READ".
+	//
+	// The default is "REC UNREAD".
+	Status string `json:"status,omitempty"`
+}
+
+// Signal is the signal quality, as per +CSQ.
+type Signal struct {
+	RSSI int `json:"rssi"`
+	BER  int `json:"ber"`
+}
+
+// Failure injects an error into the response to a command.
+type Failure struct {
+	// Command is the name of the command, e.g. "+CMGS".
+	Command string `json:"command"`
+
+	// Error is the error returned, either "ERROR", "+CME ERROR: <n>" or
+	// "+CMS ERROR: <n>".
+	Error string `json:"error"`
+
+	// Count is the number of subsequent executions of the command that
+	// fail.
+	//
+	// The default is 1.
+	Count int `json:"count,omitempty"`
+}
+
+// Event is a change that occurs during the simulation.
+//
+// Only one of the changes should be set in each event.
+type Event struct {
+	// At is the time of the event, relative to the start of the simulation.
+	At Duration `json:"at"`
+
+	// SMS is a message delivered by the network.
+	SMS *SMS `json:"sms,omitempty"`
+
+	// Registration is the network registration status, as per +CREG.
+	Registration *int `json:"registration,omitempty"`
+
+	// Signal is the signal quality.
+	Signal *Signal `json:"signal,omitempty"`
+
+	// URC contains the lines of an unsolicited result code.
+	URC []string `json:"urc,omitempty"`
+
+	// Fail injects an error into the response to a command.
+	Fail *Failure `json:"fail,omitempty"`
+}
+
+// Duration is a time.Duration that is encoded in JSON as a string, e.g. "5s".
+type Duration time.Duration
+
+// UnmarshalJSON decodes the duration from a string, as per time.ParseDuration.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// loadScenario reads the scenario from the named file.
+func loadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readScenario(f)
+}
+
+// readScenario decodes the scenario from the reader.
+func readScenario(r io.Reader) (*Scenario, error) {
+	var s Scenario
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&s); err != nil {
+		return nil, err
+	}
+	for i, e := range s.Events {
+		if e.Fail != nil {
+			if _, ok := parseError(e.Fail.Error); !ok {
+				return nil, fmt.Errorf("event %d: unknown error '%s'", i, e.Fail.Error)
+			}
+		}
+	}
+	return &s, nil
+}
+
+// options returns the options to create a sim.Modem in the initial state.
+func (s *Scenario) options() []sim.Option {
+	opts := []sim.Option{sim.WithLatency(time.Duration(s.Latency))}
+	if s.PIN != "" {
+		opts = append(opts, sim.WithPIN(s.PIN))
+	}
+	if s.SMSC != "" {
+		opts = append(opts, sim.WithSMSC(s.SMSC))
+	}
+	if s.Identity != nil {
+		opts = append(opts, sim.WithIdentity(*s.Identity))
+	}
+	if len(s.Phonebook) > 0 {
+		entries := make([]sim.PhonebookEntry, len(s.Phonebook))
+		for i, e := range s.Phonebook {
+			entries[i] = sim.PhonebookEntry{Index: e.Index, Number: e.Number, Name: e.Name}
+		}
+		opts = append(opts, sim.WithPhonebook("SM", entries...))
+	}
+	return opts
+}
+
+// store adds the scenario messages to the SIM message storage.
+func (s *Scenario) store(m *sim.Modem) error {
+	for _, msg := range s.Messages {
+		status := sim.StatusRecUnread
+		if msg.Status != "" {
+			var err error
+			if status, err = parseStatus(msg.Status); err != nil {
+				return err
+			}
+		}
+		tpdus, err := deliverTPDUs(msg)
+		if err != nil {
+			return err
+		}
+		for _, tp := range tpdus {
+			if _, err = m.StoreMessage("SM", status, tp); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// run applies the scenario events to the modem at the scheduled times,
+// until all events are applied or done is closed.
+func (s *Scenario) run(m *sim.Modem, done <-chan struct{}) {
+	events := append([]Event{}, s.Events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	start := time.Now()
+	for _, e := range events {
+		select {
+		case <-time.After(time.Until(start.Add(time.Duration(e.At)))):
+		case <-done:
+			return
+		}
+		if err := e.apply(m); err != nil {
+			log.Printf("event at %s: %v", time.Duration(e.At), err)
+		}
+	}
+}
+
+// apply applies the event to the modem.
+func (e *Event) apply(m *sim.Modem) error {
+	if e.SMS != nil {
+		tpdus, err := deliverTPDUs(*e.SMS)
+		if err != nil {
+			return err
+		}
+		for _, tp := range tpdus {
+			if err = m.Deliver(tp); err != nil {
+				return err
+			}
+		}
+	}
+	if e.Registration != nil {
+		m.SetRegistration(*e.Registration)
+	}
+	if e.Signal != nil {
+		m.SetSignal(e.Signal.RSSI, e.Signal.BER)
+	}
+	if len(e.URC) > 0 {
+		m.Inject(e.URC...)
+	}
+	if e.Fail != nil {
+		err, _ := parseError(e.Fail.Error)
+		count := e.Fail.Count
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			m.FailNext(strings.ToUpper(e.Fail.Command), err)
+		}
+	}
+	return nil
+}
+
+// deliverTPDUs returns the binary SMS-DELIVER TPDUs containing the message.
+func deliverTPDUs(msg SMS) ([][]byte, error) {
+	pdus, err := sms.Encode([]byte(msg.Text), sms.AsDeliver, sms.From(msg.From))
+	if err != nil {
+		return nil, err
+	}
+	scts := tpdu.Timestamp{Time: time.Now()}
+	tpdus := make([][]byte, len(pdus))
+	for i, p := range pdus {
+		p.SCTS = scts
+		if tpdus[i], err = p.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+	return tpdus, nil
+}
+
+// parseError returns the error corresponding to a final result code, and
+// false if the result code is not recognised.
+func parseError(s string) (error, bool) {
+	switch {
+	case s == "ERROR":
+		return sim.ErrError, true
+	case strings.HasPrefix(s, "+CME ERROR:"):
+		return at.CMEError(strings.TrimSpace(strings.TrimPrefix(s, "+CME ERROR:"))), true
+	case strings.HasPrefix(s, "+CMS ERROR:"):
+		return at.CMSError(strings.TrimSpace(strings.TrimPrefix(s, "+CMS ERROR:"))), true
+	}
+	return nil, false
+}
+
+// parseStatus returns the status corresponding to its text mode name.
+func parseStatus(s string) (sim.Status, error) {
+	for _, st := range []sim.Status{sim.StatusRecUnread, sim.StatusRecRead, sim.StatusStoUnsent, sim.StatusStoSent} {
+		if strings.EqualFold(s, st.String()) {
+			return st, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status '%s'", s)
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestReadScenario(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  string
+	}{
+		{
+			"empty",
+			`{}`,
+			"",
+		},
+		{
+			"full",
+			`{
+			  "pin": "1234",
+			  "smsc": "+61411000000",
+			  "latency": "10ms",
+			  "phonebook": [{"index": 1, "number": "+61412345678", "name": "Alice"}],
+			  "messages": [{"from": "+61412345678", "text": "hi", "status": "REC READ"}],
+			  "events": [
+			    {"at": "5s", "sms": {"from": "+61412345678", "text": "hello"}},
+			    {"at": "10s", "registration": 0},
+			    {"at": "15s", "fail": {"command": "+CMGS", "error": "+CMS ERROR: 500"}}
+			  ]
+			}`,
+			"",
+		},
+		{
+			"unknown field",
+			`{"smsc": "+61411000000", "junk": 1}`,
+			"unknown field",
+		},
+		{
+			"bad duration",
+			`{"events": [{"at": "5 seconds"}]}`,
+			"time: unknown unit",
+		},
+		{
+			"unknown error",
+			`{"events": [{"at": "1s", "fail": {"command": "+CMGS", "error": "BUSY"}}]}`,
+			"event 0: unknown error 'BUSY'",
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			s, err := readScenario(strings.NewReader(p.in))
+			if p.err != "" {
+				require.NotNil(t, err)
+				assert.Contains(t, err.Error(), p.err)
+				return
+			}
+			require.Nil(t, err)
+			require.NotNil(t, s)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestReadScenarioFields(t *testing.T) {
+	s, err := readScenario(strings.NewReader(`{
+	  "smsc": "+61411000000",
+	  "latency": "10ms",
+	  "events": [
+	    {"at": "1m30s", "registration": 5},
+	    {"at": "2s", "signal": {"rssi": 10, "ber": 2}},
+	    {"at": "3s", "urc": ["RING"]}
+	  ]
+	}`))
+	require.Nil(t, err)
+	assert.Equal(t, "+61411000000", s.SMSC)
+	assert.Equal(t, Duration(10*time.Millisecond), s.Latency)
+	require.Equal(t, 3, len(s.Events))
+	assert.Equal(t, Duration(90*time.Second), s.Events[0].At)
+	require.NotNil(t, s.Events[0].Registration)
+	assert.Equal(t, 5, *s.Events[0].Registration)
+	assert.Equal(t, &Signal{RSSI: 10, BER: 2}, s.Events[1].Signal)
+	assert.Equal(t, []string{"RING"}, s.Events[2].URC)
+}
+
+func TestParseError(t *testing.T) {
+	patterns := []struct {
+		in  string
+		err error
+		ok  bool
+	}{
+		{"ERROR", sim.ErrError, true},
+		{"+CME ERROR: 10", at.CMEError("10"), true},
+		{"+CMS ERROR:500", at.CMSError("500"), true},
+		{"BUSY", nil, false},
+	}
+	for _, p := range patterns {
+		err, ok := parseError(p.in)
+		assert.Equal(t, p.ok, ok, p.in)
+		assert.Equal(t, p.err, err, p.in)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	st, err := parseStatus("rec read")
+	assert.Nil(t, err)
+	assert.Equal(t, sim.StatusRecRead, st)
+	_, err = parseStatus("unread")
+	assert.NotNil(t, err)
+}
+
+func TestScenarioStore(t *testing.T) {
+	s := &Scenario{Messages: []SMS{
+		{From: "+61412345678", Text: "hello"},
+		{From: "+61412345678", Text: strings.Repeat("0123456789", 20), Status: "REC READ"},
+	}}
+	m := sim.New(s.options()...)
+	defer m.Close()
+	require.Nil(t, s.store(m))
+	msgs := m.Messages("SM")
+	require.Equal(t, 3, len(msgs))
+	assert.Equal(t, sim.StatusRecUnread, msgs[0].Status)
+	assert.Equal(t, sim.StatusRecRead, msgs[1].Status)
+	assert.Equal(t, sim.StatusRecRead, msgs[2].Status)
+
+	s = &Scenario{Messages: []SMS{{From: "+61412345678", Text: "hello", Status: "junk"}}}
+	assert.NotNil(t, s.store(m))
+}
+
+func TestEventApply(t *testing.T) {
+	m := sim.New()
+	defer m.Close()
+	a := at.New(m, at.WithTimeout(100*time.Millisecond))
+	require.Nil(t, a.Init())
+	_, err := a.Command("+CMEE=1")
+	require.Nil(t, err)
+
+	e := Event{Signal: &Signal{RSSI: 10, BER: 2}}
+	require.Nil(t, e.apply(m))
+	i, err := a.Command("+CSQ")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"+CSQ: 10,2"}, i)
+
+	e = Event{Fail: &Failure{Command: "+csq", Error: "+CME ERROR: 10", Count: 2}}
+	require.Nil(t, e.apply(m))
+	for n := 0; n < 2; n++ {
+		_, err = a.Command("+CSQ")
+		assert.Equal(t, at.CMEError("10"), err)
+	}
+	_, err = a.Command("+CSQ")
+	assert.Nil(t, err)
+
+	e = Event{SMS: &SMS{From: "+61412345678", Text: "hello"}}
+	require.Nil(t, e.apply(m))
+	assert.Equal(t, 1, len(m.Messages("SM")))
+}
+
+func TestCreateLink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "modem")
+
+	// new
+	require.Nil(t, createLink("/dev/pts/1", link))
+	target, err := os.Readlink(link)
+	require.Nil(t, err)
+	assert.Equal(t, "/dev/pts/1", target)
+
+	// replaces existing symlink
+	require.Nil(t, createLink("/dev/pts/2", link))
+	target, err = os.Readlink(link)
+	require.Nil(t, err)
+	assert.Equal(t, "/dev/pts/2", target)
+
+	// but not a regular file
+	file := filepath.Join(dir, "file")
+	require.Nil(t, os.WriteFile(file, []byte("keep"), 0o644))
+	assert.NotNil(t, createLink("/dev/pts/3", file))
+	data, err := os.ReadFile(file)
+	require.Nil(t, err)
+	assert.Equal(t, "keep", string(data))
+}
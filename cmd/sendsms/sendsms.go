@@ -35,6 +35,7 @@ func main() {
 	verbose := flag.Bool("v", false, "log modem interactions")
 	pdumode := flag.Bool("p", false, "send in PDU mode")
 	hex := flag.Bool("x", false, "hex dump modem responses")
+	redact := flag.Bool("r", false, "log modem interactions, with sensitive data redacted")
 	vsn := flag.Bool("version", false, "report version and exit")
 	flag.Parse()
 	if *vsn {
@@ -46,7 +47,9 @@ func main() {
 		log.Fatal(err)
 	}
 	var mio io.ReadWriter = m
-	if *hex {
+	if *redact {
+		mio = trace.New(m, trace.WithRedaction())
+	} else if *hex {
 		mio = trace.New(m, trace.WithReadFormat("r: %v"))
 	} else if *verbose {
 		mio = trace.New(m)
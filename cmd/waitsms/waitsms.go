@@ -36,6 +36,7 @@ func main() {
 	timeout := flag.Duration("t", 400*time.Millisecond, "command timeout period")
 	verbose := flag.Bool("v", false, "log modem interactions")
 	hex := flag.Bool("x", false, "hex dump modem responses")
+	redact := flag.Bool("r", false, "log modem interactions, with sensitive data redacted")
 	vsn := flag.Bool("version", false, "report version and exit")
 	flag.Parse()
 	if *vsn {
@@ -49,7 +50,9 @@ func main() {
 	}
 	defer m.Close()
 	var mio io.ReadWriter = m
-	if *hex {
+	if *redact {
+		mio = trace.New(m, trace.WithRedaction())
+	} else if *hex {
 		mio = trace.New(m, trace.WithReadFormat("r: %v"))
 	} else if *verbose {
 		mio = trace.New(m)
@@ -0,0 +1,478 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+// Package fault provides a decorator for io.ReadWriter that injects faults
+// into the data passing through it.
+//
+// It is intended for testing the behaviour of drivers, such as the at
+// package, when the link to the modem is unreliable.
+package fault
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault injects faults into the reads and writes of an io.ReadWriter.
+//
+// Each fault is applied when its trigger fires. Byte faults - drops,
+// duplicates and corruptions - are triggered for each byte, while the
+// remaining faults are triggered for each read or write.
+type Fault struct {
+	rw    io.ReadWriter
+	rules []rule
+
+	// mu protects the fields following.
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	cmd     string
+	pending []byte
+	stalled [2]bool
+	eof     bool
+	counts  map[Kind]int
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Kind identifies the kind of a fault.
+type Kind int
+
+const (
+	// Drop discards a byte.
+	Drop Kind = iota
+
+	// Duplicate repeats a byte.
+	Duplicate
+
+	// Corrupt flips a bit in a byte.
+	Corrupt
+
+	// Split splits a read or write into two, so a line may be split across
+	// reads.
+	Split
+
+	// Delay delays a read or write by a random period.
+	Delay
+
+	// Stall blocks the read or write, and all subsequent reads or writes,
+	// until the Fault is closed.
+	Stall
+
+	// EOF ends the stream, as if the modem had been disconnected.
+	EOF
+)
+
+var kindText = []string{"drop", "duplicate", "corrupt", "split", "delay", "stall", "eof"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindText) {
+		return "unknown"
+	}
+	return kindText[k]
+}
+
+// Dir is the direction of the data passing through the Fault.
+type Dir int
+
+const (
+	// Read indicates data read from the underlying io.ReadWriter.
+	Read Dir = iota
+
+	// Write indicates data written to the underlying io.ReadWriter.
+	Write
+)
+
+// Context describes the data being considered by a Trigger.
+type Context struct {
+	// Dir is the direction of the data.
+	Dir Dir
+
+	// Cmd is the most recent command line written, excluding the "AT" prefix
+	// and line terminator, e.g. "+CMGS=23".
+	Cmd string
+
+	// Rand is a source of random numbers.
+	//
+	// The source is seeded by WithSeed, so may be used to generate
+	// reproducible faults.
+	Rand *rand.Rand
+}
+
+// Trigger determines if a fault is to be applied.
+type Trigger func(c Context) bool
+
+// Always is a Trigger that always fires.
+func Always(c Context) bool {
+	return true
+}
+
+// Reads is a Trigger that fires for data read from the underlying
+// io.ReadWriter.
+func Reads(c Context) bool {
+	return c.Dir == Read
+}
+
+// Writes is a Trigger that fires for data written to the underlying
+// io.ReadWriter.
+func Writes(c Context) bool {
+	return c.Dir == Write
+}
+
+// Probability returns a Trigger that fires randomly, with the probability p.
+func Probability(p float64) Trigger {
+	return func(c Context) bool {
+		return c.Rand.Float64() < p
+	}
+}
+
+// OnCommand returns a Trigger that fires while the most recent command written
+// starts with the prefix, e.g. "+CMGS".
+//
+// The match is case insensitive, and the "AT" prefix is optional.
+func OnCommand(prefix string) Trigger {
+	prefix = trimAT(prefix)
+	return func(c Context) bool {
+		return len(c.Cmd) >= len(prefix) && strings.EqualFold(c.Cmd[:len(prefix)], prefix)
+	}
+}
+
+// All returns a Trigger that fires only if all the triggers fire.
+//
+// The triggers are evaluated in order, and evaluation stops with the first
+// trigger that does not fire.
+func All(triggers ...Trigger) Trigger {
+	return func(c Context) bool {
+		for _, t := range triggers {
+			if !t(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Once returns a Trigger that fires only the first time the trigger fires.
+func Once(t Trigger) Trigger {
+	var mu sync.Mutex
+	fired := false
+	return func(c Context) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if fired || !t(c) {
+			return false
+		}
+		fired = true
+		return true
+	}
+}
+
+// rule applies a kind of fault when triggered.
+type rule struct {
+	kind    Kind
+	trigger Trigger
+	delay   time.Duration
+}
+
+// Option modifies a Fault object created by New.
+type Option func(*Fault)
+
+// New creates a new Fault on the io.ReadWriter.
+//
+// With no options the Fault passes data through unaltered.
+func New(rw io.ReadWriter, options ...Option) *Fault {
+	f := &Fault{
+		rw:     rw,
+		rnd:    rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		counts: make(map[Kind]int),
+		done:   make(chan struct{}),
+	}
+	for _, option := range options {
+		option(f)
+	}
+	return f
+}
+
+// WithSeed seeds the random number generator, so random faults are
+// reproducible.
+func WithSeed(seed int64) Option {
+	return func(f *Fault) {
+		f.rnd = rand.New(rand.NewPCG(uint64(seed), 0))
+	}
+}
+
+// WithDrop drops bytes when triggered.
+func WithDrop(t Trigger) Option {
+	return withRule(rule{kind: Drop, trigger: t})
+}
+
+// WithDuplicate duplicates bytes when triggered.
+func WithDuplicate(t Trigger) Option {
+	return withRule(rule{kind: Duplicate, trigger: t})
+}
+
+// WithCorrupt corrupts bytes when triggered, by flipping one of the bits in
+// the byte.
+func WithCorrupt(t Trigger) Option {
+	return withRule(rule{kind: Corrupt, trigger: t})
+}
+
+// WithSplit splits reads and writes into two when triggered.
+//
+// The split point is chosen randomly.  For reads the remainder is returned by
+// the subsequent read.
+func WithSplit(t Trigger) Option {
+	return withRule(rule{kind: Split, trigger: t})
+}
+
+// WithDelay delays reads and writes by a random period, up to max, when
+// triggered.
+//
+// Reads are delayed before the data is read from the underlying
+// io.ReadWriter.
+func WithDelay(max time.Duration, t Trigger) Option {
+	return withRule(rule{kind: Delay, trigger: t, delay: max})
+}
+
+// WithStall stalls reads or writes when triggered.
+//
+// A stalled read or write blocks until the Fault is closed, as do all
+// subsequent reads or writes in the same direction.
+// The trigger for reads is evaluated once data has been read from the
+// underlying io.ReadWriter, and that data is discarded.
+func WithStall(t Trigger) Option {
+	return withRule(rule{kind: Stall, trigger: t})
+}
+
+// WithEOF ends the stream when triggered.
+//
+// The triggering read, and all subsequent reads, return io.EOF, and
+// subsequent writes return ErrClosed.
+// The data read by the triggering read is discarded.
+//
+// The trigger is only evaluated for reads.
+func WithEOF(t Trigger) Option {
+	return withRule(rule{kind: EOF, trigger: t})
+}
+
+func withRule(r rule) Option {
+	return func(f *Fault) {
+		f.rules = append(f.rules, r)
+	}
+}
+
+// ErrClosed indicates the Fault has been closed or has reached EOF.
+var ErrClosed = errors.New("closed")
+
+// Count returns the number of faults of the kind that have been injected.
+func (f *Fault) Count(k Kind) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[k]
+}
+
+// Close closes the Fault, unblocking any stalled reads or writes, and closes
+// the underlying io.ReadWriter if it is an io.Closer.
+func (f *Fault) Close() error {
+	f.closeOnce.Do(func() {
+		close(f.done)
+	})
+	if c, ok := f.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (f *Fault) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		f.mu.Lock()
+		if f.eof {
+			f.mu.Unlock()
+			return 0, io.EOF
+		}
+		if len(f.pending) > 0 {
+			n := copy(p, f.pending)
+			f.pending = f.pending[n:]
+			f.mu.Unlock()
+			return n, nil
+		}
+		stalled := f.stalled[Read]
+		delay := f.delay(Read)
+		f.mu.Unlock()
+		if stalled {
+			<-f.done
+			return 0, io.EOF
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		n, err := f.rw.Read(p)
+		f.mu.Lock()
+		// triggered after the read, as reads typically block awaiting data
+		// from the modem.
+		if f.stall(Read) {
+			f.mu.Unlock()
+			<-f.done
+			return 0, io.EOF
+		}
+		if f.fires(EOF, Read) {
+			f.eof = true
+			f.mu.Unlock()
+			return 0, io.EOF
+		}
+		data := f.mangle(Read, p[:n])
+		if len(data) > 1 && f.fires(Split, Read) {
+			i := 1 + f.rnd.IntN(len(data)-1)
+			f.pending = append(f.pending, data[i:]...)
+			data = data[:i]
+		}
+		n = copy(p, data)
+		f.pending = append(f.pending, data[n:]...)
+		f.mu.Unlock()
+		if n > 0 || err != nil {
+			return n, err
+		}
+		// all data dropped, so read again
+	}
+}
+
+func (f *Fault) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	if f.eof {
+		f.mu.Unlock()
+		return 0, ErrClosed
+	}
+	if len(p) > 2 && strings.EqualFold(string(p[:2]), "AT") {
+		cmd := p[2:]
+		if i := bytes.IndexAny(cmd, "\r\n"); i >= 0 {
+			cmd = cmd[:i]
+		}
+		f.cmd = string(cmd)
+	}
+	stall := f.stall(Write)
+	delay := f.delay(Write)
+	f.mu.Unlock()
+	if stall {
+		<-f.done
+		return 0, ErrClosed
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	f.mu.Lock()
+	data := f.mangle(Write, p)
+	var tail []byte
+	if len(data) > 1 && f.fires(Split, Write) {
+		i := 1 + f.rnd.IntN(len(data)-1)
+		data, tail = data[:i], data[i:]
+	}
+	f.mu.Unlock()
+	written := 0
+	for _, d := range [][]byte{data, tail} {
+		if len(d) == 0 {
+			continue
+		}
+		n, err := f.rw.Write(d)
+		written += n
+		if err == nil && n < len(d) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return min(written, len(p)), err
+		}
+	}
+	return len(p), nil
+}
+
+// context returns the Context for data in the direction.
+//
+// Must be called with the lock held.
+func (f *Fault) context(dir Dir) Context {
+	return Context{Dir: dir, Cmd: f.cmd, Rand: f.rnd}
+}
+
+// fires returns true if a rule for the kind of fault is triggered, and
+// records the fault.
+//
+// Must be called with the lock held.
+func (f *Fault) fires(k Kind, dir Dir) bool {
+	for _, r := range f.rules {
+		if r.kind == k && r.trigger(f.context(dir)) {
+			f.counts[k]++
+			return true
+		}
+	}
+	return false
+}
+
+// stall returns true if data in the direction is stalled.
+//
+// Must be called with the lock held.
+func (f *Fault) stall(dir Dir) bool {
+	if !f.stalled[dir] && f.fires(Stall, dir) {
+		f.stalled[dir] = true
+	}
+	return f.stalled[dir]
+}
+
+// delay returns the random delay to be applied, if any.
+//
+// Must be called with the lock held.
+func (f *Fault) delay(dir Dir) time.Duration {
+	for _, r := range f.rules {
+		if r.kind == Delay && r.delay > 0 && r.trigger(f.context(dir)) {
+			f.counts[Delay]++
+			return time.Duration(f.rnd.Int64N(int64(r.delay)))
+		}
+	}
+	return 0
+}
+
+// mangle applies the byte faults to the data, returning the altered data.
+//
+// Must be called with the lock held.
+func (f *Fault) mangle(dir Dir, data []byte) []byte {
+	if !f.hasByteRules() {
+		return append([]byte{}, data...)
+	}
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		if f.fires(Drop, dir) {
+			continue
+		}
+		if f.fires(Corrupt, dir) {
+			b ^= 1 << uint(f.rnd.IntN(8))
+		}
+		out = append(out, b)
+		if f.fires(Duplicate, dir) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+func (f *Fault) hasByteRules() bool {
+	for _, r := range f.rules {
+		switch r.kind {
+		case Drop, Duplicate, Corrupt:
+			return true
+		}
+	}
+	return false
+}
+
+func trimAT(cmd string) string {
+	if len(cmd) >= 2 && strings.EqualFold(cmd[:2], "AT") {
+		return cmd[2:]
+	}
+	return cmd
+}
@@ -0,0 +1,377 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package fault_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/fault"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestNew(t *testing.T) {
+	rw := &mockRW{rx: bytes.NewBufferString("hello\r\n")}
+	f := fault.New(rw)
+	require.NotNil(t, f)
+	b := make([]byte, 10)
+	n, err := f.Read(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\r\n", string(b[:n]))
+	n, err = f.Write([]byte("AT\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, [][]byte{[]byte("AT\r\n")}, rw.writes)
+}
+
+func TestRead(t *testing.T) {
+	patterns := []struct {
+		name    string
+		options []fault.Option
+		in      string
+		size    int
+		out     []string
+		kind    fault.Kind
+		count   int
+	}{
+		{
+			"drop",
+			[]fault.Option{fault.WithDrop(fault.Always)},
+			"hello",
+			10,
+			nil,
+			fault.Drop,
+			5,
+		},
+		{
+			"drop writes",
+			[]fault.Option{fault.WithDrop(fault.Writes)},
+			"hello",
+			10,
+			[]string{"hello"},
+			fault.Drop,
+			0,
+		},
+		{
+			"duplicate",
+			[]fault.Option{fault.WithDuplicate(fault.Always)},
+			"abc",
+			4,
+			[]string{"aabb", "cc"},
+			fault.Duplicate,
+			3,
+		},
+		{
+			"corrupt",
+			[]fault.Option{fault.WithCorrupt(fault.Once(fault.Always)), fault.WithSeed(1)},
+			"abc",
+			10,
+			[]string{"ibc"},
+			fault.Corrupt,
+			1,
+		},
+		{
+			"split",
+			[]fault.Option{fault.WithSplit(fault.Once(fault.Reads)), fault.WithSeed(1)},
+			"hello\r\n",
+			10,
+			[]string{"hell", "o\r\n"},
+			fault.Split,
+			1,
+		},
+		{
+			"probability",
+			[]fault.Option{fault.WithDrop(fault.Probability(0.5)), fault.WithSeed(1)},
+			"hello world",
+			20,
+			[]string{"eo r"},
+			fault.Drop,
+			7,
+		},
+		{
+			"all",
+			[]fault.Option{fault.WithDrop(fault.All(fault.Reads, fault.Probability(0)))},
+			"hello",
+			10,
+			[]string{"hello"},
+			fault.Drop,
+			0,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			rw := &mockRW{rx: bytes.NewBufferString(p.in)}
+			f := fault.New(rw, p.options...)
+			var out []string
+			b := make([]byte, p.size)
+			for {
+				n, err := f.Read(b)
+				if n > 0 {
+					out = append(out, string(b[:n]))
+				}
+				if err != nil {
+					assert.Equal(t, io.EOF, err)
+					break
+				}
+			}
+			assert.Equal(t, p.out, out)
+			assert.Equal(t, p.count, f.Count(p.kind))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	patterns := []struct {
+		name    string
+		options []fault.Option
+		in      []string
+		out     []string
+	}{
+		{
+			"drop",
+			[]fault.Option{fault.WithDrop(fault.All(fault.Writes, fault.Once(fault.Always)))},
+			[]string{"AT\r\n"},
+			[]string{"T\r\n"},
+		},
+		{
+			"drop reads",
+			[]fault.Option{fault.WithDrop(fault.Reads)},
+			[]string{"AT\r\n"},
+			[]string{"AT\r\n"},
+		},
+		{
+			"duplicate",
+			[]fault.Option{fault.WithDuplicate(fault.Always)},
+			[]string{"AT"},
+			[]string{"AATT"},
+		},
+		{
+			"split",
+			[]fault.Option{fault.WithSplit(fault.Always), fault.WithSeed(1)},
+			[]string{"AT\r\n"},
+			[]string{"AT", "\r\n"},
+		},
+		{
+			"command",
+			[]fault.Option{fault.WithDrop(fault.OnCommand("atE0"))},
+			[]string{"AT\r\n", "ATE0\r\n", "ATE1\r\n"},
+			[]string{"AT\r\n", "ATE1\r\n"},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			rw := &mockRW{}
+			f := fault.New(rw, p.options...)
+			for _, w := range p.in {
+				n, err := f.Write([]byte(w))
+				assert.Nil(t, err)
+				assert.Equal(t, len(w), n)
+			}
+			var out []string
+			for _, w := range rw.writes {
+				out = append(out, string(w))
+			}
+			assert.Equal(t, p.out, out)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestWriteSplitError(t *testing.T) {
+	// the tail of the split fails
+	rw := &mockRW{wlimit: 1}
+	f := fault.New(rw, fault.WithSplit(fault.Always), fault.WithSeed(1))
+	n, err := f.Write([]byte("AT\r\n"))
+	assert.Equal(t, errWrite, err)
+	assert.Equal(t, 2, n)
+
+	// the head of the split is written short
+	rw = &mockRW{wlimit: 1, short: true}
+	f = fault.New(rw, fault.WithSplit(fault.Always), fault.WithSeed(1))
+	n, err = f.Write([]byte("AT\r\n"))
+	assert.Equal(t, io.ErrShortWrite, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestOnCommand(t *testing.T) {
+	rw := &mockRW{rx: &bytes.Buffer{}}
+	f := fault.New(rw, fault.WithDrop(fault.All(fault.Reads, fault.OnCommand("+CMGS"))))
+	b := make([]byte, 10)
+
+	f.Write([]byte("AT+CMGS=3\r"))
+	rw.rx.WriteString("\r\n> ")
+	n, err := f.Read(b)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, n)
+
+	f.Write([]byte("AT\r\n"))
+	rw.rx.WriteString("\r\nOK\r\n")
+	n, err = f.Read(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "\r\nOK\r\n", string(b[:n]))
+}
+
+func TestDelay(t *testing.T) {
+	rw := &mockRW{rx: bytes.NewBufferString("hello")}
+	f := fault.New(rw, fault.WithDelay(time.Millisecond, fault.Always))
+	b := make([]byte, 10)
+	_, err := f.Read(b)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("AT\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, f.Count(fault.Delay))
+}
+
+func TestStall(t *testing.T) {
+	rw := &mockRW{rx: bytes.NewBufferString("hello")}
+	f := fault.New(rw, fault.WithStall(fault.Reads))
+	done := make(chan error)
+	go func() {
+		b := make([]byte, 10)
+		_, err := f.Read(b)
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Error("read not stalled")
+	case <-time.After(10 * time.Millisecond):
+	}
+	// writes unaffected
+	_, err := f.Write([]byte("AT\r\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	select {
+	case err := <-done:
+		assert.Equal(t, io.EOF, err)
+	case <-time.After(100 * time.Millisecond):
+		t.Error("read still stalled")
+	}
+	assert.Equal(t, 1, f.Count(fault.Stall))
+}
+
+func TestEOF(t *testing.T) {
+	rw := &mockRW{rx: bytes.NewBufferString("hello")}
+	f := fault.New(rw, fault.WithEOF(fault.Always))
+	b := make([]byte, 10)
+	n, err := f.Read(b)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, n)
+	n, err = f.Write([]byte("AT\r\n"))
+	assert.Equal(t, fault.ErrClosed, err)
+	assert.Equal(t, 0, n)
+	assert.Nil(t, rw.writes)
+}
+
+func TestKindString(t *testing.T) {
+	assert.Equal(t, "drop", fault.Drop.String())
+	assert.Equal(t, "eof", fault.EOF.String())
+	assert.Equal(t, "unknown", fault.Kind(-1).String())
+}
+
+func TestATTimeout(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{}))
+	defer m.Close()
+	f := fault.New(m, fault.WithStall(fault.All(fault.Reads, fault.OnCommand("+CSQ"))))
+	a := at.New(f, at.WithTimeout(20*time.Millisecond))
+	_, err := a.Command("")
+	assert.Nil(t, err)
+	_, err = a.Command("+CSQ")
+	assert.Equal(t, at.ErrDeadlineExceeded, err)
+	f.Close()
+	select {
+	case <-a.Closed():
+	case <-time.After(100 * time.Millisecond):
+		t.Error("AT not closed")
+	}
+}
+
+func TestATEOF(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{}))
+	defer m.Close()
+	f := fault.New(m, fault.WithEOF(fault.OnCommand("+CSQ")))
+	a := at.New(f, at.WithTimeout(20*time.Millisecond))
+	_, err := a.Command("")
+	assert.Nil(t, err)
+	_, err = a.Command("+CSQ")
+	assert.Equal(t, at.ErrClosed, err)
+	select {
+	case <-a.Closed():
+	case <-time.After(100 * time.Millisecond):
+		t.Error("AT not closed")
+	}
+	_, err = a.Command("")
+	assert.Equal(t, at.ErrClosed, err)
+}
+
+func TestATSMSEscape(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{}))
+	defer m.Close()
+	// drop the prompt
+	f := fault.New(m, fault.WithDrop(fault.All(fault.Reads, fault.OnCommand("+CMGS"))))
+	a := at.New(f, at.WithTimeout(20*time.Millisecond), at.WithCmdTimeouts(nil))
+	_, err := a.SMSCommand("+CMGS=3", "000000")
+	assert.Equal(t, at.ErrDeadlineExceeded, err)
+	assert.True(t, f.Count(fault.Drop) > 0)
+	// escape returns the modem to command mode
+	_, err = a.Command("")
+	assert.Nil(t, err)
+	assert.Empty(t, m.Sent())
+}
+
+func TestATNoise(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{}))
+	defer m.Close()
+	f := fault.New(m,
+		fault.WithSeed(1),
+		fault.WithSplit(fault.Reads),
+		fault.WithDelay(time.Millisecond, fault.Always))
+	a := at.New(f, at.WithTimeout(100*time.Millisecond))
+	for i := 0; i < 10; i++ {
+		info, err := a.Command("+CGMI")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"warthog618"}, info)
+	}
+	assert.True(t, f.Count(fault.Split) > 0)
+}
+
+var errWrite = errors.New("write failed")
+
+type mockRW struct {
+	rx     *bytes.Buffer
+	writes [][]byte
+
+	// wlimit, if non-zero, is the number of writes accepted before writes
+	// fail with errWrite.
+	wlimit int
+
+	// short writes only the first byte of the writes accepted.
+	short bool
+}
+
+func (m *mockRW) Read(p []byte) (int, error) {
+	if m.rx == nil {
+		return 0, io.EOF
+	}
+	return m.rx.Read(p)
+}
+
+func (m *mockRW) Write(p []byte) (int, error) {
+	if m.wlimit > 0 && len(m.writes) >= m.wlimit {
+		return 0, errWrite
+	}
+	if m.short {
+		p = p[:1]
+	}
+	m.writes = append(m.writes, append([]byte{}, p...))
+	return len(p), nil
+}
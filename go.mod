@@ -5,12 +5,12 @@ require (
 	github.com/stretchr/testify v1.4.0
 	github.com/warthog618/sms v0.3.0
 	go.bug.st/serial v1.7.1
+	golang.org/x/sys v0.43.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sys v0.43.0 // indirect
 	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
 
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/info"
+)
+
+// TP-FCS failure causes used to reject messages, as per 3GPP TS 23.040
+// 9.2.3.22.
+const (
+	fcsMemoryCapacityExceeded = 0xd3
+	fcsUnspecified            = 0xff
+)
+
+// AckHandler receives a decoded SMS message from the modem, and returns nil
+// to acknowledge the message, or an error to reject it.
+//
+// A rejected message is retained by the SMSC to be delivered later.
+type AckHandler func(Message) error
+
+// ErrNack is returned by an AckHandler to reject a message with a particular
+// TP-FCS failure cause, e.g. 0xd0 if the SIM SMS storage is full.
+//
+// Other errors reject the message with memory capacity exceeded (0xd3).
+type ErrNack struct {
+	FCS byte
+}
+
+func (e ErrNack) Error() string {
+	return fmt.Sprintf("message rejected with cause 0x%02x", e.FCS)
+}
+
+// fcsOf returns the TP-FCS failure cause for rejecting a message with the
+// error.
+func fcsOf(err error) byte {
+	switch v := err.(type) {
+	case ErrNack:
+		return v.FCS
+	case ErrCollect, ErrUnmarshal:
+		return fcsUnspecified
+	}
+	return fcsMemoryCapacityExceeded
+}
+
+type ackHandlerOption AckHandler
+
+func (o ackHandlerOption) applyRxOption(c *rxConfig) {
+	c.ah = AckHandler(o)
+}
+
+// WithAckHandler passes received messages to the AckHandler, rather than to
+// the MessageHandler passed to StartMessageRx, which may then be nil.
+//
+// Messages are acknowledged once handled, unless overridden by WithAckTiming.
+// Messages received via store and notify are not acknowledged, but those
+// rejected by the AckHandler are retained in storage, and collected again
+// when reception is next started.
+func WithAckHandler(ah AckHandler) RxOption {
+	return ackHandlerOption(ah)
+}
+
+// AckTiming determines when messages forwarded by +CMT indications are
+// acknowledged.
+type AckTiming int
+
+const (
+	// AckOnReceipt acknowledges each TPDU as soon as it is received, before it
+	// is decoded.
+	AckOnReceipt AckTiming = iota
+
+	// AckOnCollect acknowledges each TPDU once it has been collected and,
+	// for the final TPDU of a message, the message decoded, but before the
+	// message is handled.
+	//
+	// This is the default unless an AckHandler is provided.
+	AckOnCollect
+
+	// AckOnHandled acknowledges the final TPDU of a message once the message
+	// has been handled.
+	//
+	// This is the default if an AckHandler is provided.
+	AckOnHandled
+)
+
+type ackTimingOption AckTiming
+
+func (o ackTimingOption) applyRxOption(c *rxConfig) {
+	c.timing = AckTiming(o)
+	c.timingSet = true
+}
+
+// WithAckTiming sets when messages forwarded by +CMT indications are
+// acknowledged.
+//
+// Acknowledging late allows a message that cannot be decoded or handled to be
+// rejected, but the modem may give up waiting for the acknowledgement if the
+// handler is slow.
+func WithAckTiming(t AckTiming) RxOption {
+	return ackTimingOption(t)
+}
+
+// ack acknowledges a message forwarded by a +CMT indication if err is nil,
+// else rejects it.
+//
+// Messages can only be rejected in PDU mode, so are acknowledged in text mode
+// regardless.
+func (g *GSM) ack(err error) error {
+	if err == nil || !g.pduMode {
+		_, err = g.Command("+CNMA", at.WithPriority(at.PriorityHigh))
+		return err
+	}
+	t := tpdu.TPDU{FCS: fcsOf(err)}
+	t.SetSmsType(tpdu.SmsDeliverReport)
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = g.SMSCommand(fmt.Sprintf("+CNMA=2,%d", len(b)), hex.EncodeToString(b),
+		at.WithPriority(at.PriorityHigh))
+	return err
+}
+
+// ackRequired returns true if the modem requires messages forwarded by +CMT
+// indications to be acknowledged, i.e. it is using phase 2+ message service.
+//
+// The service is that selected by the last +CSMS command in cmds, else that
+// reported by the modem.
+// If the service cannot be determined then acknowledgement is assumed to be
+// required.
+func (g *GSM) ackRequired(cmds []string) bool {
+	for i := len(cmds) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(cmds[i], "+CSMS=") {
+			continue
+		}
+		service, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(cmds[i], "+CSMS=")))
+		if err != nil {
+			return true
+		}
+		return service == 1
+	}
+	i, err := g.Command("+CSMS?")
+	if err != nil {
+		return true
+	}
+	for _, l := range i {
+		if !info.HasPrefix(l, "+CSMS") {
+			continue
+		}
+		fields := strings.Split(info.TrimPrefix(l, "+CSMS"), ",")
+		service, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return true
+		}
+		return service == 1
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestAckHandler(t *testing.T) {
+	patterns := []struct {
+		name    string
+		err     error
+		options []gsm.RxOption
+		acks    int
+		nacks   []byte
+	}{
+		{"ack", nil, nil, 1, nil},
+		{"nack", errors.New("busy"), nil, 0, []byte{0xd3}},
+		{"nack cause", gsm.ErrNack{FCS: 0xd0}, nil, 0, []byte{0xd0}},
+		{"on receipt", errors.New("busy"), []gsm.RxOption{gsm.WithAckTiming(gsm.AckOnReceipt)}, 1, nil},
+		{"on collect", errors.New("busy"), []gsm.RxOption{gsm.WithAckTiming(gsm.AckOnCollect)}, 1, nil},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork()
+			defer n.Close()
+			g := networkGSM(t, n, "+61400000001")
+			r := networkGSM(t, n, "+61400000002")
+			m := n.Modem("+61400000002")
+			msgs := make(chan gsm.Message, 1)
+			errs := make(chan error, 1)
+			ah := func(msg gsm.Message) error {
+				msgs <- msg
+				return p.err
+			}
+			options := append([]gsm.RxOption{gsm.WithAckHandler(ah)}, p.options...)
+			err := r.StartMessageRx(nil, func(err error) { errs <- err }, options...)
+			require.Nil(t, err)
+			_, err = g.SendShortMessage("+61400000002", "hello")
+			require.Nil(t, err)
+			select {
+			case msg := <-msgs:
+				assert.Equal(t, "hello", msg.Message)
+			case err := <-errs:
+				t.Fatalf("unexpected error: %v", err)
+			case <-time.After(time.Second):
+				t.Fatal("no message received")
+			}
+			waitFor(t, func() bool { return m.PendingAcks() == 0 })
+			assert.Equal(t, p.acks, m.Acks())
+			assert.Equal(t, p.nacks, m.Nacks())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestAckNotRequired(t *testing.T) {
+	patterns := []struct {
+		name     string
+		initCmds []string
+	}{
+		{"selected", []string{"+CSMS=0", "+CNMI=1,2,0,0,0"}},
+		{"queried", []string{"+CNMI=1,2,0,0,0"}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork()
+			defer n.Close()
+			g := networkGSM(t, n, "+61400000001")
+			r := networkGSM(t, n, "+61400000002")
+			m := n.Modem("+61400000002")
+			msgs := make(chan gsm.Message, 1)
+			errs := make(chan error, 1)
+			err := r.StartMessageRx(
+				func(msg gsm.Message) { msgs <- msg },
+				func(err error) { errs <- err },
+				gsm.WithInitCmds(p.initCmds...))
+			require.Nil(t, err)
+			_, err = g.SendShortMessage("+61400000002", "hello")
+			require.Nil(t, err)
+			select {
+			case msg := <-msgs:
+				assert.Equal(t, "hello", msg.Message)
+			case err := <-errs:
+				t.Fatalf("unexpected error: %v", err)
+			case <-time.After(time.Second):
+				t.Fatal("no message received")
+			}
+			select {
+			case err := <-errs:
+				t.Errorf("unexpected error: %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+			assert.Equal(t, 0, m.Acks())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestAckRxErrors(t *testing.T) {
+	// UCS2 with a dangling surrogate
+	bad := tpdu.TPDU{
+		OA: tpdu.NewAddress(tpdu.FromNumber("+61400000001")),
+		UD: []byte{0xd8, 0x00},
+	}
+	bad.SetSmsType(tpdu.SmsDeliver)
+	bad.SetDCS(0x08)
+	patterns := []struct {
+		name  string
+		tpdus []tpdu.TPDU
+		acks  int
+		nacks []byte
+	}{
+		{"duplicate segment", []tpdu.TPDU{segment(1, 2, "one"), segment(1, 2, "one")}, 2, nil},
+		{"inconsistent segment", []tpdu.TPDU{segment(1, 2, "one"), segment(3, 2, "three")}, 1, []byte{0xff}},
+		{"undecodable", []tpdu.TPDU{bad}, 1, nil},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork()
+			defer n.Close()
+			r := networkGSM(t, n, "+61400000002")
+			m := n.Modem("+61400000002")
+			errs := make(chan error, len(p.tpdus))
+			err := r.StartMessageRx(
+				func(msg gsm.Message) { t.Errorf("unexpected message: %v", msg) },
+				func(err error) { errs <- err })
+			require.Nil(t, err)
+			for _, tp := range p.tpdus {
+				b, err := tp.MarshalBinary()
+				require.Nil(t, err)
+				require.Nil(t, m.Deliver(b))
+				waitFor(t, func() bool { return m.PendingAcks() == 0 })
+			}
+			select {
+			case err := <-errs:
+				switch err.(type) {
+				case gsm.ErrCollect, gsm.ErrDecode:
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no error reported")
+			}
+			assert.Equal(t, p.acks, m.Acks())
+			assert.Equal(t, p.nacks, m.Nacks())
+		}
+		t.Run(p.name, f)
+	}
+}
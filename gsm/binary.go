@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+)
+
+// Information element IDs for application port addressing, as per 3GPP TS
+// 23.040 9.2.3.24.
+const (
+	iePorts8  = 0x04
+	iePorts16 = 0x05
+)
+
+// Ports are the application ports of a port addressed message.
+type Ports struct {
+	// Src is the originator port.
+	Src uint16
+
+	// Dst is the destination port.
+	Dst uint16
+}
+
+// portsOf returns the application ports in the UDH, or nil if none.
+func portsOf(udh tpdu.UserDataHeader) *Ports {
+	if ie, ok := udh.IE(iePorts16); ok && len(ie.Data) == 4 {
+		return &Ports{
+			Dst: uint16(ie.Data[0])<<8 | uint16(ie.Data[1]),
+			Src: uint16(ie.Data[2])<<8 | uint16(ie.Data[3]),
+		}
+	}
+	if ie, ok := udh.IE(iePorts8); ok && len(ie.Data) == 2 {
+		return &Ports{Dst: uint16(ie.Data[0]), Src: uint16(ie.Data[1])}
+	}
+	return nil
+}
+
+// SendBinaryMessage sends 8-bit data to the application port at the number.
+//
+// The data is addressed from the srcPort to the dstPort using a 16-bit
+// application port addressing information element, and is split into
+// concatenated SMS PDUs, if necessary.
+//
+// The modem must be in PDU mode.
+// SendOptions other than WithAlphabet may be applied.
+//
+// The mr of sent PDUs is returned on success, else an error.
+func (g *GSM) SendBinaryMessage(number string, srcPort, dstPort uint16, data []byte, options ...at.CommandOption) (rsp []string, err error) {
+	if !g.pduMode {
+		err = ErrWrongMode
+		return
+	}
+	cfg := newSendConfig(options)
+	udh := tpdu.UserDataHeader{{
+		ID:   iePorts16,
+		Data: []byte{byte(dstPort >> 8), byte(dstPort), byte(srcPort >> 8), byte(srcPort)},
+	}}
+	setUDH := func(t *tpdu.TPDU) error {
+		t.SetUDH(udh)
+		return nil
+	}
+	alphabetOption{a: tpdu.Alpha8Bit}.applySendOption(&cfg)
+	cfg.topts = append(cfg.topts, tpduOptionFunc(setUDH))
+	var pdus []tpdu.TPDU
+	pdus, err = g.encode(number, data, cfg)
+	if err != nil {
+		return
+	}
+	return g.sendPDUs(pdus, options)
+}
+
+type portHandlerOption struct {
+	port uint16
+	mh   MessageHandler
+}
+
+func (o portHandlerOption) applyRxOption(c *rxConfig) {
+	if c.portHandlers == nil {
+		c.portHandlers = make(map[uint16]MessageHandler)
+	}
+	c.portHandlers[o.port] = o.mh
+}
+
+// WithPortHandler routes received messages addressed to the destination port
+// to the message handler, rather than to the handler passed to
+// StartMessageRx.
+//
+// The option may be applied several times to route different ports.
+func WithPortHandler(port uint16, mh MessageHandler) RxOption {
+	return portHandlerOption{port, mh}
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestSendBinaryMessage(t *testing.T) {
+	patterns := []struct {
+		name     string
+		data     []byte
+		segments int
+	}{
+		{"short", []byte{0x00, 0x01, 0xfe, 0xff}, 1},
+		{"long", bytes.Repeat([]byte{0x00, 0x01, 0xfe, 0xff}, 75), 3},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m, g := sendModem(t)
+			defer m.Close()
+			mrs, err := g.SendBinaryMessage("+61400000002", 0x1234, 2948, p.data, gsm.WithPID(0x41))
+			require.Nil(t, err)
+			assert.Equal(t, p.segments, len(mrs))
+			sent := m.Sent()
+			require.Equal(t, p.segments, len(sent))
+			var data []byte
+			for _, s := range sent {
+				tp, err := sms.Unmarshal(s.TPDU, sms.AsMO)
+				require.Nil(t, err)
+				a, _ := tp.Alphabet()
+				assert.Equal(t, tpdu.Alpha8Bit, a)
+				assert.Equal(t, byte(0x41), tp.PID)
+				assert.True(t, tp.UDHI())
+				ie, ok := tp.UDH.IE(0x05)
+				assert.True(t, ok)
+				assert.Equal(t, []byte{0x0b, 0x84, 0x12, 0x34}, ie.Data)
+				_, _, _, concat := tp.ConcatInfo()
+				assert.Equal(t, p.segments > 1, concat)
+				data = append(data, tp.UD...)
+			}
+			assert.Equal(t, p.data, data)
+		}
+		t.Run(p.name, f)
+	}
+
+	// wrong mode
+	m := sim.New()
+	defer m.Close()
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithTextMode)
+	_, err := g.SendBinaryMessage("+61400000002", 1, 2, []byte{1})
+	assert.Equal(t, gsm.ErrWrongMode, err)
+}
+
+func TestPortHandler(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	g := networkGSM(t, n, "+61400000001")
+	r := networkGSM(t, n, "+61400000002")
+	msgs := make(chan gsm.Message, 3)
+	portMsgs := make(chan gsm.Message, 3)
+	errs := make(chan error, 3)
+	err := r.StartMessageRx(
+		func(msg gsm.Message) { msgs <- msg },
+		func(err error) { errs <- err },
+		gsm.WithPortHandler(2948, func(msg gsm.Message) { portMsgs <- msg }))
+	require.Nil(t, err)
+
+	receive := func(c chan gsm.Message) gsm.Message {
+		t.Helper()
+		select {
+		case msg := <-c:
+			return msg
+		case err := <-errs:
+			t.Fatalf("unexpected error: %v", err)
+		case <-time.After(time.Second):
+			t.Fatal("no message received")
+		}
+		return gsm.Message{}
+	}
+
+	// routed by port
+	data := bytes.Repeat([]byte{0x00, 0x01, 0xfe, 0xff}, 75)
+	_, err = g.SendBinaryMessage("+61400000002", 9200, 2948, data)
+	require.Nil(t, err)
+	msg := receive(portMsgs)
+	assert.Equal(t, "+61400000001", msg.Number)
+	assert.Equal(t, data, msg.Data)
+	assert.Equal(t, string(data), msg.Message)
+	assert.Equal(t, &gsm.Ports{Src: 9200, Dst: 2948}, msg.Ports)
+
+	// other ports use the default handler
+	_, err = g.SendBinaryMessage("+61400000002", 9200, 2949, []byte{1, 2})
+	require.Nil(t, err)
+	msg = receive(msgs)
+	assert.Equal(t, []byte{1, 2}, msg.Data)
+	assert.Equal(t, &gsm.Ports{Src: 9200, Dst: 2949}, msg.Ports)
+
+	// as do text messages
+	_, err = g.SendShortMessage("+61400000002", "hello")
+	require.Nil(t, err)
+	msg = receive(msgs)
+	assert.Equal(t, "hello", msg.Message)
+	assert.Equal(t, []byte("hello"), msg.Data)
+	assert.Nil(t, msg.Ports)
+}
+
+func TestReadBinaryMessage(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{CMEE: 1}))
+	defer m.Close()
+	tp := tpdu.TPDU{
+		OA: tpdu.NewAddress(tpdu.FromNumber("+61400000001")),
+		UD: []byte{1, 2, 3},
+	}
+	tp.SetSmsType(tpdu.SmsDeliver)
+	tp.SetDCS(byte(tpdu.Dcs8BitData))
+	tp.SetUDH(tpdu.UserDataHeader{{ID: 0x04, Data: []byte{80, 81}}})
+	b, err := tp.MarshalBinary()
+	require.Nil(t, err)
+	_, err = m.StoreMessage("SM", sim.StatusRecUnread, b)
+	require.Nil(t, err)
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	msg, err := g.ReadMessage(1)
+	require.Nil(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, msg.Data)
+	assert.Equal(t, &gsm.Ports{Src: 81, Dst: 80}, msg.Ports)
+	assert.Equal(t, gsm.StatusRecUnread, msg.Status)
+}
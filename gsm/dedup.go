@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// DedupStore records the keys of received TPDUs, to detect duplicates.
+//
+// A persistent implementation allows duplicates to be detected across
+// restarts.
+//
+// Implementations must be safe for concurrent use.
+type DedupStore interface {
+	// Contains returns true if the key has been added and has not expired.
+	Contains(key string) (bool, error)
+
+	// Add adds the key, which expires at the expiry time.
+	Add(key string, expiry time.Time) error
+}
+
+// MemoryDedupStore is a DedupStore held in memory.
+//
+// This is the default store used by WithDedup.
+type MemoryDedupStore struct {
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+// NewMemoryDedupStore creates an empty MemoryDedupStore.
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{keys: make(map[string]time.Time)}
+}
+
+// Contains returns true if the key has been added and has not expired.
+func (s *MemoryDedupStore) Contains(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.keys[key]
+	return ok && time.Now().Before(expiry), nil
+}
+
+// Add adds the key, which expires at the expiry time.
+//
+// Expired keys are pruned as keys are added.
+func (s *MemoryDedupStore) Add(key string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, e := range s.keys {
+		if !now.Before(e) {
+			delete(s.keys, k)
+		}
+	}
+	s.keys[key] = expiry
+	return nil
+}
+
+// ErrDuplicate indicates a received TPDU was discarded as a duplicate of one
+// already handled.
+type ErrDuplicate struct {
+	TPDU tpdu.TPDU
+}
+
+func (e ErrDuplicate) Error() string {
+	return fmt.Sprintf("duplicate TPDU from %s", e.TPDU.OA.Number())
+}
+
+// dedupKey returns the key identifying the TPDU, being the originating
+// address, the SCTS, and, for concatenated messages, the reference and
+// sequence number of the segment.
+func dedupKey(tp *tpdu.TPDU) string {
+	_, seqno, mref, _ := tp.ConcatInfo()
+	return fmt.Sprintf("%s/%d/%d/%d", tp.OA.Number(), tp.SCTS.Unix(), mref, seqno)
+}
+
+// dedup detects duplicate TPDUs.
+//
+// A nil dedup detects no duplicates.
+type dedup struct {
+	store  DedupStore
+	window time.Duration
+	eh     ErrorHandler
+}
+
+// seen returns true if the TPDU is a duplicate of a handled TPDU.
+//
+// If the store fails the TPDU is assumed not to be a duplicate.
+func (d *dedup) seen(tp *tpdu.TPDU) bool {
+	if d == nil {
+		return false
+	}
+	ok, err := d.store.Contains(dedupKey(tp))
+	if err != nil {
+		d.eh(err)
+		return false
+	}
+	return ok
+}
+
+// add records the TPDUs of a handled message.
+func (d *dedup) add(tpdus []*tpdu.TPDU) {
+	if d == nil {
+		return
+	}
+	expiry := time.Now().Add(d.window)
+	for _, tp := range tpdus {
+		if tp == nil {
+			continue
+		}
+		if err := d.store.Add(dedupKey(tp), expiry); err != nil {
+			d.eh(err)
+		}
+	}
+}
+
+type dedupOption time.Duration
+
+func (o dedupOption) applyRxOption(c *rxConfig) {
+	c.dedupWindow = time.Duration(o)
+}
+
+// WithDedup discards received TPDUs that duplicate those of messages already
+// handled within the window, such as those redelivered by the SMSC when an
+// acknowledgement is late.
+//
+// TPDUs are identified by their originating address and SCTS, and, for
+// concatenated messages, their reference and sequence number.
+//
+// Duplicates are acknowledged, or deleted from storage, but not passed to the
+// handler.
+func WithDedup(window time.Duration) RxOption {
+	return dedupOption(window)
+}
+
+type dedupStoreOption struct {
+	s DedupStore
+}
+
+func (o dedupStoreOption) applyRxOption(c *rxConfig) {
+	c.dedupStore = o.s
+}
+
+// WithDedupStore overrides the store used by WithDedup to record handled
+// TPDUs, which is a MemoryDedupStore by default.
+//
+// If WithDedup is not also applied then the window is 24 hours.
+func WithDedupStore(s DedupStore) RxOption {
+	return dedupStoreOption{s}
+}
+
+type duplicateErrorsOption bool
+
+func (o duplicateErrorsOption) applyRxOption(c *rxConfig) {
+	c.dupErrors = bool(o)
+}
+
+// WithDuplicateErrors passes an ErrDuplicate to the error handler for each
+// duplicate discarded, rather than discarding it silently.
+var WithDuplicateErrors = duplicateErrorsOption(true)
+
+// newDedup returns the dedup for the configuration, or nil if deduplication
+// is not enabled.
+func newDedup(c rxConfig, eh ErrorHandler) *dedup {
+	if c.dedupWindow <= 0 && c.dedupStore == nil {
+		return nil
+	}
+	d := dedup{store: c.dedupStore, window: c.dedupWindow, eh: eh}
+	if d.store == nil {
+		d.store = NewMemoryDedupStore()
+	}
+	if d.window <= 0 {
+		d.window = 24 * time.Hour
+	}
+	return &d
+}
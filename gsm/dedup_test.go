@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+const cmtHello = "\r\n+CMT: ,24\r\n00040B911234567890F000000250100173832305C8329BFD06\r\n"
+
+func TestDedup(t *testing.T) {
+	store := gsm.NewMemoryDedupStore()
+	patterns := []struct {
+		name    string
+		options []gsm.RxOption
+		msgs    int
+		dups    int
+	}{
+		{"off", nil, 2, 0},
+		{"silent", []gsm.RxOption{gsm.WithDedup(time.Hour)}, 1, 0},
+		{"errors", []gsm.RxOption{gsm.WithDedup(time.Hour), gsm.WithDuplicateErrors}, 1, 1},
+		{"expired", []gsm.RxOption{gsm.WithDedup(time.Nanosecond)}, 2, 0},
+		{"store", []gsm.RxOption{gsm.WithDedupStore(store)}, 1, 0},
+		// the store retains the TPDU from the previous pattern
+		{"restart", []gsm.RxOption{gsm.WithDedupStore(store)}, 0, 0},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := sim.New()
+			defer m.Close()
+			g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+			msgs := make(chan gsm.Message, 2)
+			errs := make(chan error, 2)
+			// phase 2 service, so injected messages are not acknowledged
+			options := append([]gsm.RxOption{gsm.WithInitCmds("+CNMI=1,2,0,0,0")}, p.options...)
+			err := g.StartMessageRx(
+				func(msg gsm.Message) { msgs <- msg },
+				func(err error) { errs <- err },
+				options...)
+			require.Nil(t, err)
+			m.Inject(cmtHello)
+			m.Inject(cmtHello)
+			var nmsgs, ndups int
+			timeout := time.After(100 * time.Millisecond)
+			for done := false; !done; {
+				select {
+				case msg := <-msgs:
+					assert.Equal(t, "Hello", msg.Message)
+					nmsgs++
+				case err := <-errs:
+					dup, ok := err.(gsm.ErrDuplicate)
+					require.True(t, ok, err)
+					assert.Equal(t, "+21436587090", dup.TPDU.OA.Number())
+					ndups++
+				case <-timeout:
+					done = true
+				}
+			}
+			assert.Equal(t, p.msgs, nmsgs)
+			assert.Equal(t, p.dups, ndups)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestDedupAck(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	r := networkGSM(t, n, "+61400000002")
+	m := n.Modem("+61400000002")
+	msgs := make(chan gsm.Message, 2)
+	errs := make(chan error, 2)
+	ah := func(msg gsm.Message) error {
+		msgs <- msg
+		return nil
+	}
+	err := r.StartMessageRx(nil,
+		func(err error) { errs <- err },
+		gsm.WithAckHandler(ah),
+		gsm.WithDedup(time.Hour))
+	require.Nil(t, err)
+	tp, err := hex.DecodeString("040B911234567890F000000250100173832305C8329BFD06")
+	require.Nil(t, err)
+	require.Nil(t, m.Deliver(tp))
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, "Hello", msg.Message)
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	// the message is recorded as handled before it is acknowledged
+	waitFor(t, func() bool { return m.Acks() == 1 })
+	require.Nil(t, m.Deliver(tp))
+	// the duplicate is acknowledged but not handled
+	waitFor(t, func() bool { return m.Acks() == 2 })
+	select {
+	case msg := <-msgs:
+		t.Errorf("duplicate received: %v", msg)
+	case err := <-errs:
+		t.Errorf("unexpected error: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDedupStored(t *testing.T) {
+	m := sim.New()
+	defer m.Close()
+	tp, err := hex.DecodeString("040B911234567890F000000250100173832305C8329BFD06")
+	require.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = m.StoreMessage("SM", sim.StatusRecUnread, tp)
+		require.Nil(t, err)
+	}
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	msgs := make(chan gsm.Message, 2)
+	errs := make(chan error, 2)
+	err = g.StartMessageRx(
+		func(msg gsm.Message) { msgs <- msg },
+		func(err error) { errs <- err },
+		gsm.WithStoreAndNotify,
+		gsm.WithDedup(time.Hour),
+		gsm.WithDuplicateErrors)
+	require.Nil(t, err)
+	// the sweep processes both before returning
+	require.Equal(t, 1, len(msgs))
+	assert.Equal(t, "Hello", (<-msgs).Message)
+	require.Equal(t, 1, len(errs))
+	assert.IsType(t, gsm.ErrDuplicate{}, <-errs)
+	// both are deleted from storage
+	msgList, err := g.ListMessages(gsm.StatusAll)
+	require.Nil(t, err)
+	assert.Empty(t, msgList)
+}
@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// FileCollector is a Collector that persists the segments of partially
+// reassembled concatenated messages to a directory, so they survive restarts.
+//
+// Each partial message is held in its own file, which is written atomically
+// and removed once the message is complete or its reassembly times out.
+//
+// Use WithCollector to pass it to StartMessageRx.
+type FileCollector struct {
+	dir           string
+	timeout       time.Duration
+	expiryHandler func([]*tpdu.TPDU)
+	errorHandler  ErrorHandler
+
+	// mu protects the fields following.
+	mu     sync.Mutex
+	pipes  map[string]*filePipe
+	closed bool
+}
+
+// filePipe contains the segments of a partially reassembled message.
+type filePipe struct {
+	// updated is when the most recent segment was collected.
+	updated  time.Time
+	segments []*tpdu.TPDU
+	frags    int
+	timer    *time.Timer
+}
+
+// pendingFile is the persisted form of a filePipe.
+type pendingFile struct {
+	Updated time.Time
+
+	// Segments contains the hex encoded TPDUs, with missing segments empty.
+	Segments []string
+}
+
+const (
+	pendingSuffix = ".json"
+	corruptSuffix = ".corrupt"
+	tmpPrefix     = ".tmp-"
+)
+
+// FileCollectorOption is a construction option for a FileCollector.
+type FileCollectorOption interface {
+	applyFileCollectorOption(*FileCollector)
+}
+
+// NewFileCollector creates a FileCollector that persists partial messages in
+// the directory, creating it if necessary.
+//
+// Any partial messages already persisted in the directory are restored, along
+// with their reassembly timers.  Those which have already timed out are passed
+// to the expiry handler shortly after the FileCollector is created.
+// Files that cannot be restored are renamed with a ".corrupt" suffix, so they
+// are not restored again, and reported to the error handler, if any.
+func NewFileCollector(dir string, options ...FileCollectorOption) (*FileCollector, error) {
+	c := &FileCollector{
+		dir:     dir,
+		timeout: 24 * time.Hour,
+		pipes:   make(map[string]*filePipe),
+	}
+	for _, option := range options {
+		option.applyFileCollectorOption(c)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := c.restore(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+type fileReassemblyTimeoutOption struct {
+	d time.Duration
+	f func([]*tpdu.TPDU)
+}
+
+func (o fileReassemblyTimeoutOption) applyFileCollectorOption(c *FileCollector) {
+	c.timeout = o.d
+	c.expiryHandler = o.f
+}
+
+// WithFileReassemblyTimeout overrides the time allowed for all the segments of
+// a concatenated message to be collected, which is 24 hours by default.
+//
+// The time is measured from the collection of the most recent segment, and
+// continues across restarts.
+// The expiry handler, if not nil, is passed the segments collected for a
+// message that times out.
+func WithFileReassemblyTimeout(d time.Duration, f func([]*tpdu.TPDU)) FileCollectorOption {
+	return fileReassemblyTimeoutOption{d, f}
+}
+
+type fileErrorHandlerOption ErrorHandler
+
+func (o fileErrorHandlerOption) applyFileCollectorOption(c *FileCollector) {
+	c.errorHandler = ErrorHandler(o)
+}
+
+// WithFileErrorHandler provides a handler for errors that occur outside of
+// Collect, such as partial messages that cannot be restored.
+func WithFileErrorHandler(eh ErrorHandler) FileCollectorOption {
+	return fileErrorHandlerOption(eh)
+}
+
+// Collect adds the TPDU to the collection, returning the TPDUs of the message
+// once it is complete.
+//
+// If the segment cannot be persisted then it is not collected, and the error
+// is returned.
+func (c *FileCollector) Collect(tp tpdu.TPDU) ([]*tpdu.TPDU, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, sms.ErrClosed
+	}
+	segments, seqno, mref, ok := tp.ConcatInfo()
+	if !ok || segments < 2 {
+		return []*tpdu.TPDU{&tp}, nil
+	}
+	if seqno < 1 || seqno > segments {
+		return nil, sms.ErrReassemblyInconsistency
+	}
+	key := fmt.Sprintf("%s:%d:%d", tp.OA.Number(), segments, mref)
+	p, ok := c.pipes[key]
+	if ok {
+		if len(p.segments) != segments {
+			// a corrupted restore
+			return nil, sms.ErrReassemblyInconsistency
+		}
+		if p.segments[seqno-1] != nil {
+			return nil, sms.ErrDuplicateSegment
+		}
+		if !p.timer.Stop() {
+			// timed out, but not yet cleaned up, so start afresh
+			ok = false
+		}
+	}
+	if !ok {
+		p = &filePipe{segments: make([]*tpdu.TPDU, segments)}
+	}
+	p.segments[seqno-1] = &tp
+	p.frags++
+	if p.frags == segments {
+		delete(c.pipes, key)
+		// the message is complete, so a stale file is no reason to fail it
+		if os.Remove(c.path(key)) == nil {
+			c.syncDir()
+		}
+		return p.segments, nil
+	}
+	p.updated = time.Now()
+	err := c.write(key, p)
+	if err != nil {
+		// forget the segment, so it may be redelivered
+		p.segments[seqno-1] = nil
+		p.frags--
+	}
+	if p.frags > 0 {
+		c.pipes[key] = p
+		p.timer = c.expire(key, p, c.timeout)
+	}
+	return nil, err
+}
+
+// Close stops the reassembly timers.
+//
+// Partial messages remain persisted, to be restored by the next FileCollector
+// created for the directory.
+func (c *FileCollector) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, p := range c.pipes {
+		p.timer.Stop()
+	}
+}
+
+// expire starts the reassembly timer for the pipe.
+func (c *FileCollector) expire(key string, p *filePipe, d time.Duration) *time.Timer {
+	return time.AfterFunc(d, func() {
+		c.mu.Lock()
+		if c.pipes[key] == p {
+			delete(c.pipes, key)
+			if os.Remove(c.path(key)) == nil {
+				c.syncDir()
+			}
+		}
+		c.mu.Unlock()
+		if c.expiryHandler != nil {
+			c.expiryHandler(p.segments)
+		}
+	})
+}
+
+// path returns the path of the file persisting the partial message.
+func (c *FileCollector) path(key string) string {
+	return filepath.Join(c.dir, hex.EncodeToString([]byte(key))+pendingSuffix)
+}
+
+// write atomically persists the partial message.
+func (c *FileCollector) write(key string, p *filePipe) (err error) {
+	pf := pendingFile{Updated: p.updated, Segments: make([]string, len(p.segments))}
+	for i, s := range p.segments {
+		if s == nil {
+			continue
+		}
+		var b []byte
+		b, err = s.MarshalBinary()
+		if err != nil {
+			return
+		}
+		pf.Segments[i] = hex.EncodeToString(b)
+	}
+	b, err := json.Marshal(pf)
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.dir, tmpPrefix)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(f.Name(), c.path(key)); err != nil {
+		return
+	}
+	return c.syncDir()
+}
+
+// syncDir flushes the directory, so that renames and removals of its files
+// are persisted.
+func (c *FileCollector) syncDir() error {
+	d, err := os.Open(c.dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// restore reloads the partial messages persisted in the directory, and
+// removes any temporary files left by an interrupted write.
+//
+// Files that cannot be read are moved aside and reported to the error
+// handler, rather than preventing the remainder being restored.
+func (c *FileCollector) restore() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, tmpPrefix) {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, pendingSuffix) {
+			continue
+		}
+		key, err := hex.DecodeString(strings.TrimSuffix(name, pendingSuffix))
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(c.dir, name)
+		p, err := readPending(path)
+		if err != nil {
+			if rerr := os.Rename(path, path+corruptSuffix); rerr != nil {
+				return rerr
+			}
+			c.syncDir()
+			if c.errorHandler != nil {
+				c.errorHandler(err)
+			}
+			continue
+		}
+		c.pipes[string(key)] = p
+		p.timer = c.expire(string(key), p, time.Until(p.updated.Add(c.timeout)))
+	}
+	return nil
+}
+
+// readPending reads the partial message persisted in the file.
+func readPending(path string) (*filePipe, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pf pendingFile
+	if err = json.Unmarshal(b, &pf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p := &filePipe{updated: pf.Updated, segments: make([]*tpdu.TPDU, len(pf.Segments))}
+	for i, s := range pf.Segments {
+		if s == "" {
+			continue
+		}
+		tb, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		tp, err := sms.Unmarshal(tb)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		p.segments[i] = tp
+		p.frags++
+	}
+	return p, nil
+}
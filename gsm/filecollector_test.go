@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/gsm"
+)
+
+// segment returns segment seqno of a concatenated SMS-DELIVER.
+func segment(seqno, segments int, ud string) tpdu.TPDU {
+	tp := tpdu.TPDU{
+		OA: tpdu.NewAddress(tpdu.FromNumber("+61400000001")),
+		SCTS: tpdu.Timestamp{
+			Time: time.Date(2026, time.October, 18, 9, 30, 0, 0, time.FixedZone("SCTS", 10*3600))},
+		UD: []byte(ud),
+	}
+	tp.SetSmsType(tpdu.SmsDeliver)
+	tp.SetDCS(byte(tpdu.Dcs8BitData))
+	tp.SetUDH(tpdu.UserDataHeader{{ID: 0, Data: []byte{42, byte(segments), byte(seqno)}}})
+	return tp
+}
+
+func TestFileCollector(t *testing.T) {
+	dir := t.TempDir()
+	c, err := gsm.NewFileCollector(dir)
+	require.Nil(t, err)
+
+	// single segment
+	tp := segment(1, 1, "single")
+	tpdus, err := c.Collect(tp)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(tpdus))
+	assert.Equal(t, tpdu.UserData("single"), tpdus[0].UD)
+
+	// partial
+	tpdus, err = c.Collect(segment(2, 3, "two"))
+	require.Nil(t, err)
+	assert.Nil(t, tpdus)
+	tpdus, err = c.Collect(segment(2, 3, "two"))
+	assert.Equal(t, sms.ErrDuplicateSegment, err)
+	assert.Nil(t, tpdus)
+	_, err = c.Collect(segment(4, 3, "four"))
+	assert.Equal(t, sms.ErrReassemblyInconsistency, err)
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+
+	// restart
+	c.Close()
+	_, err = c.Collect(segment(1, 3, "one"))
+	assert.Equal(t, sms.ErrClosed, err)
+	// as if interrupted while writing
+	require.Nil(t, os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("junk"), 0o600))
+	c, err = gsm.NewFileCollector(dir)
+	require.Nil(t, err)
+	defer c.Close()
+	tpdus, err = c.Collect(segment(1, 3, "one"))
+	require.Nil(t, err)
+	assert.Nil(t, tpdus)
+	tpdus, err = c.Collect(segment(3, 3, "three"))
+	require.Nil(t, err)
+	require.Equal(t, 3, len(tpdus))
+	m, err := sms.Decode(tpdus)
+	require.Nil(t, err)
+	assert.Equal(t, "onetwothree", string(m))
+	assert.Equal(t, "+61400000001", tpdus[1].OA.Number())
+
+	// complete messages and temporary files are removed
+	files, err = filepath.Glob(filepath.Join(dir, "*"))
+	require.Nil(t, err)
+	assert.Empty(t, files)
+	files, err = filepath.Glob(filepath.Join(dir, ".tmp-*"))
+	require.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestFileCollectorTimeout(t *testing.T) {
+	dir := t.TempDir()
+	expired := make(chan []*tpdu.TPDU, 1)
+	eh := func(tpdus []*tpdu.TPDU) { expired <- tpdus }
+	c, err := gsm.NewFileCollector(dir, gsm.WithFileReassemblyTimeout(50*time.Millisecond, eh))
+	require.Nil(t, err)
+	_, err = c.Collect(segment(1, 2, "one"))
+	require.Nil(t, err)
+	c.Close()
+
+	// the timer continues across the restart
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-expired:
+		t.Fatal("expired while closed")
+	default:
+	}
+	// already timed out, so expires once restored
+	c, err = gsm.NewFileCollector(dir, gsm.WithFileReassemblyTimeout(50*time.Millisecond, eh))
+	require.Nil(t, err)
+	defer c.Close()
+	select {
+	case tpdus := <-expired:
+		require.Equal(t, 2, len(tpdus))
+		require.NotNil(t, tpdus[0])
+		assert.Equal(t, tpdu.UserData("one"), tpdus[0].UD)
+		assert.Nil(t, tpdus[1])
+	case <-time.After(time.Second):
+		t.Fatal("not expired")
+	}
+	waitFor(t, func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		return len(files) == 0
+	})
+
+	// and the message is forgotten
+	tpdus, err := c.Collect(segment(2, 2, "two"))
+	require.Nil(t, err)
+	assert.Nil(t, tpdus)
+}
+
+func TestFileCollectorCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	c, err := gsm.NewFileCollector(dir)
+	require.Nil(t, err)
+	_, err = c.Collect(segment(1, 2, "one"))
+	require.Nil(t, err)
+	c.Close()
+	corrupt := filepath.Join(dir, "2b3a323a3432.json")
+	require.Nil(t, os.WriteFile(corrupt, []byte("junk"), 0o600))
+
+	// the corrupt file is reported and moved aside, and the rest restored
+	var errs []error
+	c, err = gsm.NewFileCollector(dir, gsm.WithFileErrorHandler(func(err error) { errs = append(errs, err) }))
+	require.Nil(t, err)
+	defer c.Close()
+	assert.Equal(t, 1, len(errs))
+	_, err = os.Stat(corrupt)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(corrupt + ".corrupt")
+	assert.Nil(t, err)
+	tpdus, err := c.Collect(segment(2, 2, "two"))
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(tpdus))
+
+	// and not restored again
+	c.Close()
+	errs = nil
+	c, err = gsm.NewFileCollector(dir, gsm.WithFileErrorHandler(func(err error) { errs = append(errs, err) }))
+	require.Nil(t, err)
+	defer c.Close()
+	assert.Empty(t, errs)
+}
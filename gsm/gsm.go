@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/warthog618/sms"
@@ -27,6 +28,10 @@ type GSM struct {
 	pduMode       bool
 	textualErrors bool
 	eOpts         []sms.EncoderOption
+
+	// srx is the stored message receiver, if reception was started with
+	// WithStoreAndNotify.
+	srx atomic.Pointer[storedRx]
 }
 
 // Option is a construction option for the GSM.
@@ -91,6 +96,9 @@ var WithPDUMode = pduModeOption(true)
 // WithTextMode specifies that the modem is to be used in text mode.
 //
 // This overrides is the default PDU mode.
+//
+// Text mode supports sending short messages and receiving messages, but not
+// sending long messages or PDUs.
 var WithTextMode = pduModeOption(false)
 
 type textualErrorsOption bool
@@ -136,7 +144,9 @@ func (o timeoutOption) applyRxOption(c *rxConfig) {
 //
 // The default is 24 hours.
 //
-// This option is overridden by WithCollector.
+// With WithCollector the timeout is not applied to the collector, which must
+// provide its own, but still determines when the stored segments of a message
+// received using WithStoreAndNotify are deleted.
 func WithReassemblyTimeout(d time.Duration) RxOption {
 	return timeoutOption(d)
 }
@@ -144,16 +154,38 @@ func WithReassemblyTimeout(d time.Duration) RxOption {
 type cmdsOption []string
 
 func (o cmdsOption) applyRxOption(c *rxConfig) {
-	c.initCmds = []string(o)
+	c.initCmds = append([]string{}, o...)
 }
 
 // WithInitCmds overrides the commands required to setup the modem to notify when SMSs are received.
 //
-// The default is {"+CSMS=1","+CNMI=1,2,0,0,0"}
+// The default is {"+CSMS=1","+CNMI=1,2,0,0,0"}, or {"+CNMI=1,1,0,0,0"} with
+// WithStoreAndNotify.
 func WithInitCmds(c ...string) RxOption {
 	return cmdsOption(c)
 }
 
+type storedOption bool
+
+func (o storedOption) applyRxOption(c *rxConfig) {
+	c.stored = bool(o)
+}
+
+// WithStoreAndNotify specifies that received messages are stored by the modem,
+// which notifies their arrival using +CMTI indications, rather than being
+// forwarded directly using +CMT indications.
+//
+// Each notified message is read from storage, collected, and deleted from
+// storage once the complete message has been passed to the message handler.
+// Messages already in storage when reception starts are also collected, so
+// messages are not lost while reception is stopped.
+// The stored segments of concatenated messages that are not reassembled within
+// the reassembly timeout are deleted.
+//
+// The storage selected for reading must be the storage in which received
+// messages are stored, as per SelectStorage.
+var WithStoreAndNotify = storedOption(true)
+
 // Init initialises the GSM modem.
 func (g *GSM) Init(options ...at.InitOption) (err error) {
 	if err = g.AT.Init(options...); err != nil {
@@ -203,10 +235,10 @@ func (g *GSM) Init(options ...at.InitOption) (err error) {
 //
 // The mr is returned on success, else an error.
 func (g *GSM) SendShortMessage(number string, message string, options ...at.CommandOption) (rsp string, err error) {
+	cfg := newSendConfig(options)
 	if g.pduMode {
 		var pdus []tpdu.TPDU
-		eOpts := append(g.eOpts, sms.To(number))
-		pdus, err = sms.Encode([]byte(message), eOpts...)
+		pdus, err = g.encode(number, []byte(message), cfg)
 		if err != nil {
 			return
 		}
@@ -221,6 +253,10 @@ func (g *GSM) SendShortMessage(number string, message string, options ...at.Comm
 		}
 		return g.SendPDU(tp, options...)
 	}
+	if cfg.pduOnly() {
+		err = ErrWrongMode
+		return
+	}
 	var i []string
 	i, err = g.SMSCommand("+CMGS=\""+number+"\"", message, options...)
 	if err != nil {
@@ -249,27 +285,11 @@ func (g *GSM) SendLongMessage(number string, message string, options ...at.Comma
 		return
 	}
 	var pdus []tpdu.TPDU
-	eOpts := append(g.eOpts, sms.To(number))
-	pdus, err = sms.Encode([]byte(message), eOpts...)
+	pdus, err = g.encode(number, []byte(message), newSendConfig(options))
 	if err != nil {
 		return
 	}
-	for _, p := range pdus {
-		var tp []byte
-		tp, err = p.MarshalBinary()
-		if err != nil {
-			return
-		}
-		var mr string
-		mr, err = g.SendPDU(tp, options...)
-		if len(mr) > 0 {
-			rsp = append(rsp, mr)
-		}
-		if err != nil {
-			return
-		}
-	}
-	return
+	return g.sendPDUs(pdus, options)
 }
 
 // SendPDU sends an SMS PDU.
@@ -312,6 +332,58 @@ type Message struct {
 	Message string
 	SCTS    tpdu.Timestamp
 	TPDUs   []*tpdu.TPDU
+
+	// Data is the raw payload of the message.
+	//
+	// This is the UTF-8 encoded text for text messages, or the binary data for
+	// 8-bit messages, which Message also contains but as a string.
+	Data []byte
+
+	// Ports are the application ports of port addressed messages, else nil.
+	Ports *Ports
+
+	// SMSC is the address of the SMSC that delivered the message, if known.
+	SMSC string
+
+	// Alphabet is the alphabet the message was encoded with.
+	Alphabet Alphabet
+
+	// Class is the message class, or ClassNone if none.
+	Class Class
+
+	// PID is the protocol identifier.
+	PID byte
+
+	// ConcatRef is the reference number of a concatenated message.
+	ConcatRef int
+
+	// Segments is the number of SMS PDUs the message was concatenated over,
+	// which is 1 for a single PDU.
+	Segments int
+
+	// StatusReportIndication indicates the sender requested a status report.
+	StatusReportIndication bool
+
+	// MWI is the message waiting indication carried by the message, else nil.
+	MWI *MessageWaiting
+
+	// Timestamp is the SCTS, in the time zone of the SMSC.
+	Timestamp time.Time
+
+	// Received is the local time the message was received and reassembled.
+	//
+	// This is only set for messages passed to the message handler.
+	Received time.Time
+
+	// Index is the index of the message in storage.
+	//
+	// This is only relevant for messages read from storage.
+	Index int
+
+	// Status is the status of the message in storage.
+	//
+	// This is only relevant for messages read from storage.
+	Status Status
 }
 
 // MessageHandler receives a decoded SMS message from the modem.
@@ -331,6 +403,24 @@ type rxConfig struct {
 	timeout  time.Duration
 	c        Collector
 	initCmds []string
+	stored   bool
+	tracker  *Tracker
+
+	// ah handles received messages, if set, in place of the MessageHandler.
+	ah AckHandler
+
+	// timing is when forwarded messages are acknowledged, if timingSet.
+	timing    AckTiming
+	timingSet bool
+
+	// dedupWindow and dedupStore configure the detection of duplicates, and
+	// dupErrors whether they are reported.
+	dedupWindow time.Duration
+	dedupStore  DedupStore
+	dupErrors   bool
+
+	// portHandlers contains the message handlers for destination ports.
+	portHandlers map[uint16]MessageHandler
 }
 
 // StartMessageRx sets up the modem to receive SMS messages and pass them to
@@ -342,63 +432,245 @@ type rxConfig struct {
 //
 // Errors detected while receiving messages are passed to the error handler.
 //
-// Requires the modem to be in PDU mode.
+// In text mode the full header of received messages is enabled using +CSDH=1,
+// and the message is decoded according to its DCS and the TE character set.
+// Concatenated messages can only be reassembled in text mode if the modem
+// presents them as hex encoded user data, including the user data header.
+// The WithStoreAndNotify and WithStatusReports options require PDU mode.
+//
+// Messages forwarded by +CMT indications are acknowledged with +CNMA, if the
+// message service selected by a +CSMS init command, else reported by +CSMS,
+// requires acknowledgement, as determined by WithAckTiming.
+// In PDU mode, messages that cannot be unmarshalled or collected, or
+// are rejected by an AckHandler, are rejected with +CNMA=2 so the SMSC will
+// retry later.
 func (g *GSM) StartMessageRx(mh MessageHandler, eh ErrorHandler, options ...RxOption) error {
-	if !g.pduMode {
-		return ErrWrongMode
-	}
 	cfg := rxConfig{
-		timeout:  24 * time.Hour,
-		initCmds: []string{"+CSMS=1", "+CNMI=1,2,0,0,0"},
+		timeout: 24 * time.Hour,
 	}
 	for _, option := range options {
 		option.applyRxOption(&cfg)
 	}
+	if !g.pduMode && (cfg.stored || cfg.tracker != nil) {
+		return ErrWrongMode
+	}
+	if cfg.initCmds == nil {
+		ds := 0
+		if cfg.tracker != nil {
+			ds = 1
+		}
+		cfg.initCmds = []string{"+CSMS=1", fmt.Sprintf("+CNMI=1,2,0,%d,0", ds)}
+		if cfg.stored {
+			cfg.initCmds = []string{fmt.Sprintf("+CNMI=1,1,0,%d,0", 2*ds)}
+		}
+		if !g.pduMode {
+			cfg.initCmds = append([]string{"+CSDH=1"}, cfg.initCmds...)
+		}
+	}
+	unmarshal := unmarshalPDU
+	if !g.pduMode {
+		cscs, err := g.charset()
+		if err != nil {
+			return err
+		}
+		unmarshal = func(info []string) (tpdu.TPDU, string, error) {
+			return unmarshalText(info, cscs)
+		}
+	}
+	var srx *storedRx
+	if cfg.stored {
+		srx = newStoredRx(g, eh, cfg.tracker, cfg.timeout)
+	}
 	if cfg.c == nil {
 		rto := func(tpdus []*tpdu.TPDU) {
 			eh(ErrReassemblyTimeout{tpdus})
 		}
 		cfg.c = sms.NewCollector(sms.WithReassemblyTimeout(cfg.timeout, rto))
 	}
-	cmtHandler := func(info []string) {
-		tp, err := UnmarshalTPDU(info)
-		if err != nil {
-			eh(ErrUnmarshal{info, err})
-			return
+	if !cfg.timingSet {
+		cfg.timing = AckOnCollect
+		if cfg.ah != nil {
+			cfg.timing = AckOnHandled
+		}
+	}
+	if cfg.ah == nil {
+		cfg.ah = func(msg Message) error {
+			mh(msg)
+			return nil
+		}
+	}
+	// reassemble returns the TPDUs of the reassembled message, and the message
+	// once complete, or an error if the TPDU is a duplicate, could not be
+	// collected, or the message could not be decoded.
+	// The smsc is the address of the SMSC that delivered the TPDU.
+	dd := newDedup(cfg, eh)
+	reassemble := func(tp tpdu.TPDU, smsc string) ([]*tpdu.TPDU, *Message, error) {
+		if dd.seen(&tp) {
+			err := ErrDuplicate{tp}
+			if cfg.dupErrors {
+				eh(err)
+			}
+			return nil, nil, err
 		}
-		g.Command("+CNMA")
 		tpdus, err := cfg.c.Collect(tp)
 		if err != nil {
-			eh(ErrCollect{tp, err})
-			return
+			err = ErrCollect{tp, err}
+			eh(err)
+			return nil, nil, err
 		}
 		if tpdus == nil {
-			return
+			return nil, nil, nil
 		}
 		m, err := sms.Decode(tpdus)
 		if err != nil {
-			eh(ErrDecode{tpdus, err})
+			err = ErrDecode{tpdus, err}
+			eh(err)
+			return tpdus, nil, err
+		}
+		if m == nil {
+			return tpdus, nil, nil
+		}
+		msg := newMessage(tpdus, m)
+		msg.SMSC = smsc
+		msg.Received = time.Now()
+		return tpdus, &msg, nil
+	}
+	// handle passes the message to the handler for its port, else the
+	// AckHandler, returning the AckHandler's verdict.
+	handle := func(msg Message) error {
+		h := cfg.ah
+		if msg.Ports != nil {
+			if ph, ok := cfg.portHandlers[msg.Ports.Dst]; ok {
+				h = func(msg Message) error {
+					ph(msg)
+					return nil
+				}
+			}
+		}
+		err := h(msg)
+		if err == nil {
+			dd.add(msg.TPDUs)
+		}
+		return err
+	}
+	// collect returns the TPDUs of the reassembled message, once complete and
+	// handled, false if the TPDU could not be collected or the message could
+	// not be decoded, and the error returned by the handler if it rejected the
+	// message.
+	collect := func(tp tpdu.TPDU, smsc string) ([]*tpdu.TPDU, bool, error) {
+		tpdus, msg, err := reassemble(tp, smsc)
+		if msg != nil {
+			return tpdus, true, handle(*msg)
 		}
-		if m != nil {
-			mh(Message{
-				Number:  tpdus[0].OA.Number(),
-				Message: string(m),
-				SCTS:    tpdus[0].SCTS,
-				TPDUs:   tpdus,
-			})
+		return tpdus, err == nil, nil
+	}
+	// prefixes contains the indications added, to be cancelled on error.
+	var prefixes []string
+	addIndication := func(prefix string, handler at.InfoHandler, options ...at.IndicationOption) error {
+		err := g.AddIndication(prefix, handler, options...)
+		if err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+		return err
+	}
+	// noAck is set if the modem does not require +CMT to be acknowledged,
+	// determined before the modem is setup to forward any.
+	noAck := srx == nil && !g.ackRequired(cfg.initCmds)
+	var err error
+	if srx != nil {
+		srx.collect = collect
+		err = addIndication("+CMTI:", srx.notify)
+		if err == nil && cfg.tracker != nil {
+			err = addIndication("+CDSI:", srx.notify)
+		}
+	} else {
+		ack := func(err error) {
+			if noAck {
+				return
+			}
+			if err := g.ack(err); err != nil {
+				eh(err)
+			}
+		}
+		cmtHandler := func(info []string) {
+			if cfg.timing == AckOnReceipt {
+				ack(nil)
+			}
+			tp, smsc, err := unmarshal(info)
+			if err != nil {
+				err = ErrUnmarshal{info, err}
+				eh(err)
+				if cfg.timing != AckOnReceipt {
+					ack(err)
+				}
+				return
+			}
+			_, msg, err := reassemble(tp, smsc)
+			switch e := err.(type) {
+			case ErrDuplicate:
+				// already handled, so just acknowledge
+				err = nil
+			case ErrCollect:
+				if errors.Is(e.Err, sms.ErrDuplicateSegment) {
+					// already collected, so just acknowledge
+					err = nil
+				}
+			case ErrDecode:
+				// the segments have been consumed by the collector, so a
+				// redelivery could not be decoded either - drop the message,
+				// as already reported, and acknowledge
+				err = nil
+			}
+			if cfg.timing == AckOnCollect || (cfg.timing == AckOnHandled && msg == nil) {
+				ack(err)
+			}
+			if msg == nil {
+				return
+			}
+			err = handle(*msg)
+			if cfg.timing == AckOnHandled {
+				ack(err)
+			}
+		}
+		err = addIndication("+CMT:", cmtHandler, at.WithTrailingLine)
+		if err == nil && cfg.tracker != nil {
+			cdsHandler := func(info []string) {
+				tp, err := UnmarshalTPDU(info)
+				if err != nil {
+					err = ErrUnmarshal{info, err}
+					eh(err)
+					ack(err)
+					return
+				}
+				ack(nil)
+				cfg.tracker.Report(&tp)
+			}
+			err = addIndication("+CDS:", cdsHandler, at.WithTrailingLine)
+		}
+	}
+	cancel := func() {
+		for _, prefix := range prefixes {
+			g.CancelIndication(prefix)
 		}
 	}
-	err := g.AddIndication("+CMT:", cmtHandler, at.WithTrailingLine)
 	if err != nil {
+		cancel()
 		return err
 	}
-	// tell the modem to forward SMS-DELIVERs via +CMT indications...
+	// tell the modem to forward SMS-DELIVERs via +CMT or +CMTI indications...
 	for _, cmd := range cfg.initCmds {
 		if _, err = g.Command(cmd); err != nil {
-			g.CancelIndication("+CMT:")
+			cancel()
 			return err
 		}
 	}
+	if srx != nil {
+		if old := g.srx.Swap(srx); old != nil {
+			old.stop()
+		}
+		// collect any messages received while not running
+		srx.sweep()
+	}
 	return nil
 }
 
@@ -408,17 +680,30 @@ func (g *GSM) StopMessageRx() {
 	g.Command("+CNMI=0,0,0,0,0")
 	// and detach the handler
 	g.CancelIndication("+CMT:")
+	g.CancelIndication("+CMTI:")
+	g.CancelIndication("+CDS:")
+	g.CancelIndication("+CDSI:")
+	if srx := g.srx.Swap(nil); srx != nil {
+		srx.stop()
+	}
 }
 
-// UnmarshalTPDU converts +CMT info into the corresponding SMS TPDU.
+// UnmarshalTPDU converts +CMT or +CDS info into the corresponding SMS TPDU.
 func UnmarshalTPDU(info []string) (tp tpdu.TPDU, err error) {
+	tp, _, err = unmarshalPDU(info)
+	return
+}
+
+// unmarshalPDU converts +CMT or +CDS info into the corresponding SMS TPDU, and
+// returns the SMSC address, if present in the PDU.
+func unmarshalPDU(info []string) (tp tpdu.TPDU, smsc string, err error) {
 	if len(info) < 2 {
 		err = ErrUnderlength
 		return
 	}
-	lstr := strings.Split(info[0], ",")
+	lstr := strings.Split(info[0][strings.Index(info[0], ":")+1:], ",")
 	var l int
-	l, err = strconv.Atoi(lstr[len(lstr)-1])
+	l, err = strconv.Atoi(strings.TrimSpace(lstr[len(lstr)-1]))
 	if err != nil {
 		return
 	}
@@ -432,6 +717,7 @@ func UnmarshalTPDU(info []string) (tp tpdu.TPDU, err error) {
 		return
 	}
 	err = tp.UnmarshalBinary(pdu.TPDU)
+	smsc = smscOf(pdu)
 	return
 }
 
@@ -493,6 +779,10 @@ func (e ErrUnmarshal) Error() string {
 }
 
 var (
+	// ErrIncompatibleAlphabet indicates the message cannot be encoded using
+	// the alphabet requested by WithAlphabet.
+	ErrIncompatibleAlphabet = errors.New("message incompatible with alphabet")
+
 	// ErrMalformedResponse indicates the modem returned a badly formed
 	// response.
 	ErrMalformedResponse = errors.New("modem returned malformed response")
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	"github.com/warthog618/modem/at"
 	"github.com/warthog618/modem/gsm"
 	"github.com/warthog618/modem/trace"
+	"github.com/warthog618/modem/transcript"
 )
 
 var debug = false // set to true to enable tracing of the flow to the mockModem.
@@ -565,7 +567,7 @@ func TestStartMessageRx(t *testing.T) {
 	}
 
 	// wrong mode
-	err := g.StartMessageRx(mh, eh)
+	err := g.StartMessageRx(mh, eh, gsm.WithStoreAndNotify)
 	require.Equal(t, gsm.ErrWrongMode, err)
 
 	g, mm = setupModem(t, cmdSet)
@@ -577,6 +579,9 @@ func TestStartMessageRx(t *testing.T) {
 
 	cmdSet["AT+CNMI=1,2,0,0,0\r\n"] = []string{"\r\nOK\r\n"}
 	cmdSet["AT+CSMS=1\r\n"] = []string{"\r\nOK\r\n"}
+	// messages that cannot be unmarshalled or collected are rejected
+	cmdSet["AT+CNMA=2,3\r"] = []string{"\n>"}
+	cmdSet["00ff00"+sub] = []string{"\r\n", "\r\nOK\r\n"}
 
 	// pass
 	err = g.StartMessageRx(mh, eh)
@@ -682,18 +687,8 @@ func TestStartMessageRxOptions(t *testing.T) {
 		"AT+CNMA\r\n":           {"\r\nOK\r\n"},
 	}
 
-	msgChan := make(chan gsm.Message, 3)
-	errChan := make(chan error, 3)
-	mh := func(msg gsm.Message) {
-		msgChan <- msg
-	}
-	eh := func(err error) {
-		errChan <- err
-	}
-
-	mc := mockCollector{
-		errChan: errChan,
-		err:     errors.New("mock collector expiry"),
+	mc := &mockCollector{
+		err: errors.New("mock collector expiry"),
 	}
 	sfs := tpdu.TPDU{
 		FirstOctet: tpdu.FoUDHI,
@@ -742,6 +737,15 @@ func TestStartMessageRxOptions(t *testing.T) {
 	}
 	for _, p := range patterns {
 		f := func(t *testing.T) {
+			msgChan := make(chan gsm.Message, 3)
+			errChan := make(chan error, 3)
+			mh := func(msg gsm.Message) {
+				msgChan <- msg
+			}
+			eh := func(err error) {
+				errChan <- err
+			}
+			mc.errChan = errChan
 			g, mm := setupModem(t, cmdSet)
 			defer teardownModem(mm)
 			err := g.StartMessageRx(mh, eh, p.options...)
@@ -817,6 +821,33 @@ func TestStopMessageRx(t *testing.T) {
 	}
 }
 
+func TestInitTranscript(t *testing.T) {
+	tr := transcript.New(t, "testdata/init.txt")
+	g := gsm.New(at.New(tr, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	assert.Nil(t, g.Init())
+}
+
+func TestMessageRxTranscript(t *testing.T) {
+	tr := transcript.New(t, "testdata/rx.txt")
+	g := gsm.New(at.New(tr, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	msgChan := make(chan gsm.Message, 1)
+	mh := func(msg gsm.Message) {
+		msgChan <- msg
+	}
+	eh := func(err error) {
+		t.Errorf("unexpected rx error: %v", err)
+	}
+	require.Nil(t, g.StartMessageRx(mh, eh))
+	select {
+	case msg := <-msgChan:
+		assert.Equal(t, "+21436587090", msg.Number)
+		assert.Equal(t, "Hello", msg.Message)
+	case <-time.After(100 * time.Millisecond):
+		t.Error("no message received")
+	}
+	g.StopMessageRx()
+}
+
 func TestUnmarshalTPDU(t *testing.T) {
 	patterns := []struct {
 		name string
@@ -977,8 +1008,10 @@ func TestErrors(t *testing.T) {
 type mockModem struct {
 	cmdSet    map[string][]string
 	echo      bool
-	closed    bool
 	readDelay time.Duration
+	// mu serialises Write and Close, and protects closed.
+	mu     sync.Mutex
+	closed bool
 	// The buffer emulating characters emitted by the modem.
 	r chan []byte
 }
@@ -997,6 +1030,8 @@ func (mm *mockModem) Read(p []byte) (n int, err error) {
 }
 
 func (mm *mockModem) Write(p []byte) (n int, err error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
 	if mm.closed {
 		return 0, at.ErrClosed
 	}
@@ -1018,6 +1053,8 @@ func (mm *mockModem) Write(p []byte) (n int, err error) {
 }
 
 func (mm *mockModem) Close() error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
 	if mm.closed == false {
 		mm.closed = true
 		close(mm.r)
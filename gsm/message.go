@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// ieSpecialSMS is the information element ID for the special SMS message
+// indication, as per 3GPP TS 23.040 9.2.3.24.2.
+const ieSpecialSMS = 0x01
+
+// Alphabet is the alphabet a message is encoded with, as per the DCS.
+type Alphabet int
+
+const (
+	// Alpha7Bit indicates the message is encoded using the GSM 7-bit default
+	// alphabet.
+	Alpha7Bit Alphabet = iota
+
+	// Alpha8Bit indicates the message is 8-bit data.
+	Alpha8Bit
+
+	// AlphaUCS2 indicates the message is encoded using UCS2.
+	AlphaUCS2
+)
+
+// alphabetOf returns the Alphabet corresponding to the TPDU alphabet.
+func alphabetOf(a tpdu.Alphabet) Alphabet {
+	switch a {
+	case tpdu.Alpha8Bit:
+		return Alpha8Bit
+	case tpdu.AlphaUCS2:
+		return AlphaUCS2
+	}
+	return Alpha7Bit
+}
+
+// tpduAlphabet returns the TPDU alphabet corresponding to the Alphabet.
+func (a Alphabet) tpduAlphabet() tpdu.Alphabet {
+	switch a {
+	case Alpha8Bit:
+		return tpdu.Alpha8Bit
+	case AlphaUCS2:
+		return tpdu.AlphaUCS2
+	}
+	return tpdu.Alpha7Bit
+}
+
+// Class is the class of a message, as per the DCS.
+type Class int
+
+const (
+	// ClassNone indicates the message has no class.
+	ClassNone Class = iota
+
+	// Class0 indicates a flash message, to be displayed immediately and not
+	// stored.
+	Class0
+
+	// Class1 indicates a message to be stored in the ME.
+	Class1
+
+	// Class2 indicates a SIM specific message.
+	Class2
+
+	// Class3 indicates a TE specific message.
+	Class3
+)
+
+// classOf returns the Class corresponding to the TPDU message class.
+func classOf(c tpdu.MessageClass) Class {
+	switch c {
+	case tpdu.MClass0:
+		return Class0
+	case tpdu.MClass1:
+		return Class1
+	case tpdu.MClass2:
+		return Class2
+	case tpdu.MClass3:
+		return Class3
+	}
+	return ClassNone
+}
+
+// messageClass returns the TPDU message class corresponding to the Class, and
+// false if the Class is ClassNone.
+func (c Class) messageClass() (tpdu.MessageClass, bool) {
+	switch c {
+	case Class0:
+		return tpdu.MClass0, true
+	case Class1:
+		return tpdu.MClass1, true
+	case Class2:
+		return tpdu.MClass2, true
+	case Class3:
+		return tpdu.MClass3, true
+	}
+	return tpdu.MClassUnknown, false
+}
+
+// MessageWaitingType is the type of message waiting.
+type MessageWaitingType int
+
+const (
+	// MWIVoicemail indicates voicemail messages are waiting.
+	MWIVoicemail MessageWaitingType = iota
+
+	// MWIFax indicates fax messages are waiting.
+	MWIFax
+
+	// MWIEmail indicates email messages are waiting.
+	MWIEmail
+
+	// MWIOther indicates other messages are waiting.
+	MWIOther
+)
+
+// MessageWaiting is a message waiting indication, as carried by the DCS or the
+// special SMS message indication in the UDH.
+type MessageWaiting struct {
+	// Type is the type of message waiting.
+	Type MessageWaitingType
+
+	// Active indicates messages are waiting, else the indication is cleared.
+	Active bool
+
+	// Count is the number of messages waiting, if provided by the UDH.
+	Count int
+}
+
+// mwiOf returns the message waiting indication carried by the TPDU, or nil if
+// none.
+func mwiOf(tp *tpdu.TPDU) *MessageWaiting {
+	if ie, ok := tp.UDH.IE(ieSpecialSMS); ok && len(ie.Data) == 2 {
+		return &MessageWaiting{
+			Type:   MessageWaitingType(ie.Data[0] & 0x03),
+			Active: ie.Data[1] > 0,
+			Count:  int(ie.Data[1]),
+		}
+	}
+	switch dcs := byte(tp.DCS); dcs & 0xf0 {
+	case 0xc0, 0xd0, 0xe0:
+		// message waiting indication groups
+		return &MessageWaiting{
+			Type:   MessageWaitingType(dcs & 0x03),
+			Active: dcs&0x08 != 0,
+		}
+	}
+	return nil
+}
+
+// smscOf returns the SMSC address in the PDU, or an empty string if none.
+func smscOf(pdu *pdumode.PDU) string {
+	if pdu.SMSC.Addr == "" {
+		return ""
+	}
+	return pdu.SMSC.Number()
+}
+
+// newMessage returns the Message for the TPDUs of a reassembled message and
+// its decoded payload.
+func newMessage(tpdus []*tpdu.TPDU, m []byte) Message {
+	tp := tpdus[0]
+	msg := Message{
+		Number:                 tp.OA.Number(),
+		Message:                string(m),
+		SCTS:                   tp.SCTS,
+		TPDUs:                  tpdus,
+		Data:                   m,
+		Ports:                  portsOf(tp.UDH),
+		PID:                    tp.PID,
+		Segments:               1,
+		StatusReportIndication: tp.FirstOctet.SRI(),
+		MWI:                    mwiOf(tp),
+		Timestamp:              tp.SCTS.Time,
+	}
+	alpha, _ := tp.Alphabet()
+	msg.Alphabet = alphabetOf(alpha)
+	class, _ := tp.DCS.Class()
+	msg.Class = classOf(class)
+	if segments, _, mref, ok := tp.ConcatInfo(); ok {
+		msg.Segments = segments
+		msg.ConcatRef = mref
+	}
+	return msg
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestMessageMetadata(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	g := networkGSM(t, n, "+61400000001")
+	r := networkGSM(t, n, "+61400000002")
+	msgs := make(chan gsm.Message, 1)
+	errs := make(chan error, 1)
+	err := r.StartMessageRx(
+		func(msg gsm.Message) { msgs <- msg },
+		func(err error) { errs <- err })
+	require.Nil(t, err)
+
+	before := time.Now()
+	long := strings.Repeat("0123456789", 40)
+	_, err = g.SendLongMessage("+61400000002", long,
+		gsm.WithClass(gsm.Class1), gsm.WithPID(0x41), gsm.WithStatusReport)
+	require.Nil(t, err)
+	var msg gsm.Message
+	select {
+	case msg = <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	assert.Equal(t, long, msg.Message)
+	assert.Equal(t, sim.DefaultSMSC, msg.SMSC)
+	assert.Equal(t, gsm.Alpha7Bit, msg.Alphabet)
+	assert.Equal(t, gsm.Class1, msg.Class)
+	assert.Equal(t, byte(0x41), msg.PID)
+	assert.Equal(t, 3, msg.Segments)
+	_, _, mref, _ := msg.TPDUs[0].ConcatInfo()
+	assert.Equal(t, mref, msg.ConcatRef)
+	assert.True(t, msg.StatusReportIndication)
+	assert.Nil(t, msg.MWI)
+	assert.True(t, msg.Timestamp.Equal(msg.SCTS.Time))
+	assert.Equal(t, msg.SCTS.Time.Location(), msg.Timestamp.Location())
+	assert.False(t, msg.Received.Before(before))
+	assert.False(t, msg.Received.After(time.Now()))
+
+	// defaults for a plain message
+	_, err = g.SendShortMessage("+61400000002", "hello")
+	require.Nil(t, err)
+	select {
+	case msg = <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	assert.Equal(t, gsm.ClassNone, msg.Class)
+	assert.Equal(t, 1, msg.Segments)
+	assert.Equal(t, 0, msg.ConcatRef)
+	assert.False(t, msg.StatusReportIndication)
+}
+
+func TestMessageWaiting(t *testing.T) {
+	patterns := []struct {
+		name string
+		dcs  byte
+		udh  tpdu.UserDataHeader
+		mwi  *gsm.MessageWaiting
+	}{
+		{"none", 0x00, nil, nil},
+		{"dcs voicemail", 0xc8, nil, &gsm.MessageWaiting{Type: gsm.MWIVoicemail, Active: true}},
+		{"dcs fax cleared", 0xd1, nil, &gsm.MessageWaiting{Type: gsm.MWIFax}},
+		{
+			"udh email",
+			0x00,
+			tpdu.UserDataHeader{{ID: 0x01, Data: []byte{0x02, 3}}},
+			&gsm.MessageWaiting{Type: gsm.MWIEmail, Active: true, Count: 3},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := sim.New(sim.WithSMSC("+61411000000"))
+			defer m.Close()
+			tp := tpdu.TPDU{
+				OA: tpdu.NewAddress(tpdu.FromNumber("+61400000001")),
+				UD: []byte("hello"),
+			}
+			tp.SetSmsType(tpdu.SmsDeliver)
+			tp.SetDCS(p.dcs)
+			if p.udh != nil {
+				tp.SetUDH(p.udh)
+			}
+			b, err := tp.MarshalBinary()
+			require.Nil(t, err)
+			_, err = m.StoreMessage("SM", sim.StatusRecUnread, b)
+			require.Nil(t, err)
+			g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+			msg, err := g.ReadMessage(1)
+			require.Nil(t, err)
+			assert.Equal(t, p.mwi, msg.MWI)
+			assert.Equal(t, "+61411000000", msg.SMSC)
+			assert.True(t, msg.Received.IsZero())
+		}
+		t.Run(p.name, f)
+	}
+}
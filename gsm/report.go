@@ -0,0 +1,375 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// WithStatusReport requests a status report for each TPDU sent, by setting
+// TP-SRR.
+//
+// The reports are returned by the modem once status reports are enabled by
+// StartMessageRx with the WithStatusReports option, and are correlated with
+// the sent message by a Tracker.
+var WithStatusReport = firstOctetOption(tpdu.FoSRR)
+
+type reportsOption struct {
+	t *Tracker
+}
+
+func (o reportsOption) applyRxOption(c *rxConfig) {
+	c.tracker = o.t
+}
+
+// WithStatusReports enables the reception of status reports, which are passed
+// to the Tracker.
+//
+// The default init commands are altered to enable status reports, i.e.
+// {"+CSMS=1","+CNMI=1,2,0,1,0"}, or {"+CNMI=1,1,0,2,0"} with
+// WithStoreAndNotify.
+func WithStatusReports(t *Tracker) RxOption {
+	return reportsOption{t}
+}
+
+// DeliveryStatus is the status of the delivery of a sent message.
+type DeliveryStatus int
+
+const (
+	// DeliveryPending indicates the message has not yet been delivered.
+	DeliveryPending DeliveryStatus = iota
+
+	// DeliveryDelivered indicates all the TPDUs of the message have been
+	// delivered.
+	DeliveryDelivered
+
+	// DeliveryFailed indicates a TPDU of the message could not be delivered
+	// and the SMSC has stopped trying to deliver it.
+	DeliveryFailed
+)
+
+var deliveryStatusText = []string{"pending", "delivered", "failed"}
+
+func (s DeliveryStatus) String() string {
+	if s < 0 || int(s) >= len(deliveryStatusText) {
+		return "unknown"
+	}
+	return deliveryStatusText[s]
+}
+
+// stStatus returns the DeliveryStatus corresponding to a TP-ST.
+//
+// The TP-ST ranges are defined in 3GPP TS 23.040 9.2.3.15.
+func stStatus(st byte) DeliveryStatus {
+	switch {
+	case st < 0x20:
+		// transaction completed
+		return DeliveryDelivered
+	case st < 0x40:
+		// temporary error, SC still trying to transfer
+		return DeliveryPending
+	}
+	// permanent error, or temporary error with SC no longer trying
+	return DeliveryFailed
+}
+
+// ErrDeliveryFailed indicates a TPDU of a sent message could not be delivered.
+type ErrDeliveryFailed struct {
+	// MR is the message reference of the TPDU.
+	MR int
+
+	// ST is the TP-ST returned in the status report.
+	ST byte
+}
+
+func (e ErrDeliveryFailed) Error() string {
+	return fmt.Sprintf("delivery of mr %d failed with status 0x%02x", e.MR, e.ST)
+}
+
+// Delivery tracks the delivery of a sent message, as reported by the status
+// reports for the TPDUs of the message.
+type Delivery struct {
+	mrs  []int
+	done chan struct{}
+
+	// mu protects the fields following.
+	mu      sync.Mutex
+	reports []*tpdu.TPDU
+	status  DeliveryStatus
+}
+
+// MRs returns the message references of the TPDUs of the message.
+func (d *Delivery) MRs() []int {
+	return append([]int{}, d.mrs...)
+}
+
+// Status returns the current delivery status of the message.
+//
+// A message is only delivered once all its TPDUs have been delivered, and has
+// failed once any of its TPDUs have failed.
+func (d *Delivery) Status() DeliveryStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// Reports returns the most recent status report for each TPDU of the message,
+// in the order of the MRs.
+//
+// The entries for TPDUs without a status report are nil.
+func (d *Delivery) Reports() []*tpdu.TPDU {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*tpdu.TPDU{}, d.reports...)
+}
+
+// Done returns a channel that is closed once the delivery has completed,
+// either delivered or failed.
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Wait waits for the delivery to complete.
+//
+// Returns nil if the message was delivered, an ErrDeliveryFailed if it
+// failed, or the context error if the context is done first.
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.status == DeliveryDelivered {
+		return nil
+	}
+	for i, r := range d.reports {
+		if r != nil && stStatus(r.ST) == DeliveryFailed {
+			return ErrDeliveryFailed{MR: d.mrs[i], ST: r.ST}
+		}
+	}
+	return nil
+}
+
+// update applies the status report for the TPDU with index seg, and returns
+// true if the delivery has completed.
+func (d *Delivery) update(seg int, sr *tpdu.TPDU) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.status != DeliveryPending {
+		return false
+	}
+	d.reports[seg] = sr
+	status := DeliveryDelivered
+	for _, r := range d.reports {
+		if r == nil {
+			status = DeliveryPending
+			continue
+		}
+		switch stStatus(r.ST) {
+		case DeliveryFailed:
+			d.status = DeliveryFailed
+			close(d.done)
+			return true
+		case DeliveryPending:
+			status = DeliveryPending
+		}
+	}
+	if status == DeliveryPending {
+		return false
+	}
+	d.status = status
+	close(d.done)
+	return true
+}
+
+// DeliveryHandler receives a Delivery once it has completed.
+type DeliveryHandler func(*Delivery)
+
+// Tracker correlates status reports with sent messages, using the message
+// references returned when the messages are sent.
+//
+// As message references are only 8 bits, deliveries should be completed or
+// untracked before the modem reuses the reference.
+type Tracker struct {
+	handler DeliveryHandler
+	hold    time.Duration
+
+	// mu protects the fields following.
+	mu sync.Mutex
+
+	// segments maps message references to the tracked deliveries.
+	segments map[int]segment
+
+	// orphans contains reports received before the corresponding message was
+	// tracked.
+	orphans map[int]orphan
+}
+
+// segment identifies a TPDU within a delivery.
+type segment struct {
+	d   *Delivery
+	idx int
+}
+
+// orphan is a status report that has not been correlated with a delivery.
+type orphan struct {
+	sr     *tpdu.TPDU
+	expiry time.Time
+}
+
+// TrackerOption is a construction option for a Tracker.
+type TrackerOption interface {
+	applyTrackerOption(*Tracker)
+}
+
+// NewTracker creates a Tracker.
+func NewTracker(options ...TrackerOption) *Tracker {
+	t := &Tracker{
+		hold:     time.Minute,
+		segments: make(map[int]segment),
+		orphans:  make(map[int]orphan),
+	}
+	for _, option := range options {
+		option.applyTrackerOption(t)
+	}
+	return t
+}
+
+type deliveryHandlerOption DeliveryHandler
+
+func (o deliveryHandlerOption) applyTrackerOption(t *Tracker) {
+	t.handler = DeliveryHandler(o)
+}
+
+// WithDeliveryHandler provides a handler that is called as each tracked
+// delivery completes.
+func WithDeliveryHandler(h DeliveryHandler) TrackerOption {
+	return deliveryHandlerOption(h)
+}
+
+type orphanHoldOption time.Duration
+
+func (o orphanHoldOption) applyTrackerOption(t *Tracker) {
+	t.hold = time.Duration(o)
+}
+
+// WithOrphanHold specifies how long status reports are held awaiting
+// correlation with a message that has not yet been tracked.
+//
+// This covers reports that arrive before Track is called for the message.
+//
+// The default is 1 minute.
+func WithOrphanHold(d time.Duration) TrackerOption {
+	return orphanHoldOption(d)
+}
+
+// Track starts tracking the delivery of a message sent as the TPDUs with the
+// message references, as returned by SendShortMessage, SendLongMessage or
+// SendPDU.
+//
+// Only the leading <mr> field of each reference is used, so any trailing
+// fields returned by the modem, such as the <scts>, are ignored.
+func (t *Tracker) Track(mrs ...string) (*Delivery, error) {
+	d := &Delivery{
+		mrs:     make([]int, len(mrs)),
+		reports: make([]*tpdu.TPDU, len(mrs)),
+		done:    make(chan struct{}),
+	}
+	for i, mr := range mrs {
+		// only the leading <mr> field, ignoring any trailing <scts> or <ackpdu>
+		if n := strings.Index(mr, ","); n >= 0 {
+			mr = mr[:n]
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(mr))
+		if err != nil {
+			return nil, err
+		}
+		d.mrs[i] = v
+	}
+	t.mu.Lock()
+	t.expire()
+	var reports []segment
+	var srs []*tpdu.TPDU
+	for i, mr := range d.mrs {
+		if o, ok := t.orphans[mr]; ok {
+			delete(t.orphans, mr)
+			reports = append(reports, segment{d, i})
+			srs = append(srs, o.sr)
+		}
+		t.segments[mr] = segment{d, i}
+	}
+	t.mu.Unlock()
+	for i, s := range reports {
+		t.apply(s, srs[i])
+	}
+	return d, nil
+}
+
+// Untrack stops tracking the delivery.
+func (t *Tracker) Untrack(d *Delivery) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, mr := range d.mrs {
+		if s, ok := t.segments[mr]; ok && s.d == d {
+			delete(t.segments, mr)
+		}
+	}
+}
+
+// Report applies a status report to the tracked delivery with the
+// corresponding message reference.
+//
+// Reports for untracked messages are held for correlation with messages
+// subsequently tracked.
+// TPDUs other than status reports are ignored.
+func (t *Tracker) Report(sr *tpdu.TPDU) {
+	if sr.SmsType() != tpdu.SmsStatusReport {
+		return
+	}
+	mr := int(sr.MR)
+	t.mu.Lock()
+	t.expire()
+	s, ok := t.segments[mr]
+	if !ok {
+		t.orphans[mr] = orphan{sr, time.Now().Add(t.hold)}
+	}
+	t.mu.Unlock()
+	if ok {
+		t.apply(s, sr)
+	}
+}
+
+// apply applies the report to the segment, and completes the delivery if
+// appropriate.
+func (t *Tracker) apply(s segment, sr *tpdu.TPDU) {
+	if !s.d.update(s.idx, sr) {
+		return
+	}
+	t.Untrack(s.d)
+	if t.handler != nil {
+		t.handler(s.d)
+	}
+}
+
+// expire discards orphaned reports that have expired.
+//
+// Must be called with the lock held.
+func (t *Tracker) expire() {
+	now := time.Now()
+	for mr, o := range t.orphans {
+		if now.After(o.expiry) {
+			delete(t.orphans, mr)
+		}
+	}
+}
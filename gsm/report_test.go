@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestTracker(t *testing.T) {
+	patterns := []struct {
+		name    string
+		mrs     []string
+		reports []byte // MR,ST pairs
+		status  gsm.DeliveryStatus
+		err     error
+	}{
+		{"single", []string{"1"}, []byte{1, 0x00}, gsm.DeliveryDelivered, nil},
+		{"long", []string{"1", "2", "3"}, []byte{3, 0x00, 1, 0x00, 2, 0x00}, gsm.DeliveryDelivered, nil},
+		{"long partial", []string{"1", "2"}, []byte{2, 0x00}, gsm.DeliveryPending, nil},
+		{"failed", []string{"1", "2"}, []byte{2, 0x41}, gsm.DeliveryFailed, gsm.ErrDeliveryFailed{MR: 2, ST: 0x41}},
+		{"temporary", []string{"1"}, []byte{1, 0x20}, gsm.DeliveryPending, nil},
+		{"temporary then delivered", []string{"1"}, []byte{1, 0x20, 1, 0x00}, gsm.DeliveryDelivered, nil},
+		{"untracked", []string{"1"}, []byte{2, 0x00}, gsm.DeliveryPending, nil},
+		{"suffixed", []string{`12,"26/10/18,09:30:00+40"`}, []byte{12, 0x00}, gsm.DeliveryDelivered, nil},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			tr := gsm.NewTracker()
+			d, err := tr.Track(p.mrs...)
+			require.Nil(t, err)
+			for i := 0; i < len(p.reports); i += 2 {
+				tr.Report(statusReport(p.reports[i], p.reports[i+1]))
+			}
+			assert.Equal(t, p.status, d.Status())
+			if p.status == gsm.DeliveryPending {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				assert.Equal(t, context.DeadlineExceeded, d.Wait(ctx))
+				return
+			}
+			assert.Equal(t, p.err, d.Wait(context.Background()))
+			reports := d.Reports()
+			require.Equal(t, len(p.mrs), len(reports))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTrackerOrphan(t *testing.T) {
+	tr := gsm.NewTracker()
+	tr.Report(statusReport(7, 0x00))
+	d, err := tr.Track("7")
+	require.Nil(t, err)
+	assert.Equal(t, gsm.DeliveryDelivered, d.Status())
+	assert.Equal(t, []int{7}, d.MRs())
+
+	// expired orphans are discarded
+	tr = gsm.NewTracker(gsm.WithOrphanHold(time.Millisecond))
+	tr.Report(statusReport(7, 0x00))
+	time.Sleep(5 * time.Millisecond)
+	d, err = tr.Track("7")
+	require.Nil(t, err)
+	assert.Equal(t, gsm.DeliveryPending, d.Status())
+}
+
+func TestTrackerHandler(t *testing.T) {
+	var done []*gsm.Delivery
+	tr := gsm.NewTracker(gsm.WithDeliveryHandler(func(d *gsm.Delivery) {
+		done = append(done, d)
+	}))
+	d, err := tr.Track("1", "2")
+	require.Nil(t, err)
+	tr.Report(statusReport(1, 0x00))
+	assert.Empty(t, done)
+	tr.Report(statusReport(2, 0x00))
+	assert.Equal(t, []*gsm.Delivery{d}, done)
+
+	// completed deliveries are no longer tracked
+	tr.Report(statusReport(2, 0x00))
+	assert.Equal(t, 1, len(done))
+
+	// nor are untracked deliveries
+	d, err = tr.Track("3")
+	require.Nil(t, err)
+	tr.Untrack(d)
+	tr.Report(statusReport(3, 0x00))
+	assert.Equal(t, gsm.DeliveryPending, d.Status())
+	assert.Equal(t, 1, len(done))
+
+	// nor are non-status reports
+	sr := statusReport(4, 0x00)
+	sr.SetSmsType(tpdu.SmsDeliver)
+	tr.Report(sr)
+
+	_, err = tr.Track("x")
+	assert.NotNil(t, err)
+}
+
+func TestDeliveryStatusString(t *testing.T) {
+	assert.Equal(t, "pending", gsm.DeliveryPending.String())
+	assert.Equal(t, "delivered", gsm.DeliveryDelivered.String())
+	assert.Equal(t, "failed", gsm.DeliveryFailed.String())
+	assert.Equal(t, "unknown", gsm.DeliveryStatus(-1).String())
+}
+
+func TestStatusReport(t *testing.T) {
+	long := strings.Repeat("0123456789", 40)
+	patterns := []struct {
+		name    string
+		stored  bool
+		to      string
+		message string
+		err     error
+	}{
+		{"direct", false, "+61400000002", "hello", nil},
+		{"direct long", false, "+61400000002", long, nil},
+		{"stored", true, "+61400000002", "hello", nil},
+		{"stored long", true, "+61400000002", long, nil},
+		{"unknown", false, "+61400000009", "hello", gsm.ErrDeliveryFailed{ST: 0x41}},
+		{"stored unknown", true, "+61400000009", "hello", gsm.ErrDeliveryFailed{ST: 0x41}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork()
+			defer n.Close()
+			g := networkGSM(t, n, "+61400000001")
+			networkGSM(t, n, "+61400000002")
+			tr := gsm.NewTracker()
+			errs := make(chan error, 2)
+			options := []gsm.RxOption{gsm.WithStatusReports(tr)}
+			if p.stored {
+				options = append(options, gsm.WithStoreAndNotify)
+			}
+			err := g.StartMessageRx(
+				func(msg gsm.Message) {},
+				func(err error) { errs <- err },
+				options...)
+			require.Nil(t, err)
+			mrs, err := g.SendLongMessage(p.to, p.message, gsm.WithStatusReport)
+			require.Nil(t, err)
+			d, err := tr.Track(mrs...)
+			require.Nil(t, err)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err = d.Wait(ctx)
+			if p.err != nil {
+				require.IsType(t, p.err, err)
+				assert.Equal(t, byte(0x41), err.(gsm.ErrDeliveryFailed).ST)
+				assert.Equal(t, gsm.DeliveryFailed, d.Status())
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, gsm.DeliveryDelivered, d.Status())
+			}
+			if p.stored {
+				waitEmpty(t, n.Modem("+61400000001"))
+			}
+			select {
+			case err := <-errs:
+				t.Errorf("unexpected error: %v", err)
+			default:
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestStatusReportMalformed(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	g := networkGSM(t, n, "+61400000001")
+	m := n.Modem("+61400000001")
+	errs := make(chan error, 1)
+	err := g.StartMessageRx(
+		func(msg gsm.Message) {},
+		func(err error) { errs <- err },
+		gsm.WithStatusReports(gsm.NewTracker()))
+	require.Nil(t, err)
+	// truncated SMS-STATUS-REPORT
+	require.Nil(t, m.DeliverReport([]byte{0x02}))
+	select {
+	case err := <-errs:
+		assert.IsType(t, gsm.ErrUnmarshal{}, err)
+	case <-time.After(time.Second):
+		t.Fatal("no error reported")
+	}
+	// rejected, as per malformed messages
+	waitFor(t, func() bool { return m.PendingAcks() == 0 })
+	assert.Equal(t, 0, m.Acks())
+	assert.Equal(t, []byte{0xff}, m.Nacks())
+}
+
+func TestStatusReportRequest(t *testing.T) {
+	n := sim.NewNetwork(sim.WithHold())
+	defer n.Close()
+	g := networkGSM(t, n, "+61400000001")
+	_, err := g.SendShortMessage("+61400000002", "hello", gsm.WithStatusReport)
+	require.Nil(t, err)
+	_, err = g.SendShortMessage("+61400000002", "hello")
+	require.Nil(t, err)
+	pending := n.Pending()
+	require.Equal(t, 2, len(pending))
+	assert.True(t, pending[0].SRR)
+	assert.False(t, pending[1].SRR)
+
+	g = gsm.New(at.New(sim.New(), at.WithTimeout(100*time.Millisecond)), gsm.WithTextMode)
+	_, err = g.SendShortMessage("+61400000002", "hello", gsm.WithStatusReport)
+	assert.Equal(t, gsm.ErrWrongMode, err)
+}
+
+// statusReport returns a status report with the MR and ST.
+func statusReport(mr, st byte) *tpdu.TPDU {
+	sr := &tpdu.TPDU{MR: mr, ST: st}
+	sr.SetSmsType(tpdu.SmsStatusReport)
+	return sr
+}
@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+
+	"github.com/warthog618/modem/at"
+)
+
+// SendOption is an option for SendShortMessage, SendLongMessage and
+// SendBinaryMessage.
+//
+// SendOptions are also CommandOptions, so may be passed alongside the
+// at.CommandOptions, but they have no effect on the AT command itself.
+//
+// SendOptions alter the SMS-SUBMIT TPDUs, so require the modem to be in PDU
+// mode.
+type SendOption interface {
+	at.CommandOption
+	applySendOption(*sendConfig)
+}
+
+type sendConfig struct {
+	// topts are applied to the template TPDU used to encode the message.
+	topts []tpdu.Option
+
+	// alphabet is the alphabet forced by WithAlphabet, else AlphaReserved.
+	alphabet tpdu.Alphabet
+}
+
+// newSendConfig returns the configuration set by the SendOptions in the
+// options.
+func newSendConfig(options []at.CommandOption) sendConfig {
+	cfg := sendConfig{alphabet: tpdu.AlphaReserved}
+	for _, option := range options {
+		if o, ok := option.(SendOption); ok {
+			o.applySendOption(&cfg)
+		}
+	}
+	return cfg
+}
+
+// pduOnly returns true if the configuration can only be applied in PDU mode.
+func (c sendConfig) pduOnly() bool {
+	return len(c.topts) > 0 || c.alphabet != tpdu.AlphaReserved
+}
+
+// encode encodes the message to the number into SMS-SUBMIT TPDUs.
+func (g *GSM) encode(number string, msg []byte, cfg sendConfig) ([]tpdu.TPDU, error) {
+	eOpts := append([]sms.EncoderOption{}, g.eOpts...)
+	eOpts = append(eOpts, sms.To(number))
+	for _, o := range cfg.topts {
+		eOpts = append(eOpts, sms.WithTemplateOption(o))
+	}
+	if cfg.alphabet == tpdu.AlphaUCS2 {
+		// the encoder expects UCS2 to be already encoded
+		msg = ucs2.Encode([]rune(string(msg)))
+	}
+	pdus, err := sms.Encode(msg, eOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.alphabet == tpdu.Alpha7Bit && len(pdus) > 0 {
+		// the encoder falls back to UCS2 if the message is not GSM7
+		if a, _ := pdus[0].Alphabet(); a != tpdu.Alpha7Bit {
+			return nil, ErrIncompatibleAlphabet
+		}
+	}
+	return pdus, nil
+}
+
+// sendPDUs sends the TPDUs, returning the mr of each TPDU sent.
+func (g *GSM) sendPDUs(pdus []tpdu.TPDU, options []at.CommandOption) (rsp []string, err error) {
+	for _, p := range pdus {
+		var tp []byte
+		tp, err = p.MarshalBinary()
+		if err != nil {
+			return
+		}
+		var mr string
+		mr, err = g.SendPDU(tp, options...)
+		if len(mr) > 0 {
+			rsp = append(rsp, mr)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// tpduOption is a SendOption that applies a tpdu.Option to the SMS-SUBMIT
+// TPDUs.
+type tpduOption struct {
+	at.NullOption
+	o tpdu.Option
+}
+
+func (o tpduOption) applySendOption(c *sendConfig) {
+	c.topts = append(c.topts, o.o)
+}
+
+// tpduOptionFunc adapts a function into a tpdu.Option.
+type tpduOptionFunc func(*tpdu.TPDU) error
+
+func (f tpduOptionFunc) ApplyTPDUOption(t *tpdu.TPDU) error {
+	return f(t)
+}
+
+// WithClass sets the message class in the DCS.
+//
+// Class 0 messages are flash messages, which are displayed immediately and
+// not stored by the recipient.
+// ClassNone leaves the class unset.
+func WithClass(c Class) SendOption {
+	f := func(t *tpdu.TPDU) error {
+		mc, ok := c.messageClass()
+		if !ok {
+			return nil
+		}
+		dcs, err := t.DCS.WithClass(mc)
+		if err != nil {
+			return err
+		}
+		t.SetDCS(byte(dcs))
+		return nil
+	}
+	return tpduOption{o: tpduOptionFunc(f)}
+}
+
+// WithFlash sends the message as a flash message, i.e. class 0.
+var WithFlash = WithClass(Class0)
+
+// WithValidityPeriod sets the relative validity period, the time the SMSC
+// holds the message awaiting delivery.
+func WithValidityPeriod(d time.Duration) SendOption {
+	f := func(t *tpdu.TPDU) error {
+		var vp tpdu.ValidityPeriod
+		vp.SetRelative(d)
+		t.SetVP(vp)
+		return nil
+	}
+	return tpduOption{o: tpduOptionFunc(f)}
+}
+
+// WithValidityUntil sets the absolute validity period, the time until which
+// the SMSC holds the message awaiting delivery.
+func WithValidityUntil(until time.Time) SendOption {
+	f := func(t *tpdu.TPDU) error {
+		var vp tpdu.ValidityPeriod
+		vp.SetAbsolute(tpdu.Timestamp{Time: until})
+		t.SetVP(vp)
+		return nil
+	}
+	return tpduOption{o: tpduOptionFunc(f)}
+}
+
+// WithPID sets the protocol identifier, e.g. 0x41 to replace a previous short
+// message of type 1 from the same sender.
+func WithPID(pid byte) SendOption {
+	f := func(t *tpdu.TPDU) error {
+		t.SetPID(pid)
+		return nil
+	}
+	return tpduOption{o: tpduOptionFunc(f)}
+}
+
+// WithRejectDuplicates sets TP-RD, requesting the SMSC reject the message if
+// it holds a message with the same MR and DA from the sender.
+var WithRejectDuplicates = firstOctetOption(tpdu.FoRD)
+
+// WithReplyPath sets TP-RP, requesting the recipient reply via the same SMSC.
+var WithReplyPath = firstOctetOption(tpdu.FoRP)
+
+// firstOctetOption sets the flags in the first octet.
+func firstOctetOption(flags tpdu.FirstOctet) SendOption {
+	f := func(t *tpdu.TPDU) error {
+		t.FirstOctet |= flags
+		return nil
+	}
+	return tpduOption{o: tpduOptionFunc(f)}
+}
+
+type alphabetOption struct {
+	at.NullOption
+	a tpdu.Alphabet
+}
+
+func (o alphabetOption) applySendOption(c *sendConfig) {
+	c.alphabet = o.a
+	c.topts = append(c.topts, o)
+}
+
+func (o alphabetOption) ApplyTPDUOption(t *tpdu.TPDU) error {
+	dcs, err := t.DCS.WithAlphabet(o.a)
+	if err != nil {
+		return err
+	}
+	t.SetDCS(byte(dcs))
+	return nil
+}
+
+// WithAlphabet forces the message to be encoded using the alphabet, rather
+// than GSM7 falling back to UCS2 if necessary.
+//
+// If the message cannot be encoded as GSM7 then ErrIncompatibleAlphabet is
+// returned.
+// With Alpha8Bit the message is sent as is, as binary data.
+func WithAlphabet(a Alphabet) SendOption {
+	return alphabetOption{a: a.tpduAlphabet()}
+}
@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestSendOptions(t *testing.T) {
+	until := time.Date(2026, time.October, 19, 9, 30, 0, 0, time.FixedZone("SCTS", 10*3600))
+	patterns := []struct {
+		name    string
+		message string
+		options []at.CommandOption
+		check   func(t *testing.T, tp *tpdu.TPDU)
+	}{
+		{
+			"none",
+			"hello",
+			nil,
+			func(t *testing.T, tp *tpdu.TPDU) {
+				assert.Equal(t, tpdu.DCS(0), tp.DCS)
+				assert.Equal(t, tpdu.VpfNotPresent, tp.FirstOctet.VPF())
+				assert.False(t, tp.FirstOctet.RD())
+				assert.False(t, tp.FirstOctet.RP())
+				assert.False(t, tp.FirstOctet.SRR())
+			},
+		},
+		{
+			"flash",
+			"hello",
+			[]at.CommandOption{gsm.WithFlash},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				c, err := tp.DCS.Class()
+				assert.Nil(t, err)
+				assert.Equal(t, tpdu.MClass0, c)
+			},
+		},
+		{
+			"class 2 ucs2",
+			"hello 😁",
+			[]at.CommandOption{gsm.WithClass(gsm.Class2)},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				c, _ := tp.DCS.Class()
+				assert.Equal(t, tpdu.MClass2, c)
+				a, _ := tp.Alphabet()
+				assert.Equal(t, tpdu.AlphaUCS2, a)
+			},
+		},
+		{
+			"class none",
+			"hello",
+			[]at.CommandOption{gsm.WithClass(gsm.ClassNone)},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				assert.Equal(t, tpdu.DCS(0), tp.DCS)
+			},
+		},
+		{
+			"relative validity",
+			"hello",
+			[]at.CommandOption{gsm.WithValidityPeriod(24 * time.Hour)},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				assert.Equal(t, tpdu.VpfRelative, tp.FirstOctet.VPF())
+				assert.Equal(t, 24*time.Hour, tp.VP.Duration)
+			},
+		},
+		{
+			"absolute validity",
+			"hello",
+			[]at.CommandOption{gsm.WithValidityUntil(until)},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				assert.Equal(t, tpdu.VpfAbsolute, tp.FirstOctet.VPF())
+				assert.True(t, until.Equal(tp.VP.Time.Time))
+			},
+		},
+		{
+			"pid",
+			"hello",
+			[]at.CommandOption{gsm.WithPID(0x41)},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				assert.Equal(t, byte(0x41), tp.PID)
+			},
+		},
+		{
+			"flags",
+			"hello",
+			[]at.CommandOption{gsm.WithRejectDuplicates, gsm.WithReplyPath, gsm.WithStatusReport},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				assert.True(t, tp.FirstOctet.RD())
+				assert.True(t, tp.FirstOctet.RP())
+				assert.True(t, tp.FirstOctet.SRR())
+			},
+		},
+		{
+			"ucs2",
+			"hello",
+			[]at.CommandOption{gsm.WithAlphabet(gsm.AlphaUCS2), gsm.WithFlash},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				a, _ := tp.Alphabet()
+				assert.Equal(t, tpdu.AlphaUCS2, a)
+				c, _ := tp.DCS.Class()
+				assert.Equal(t, tpdu.MClass0, c)
+				assert.Equal(t, []byte{0, 'h', 0, 'e', 0, 'l', 0, 'l', 0, 'o'}, []byte(tp.UD))
+			},
+		},
+		{
+			"8bit",
+			"\x01\x02",
+			[]at.CommandOption{gsm.WithAlphabet(gsm.Alpha8Bit)},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				a, _ := tp.Alphabet()
+				assert.Equal(t, tpdu.Alpha8Bit, a)
+				assert.Equal(t, []byte{1, 2}, []byte(tp.UD))
+			},
+		},
+		{
+			"gsm7",
+			"hello",
+			[]at.CommandOption{gsm.WithAlphabet(gsm.Alpha7Bit)},
+			func(t *testing.T, tp *tpdu.TPDU) {
+				a, _ := tp.Alphabet()
+				assert.Equal(t, tpdu.Alpha7Bit, a)
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m, g := sendModem(t)
+			defer m.Close()
+			_, err := g.SendShortMessage("+61400000002", p.message, p.options...)
+			require.Nil(t, err)
+			_, err = g.SendLongMessage("+61400000002", p.message, p.options...)
+			require.Nil(t, err)
+			sent := m.Sent()
+			require.Equal(t, 2, len(sent))
+			for _, s := range sent {
+				tp, err := sms.Unmarshal(s.TPDU, sms.AsMO)
+				require.Nil(t, err)
+				p.check(t, tp)
+			}
+			// options apply only to the call
+			_, err = g.SendShortMessage("+61400000002", "hello")
+			require.Nil(t, err)
+			tp, err := sms.Unmarshal(m.Sent()[2].TPDU, sms.AsMO)
+			require.Nil(t, err)
+			assert.Equal(t, tpdu.DCS(0), tp.DCS)
+			assert.Equal(t, byte(0), tp.PID)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestSendOptionsLong(t *testing.T) {
+	m, g := sendModem(t)
+	defer m.Close()
+	long := strings.Repeat("0123456789", 40)
+	mrs, err := g.SendLongMessage("+61400000002", long, gsm.WithPID(0x41), gsm.WithFlash)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(mrs))
+	for _, s := range m.Sent() {
+		tp, err := sms.Unmarshal(s.TPDU, sms.AsMO)
+		require.Nil(t, err)
+		assert.Equal(t, byte(0x41), tp.PID)
+		c, _ := tp.DCS.Class()
+		assert.Equal(t, tpdu.MClass0, c)
+		_, _, _, ok := tp.ConcatInfo()
+		assert.True(t, ok)
+	}
+}
+
+func TestSendOptionsErrors(t *testing.T) {
+	m, g := sendModem(t)
+	defer m.Close()
+	_, err := g.SendShortMessage("+61400000002", "hello 😁", gsm.WithAlphabet(gsm.Alpha7Bit))
+	assert.Equal(t, gsm.ErrIncompatibleAlphabet, err)
+	_, err = g.SendLongMessage("+61400000002", "hello 😁", gsm.WithAlphabet(gsm.Alpha7Bit))
+	assert.Equal(t, gsm.ErrIncompatibleAlphabet, err)
+	assert.Empty(t, m.Sent())
+
+	g = gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithTextMode)
+	options := []at.CommandOption{
+		gsm.WithFlash,
+		gsm.WithPID(0x41),
+		gsm.WithAlphabet(gsm.AlphaUCS2),
+		gsm.WithValidityPeriod(time.Hour),
+	}
+	for _, option := range options {
+		_, err = g.SendShortMessage("+61400000002", "hello", option)
+		assert.Equal(t, gsm.ErrWrongMode, err)
+	}
+	assert.Empty(t, m.Sent())
+}
+
+// sendModem returns a simulated modem and a GSM in PDU mode on that modem.
+func sendModem(t *testing.T) (*sim.Modem, *gsm.GSM) {
+	t.Helper()
+	m := sim.New()
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	require.Nil(t, g.Init())
+	return m, g
+}
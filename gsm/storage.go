@@ -0,0 +1,526 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/info"
+)
+
+// Status is the status of a message in storage.
+//
+// The values match those used by the modem in PDU mode.
+type Status int
+
+const (
+	// StatusRecUnread indicates a received message that has not been read.
+	StatusRecUnread Status = iota
+
+	// StatusRecRead indicates a received message that has been read.
+	StatusRecRead
+
+	// StatusStoUnsent indicates a stored message that has not been sent.
+	StatusStoUnsent
+
+	// StatusStoSent indicates a stored message that has been sent.
+	StatusStoSent
+
+	// StatusAll selects messages of any status when listing messages.
+	StatusAll
+)
+
+var statusText = []string{"REC UNREAD", "REC READ", "STO UNSENT", "STO SENT", "ALL"}
+
+// String returns the text mode name of the status, e.g. "REC UNREAD".
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusText) {
+		return "unknown"
+	}
+	return statusText[s]
+}
+
+// parseStatus returns the Status corresponding to a text mode status.
+func parseStatus(s string) (Status, error) {
+	for i, st := range statusText {
+		if strings.EqualFold(s, st) {
+			return Status(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status '%s'", s)
+}
+
+// DeleteFlag selects the messages deleted by DeleteAll.
+type DeleteFlag int
+
+const (
+	// DeleteRead deletes all read messages.
+	DeleteRead DeleteFlag = iota + 1
+
+	// DeleteReadAndSent deletes all read and sent messages.
+	DeleteReadAndSent
+
+	// DeleteReadSentAndUnsent deletes all read, sent and unsent messages.
+	DeleteReadSentAndUnsent
+
+	// DeleteEverything deletes all messages, including unread messages.
+	DeleteEverything
+)
+
+// Storage describes the usage of a message storage.
+type Storage struct {
+	// Mem is the name of the storage, e.g. "SM" or "ME".
+	//
+	// This is empty if the storage is not known.
+	Mem string
+
+	// Used is the number of messages in the storage.
+	Used int
+
+	// Total is the number of messages the storage can hold.
+	Total int
+}
+
+// ErrStored indicates a message in storage could not be decoded.
+//
+// The message remains in storage, so may be deleted using the Index.
+type ErrStored struct {
+	// Index is the location of the message in storage.
+	Index int
+
+	// Status is the status of the message, or -1 if not known.
+	Status Status
+
+	// Info contains the lines returned for the message, i.e. the header line
+	// followed by the PDU or text.
+	Info []string
+
+	// Err is the error that prevented the message being decoded.
+	Err error
+}
+
+func (e ErrStored) Error() string {
+	return fmt.Sprintf("error '%s' decoding stored message %d", e.Err, e.Index)
+}
+
+// ErrStoredMessages indicates that some of the messages returned by
+// ListMessages could not be decoded.
+type ErrStoredMessages struct {
+	Errs []ErrStored
+}
+
+func (e ErrStoredMessages) Error() string {
+	idxs := make([]string, len(e.Errs))
+	for n, err := range e.Errs {
+		idxs[n] = strconv.Itoa(err.Index)
+	}
+	return "error decoding stored messages " + strings.Join(idxs, ",")
+}
+
+// ListMessages returns the messages with the status from the storage selected
+// for reading.
+//
+// StatusAll returns all messages.
+//
+// Each message corresponds to one stored TPDU, so the segments of long
+// messages are returned as separate messages.
+//
+// If any of the messages cannot be decoded then the remaining messages are
+// still returned, along with an ErrStoredMessages identifying those that
+// could not.
+func (g *GSM) ListMessages(status Status, options ...at.CommandOption) ([]Message, error) {
+	arg := strconv.Itoa(int(status))
+	if !g.pduMode {
+		arg = `"` + status.String() + `"`
+	}
+	cscs := g.storedCharset()
+	i, err := g.Command("+CMGL="+arg, options...)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []Message
+	var errs []ErrStored
+	for n := 0; n < len(i); n++ {
+		// ignore any lines other than well-formed.
+		if !info.HasPrefix(i[n], "+CMGL") {
+			continue
+		}
+		end := n + 1
+		for end < len(i) && !info.HasPrefix(i[end], "+CMGL") {
+			end++
+		}
+		f := splitFields(info.TrimPrefix(i[n], "+CMGL"))
+		if len(f) < 2 {
+			return nil, ErrMalformedResponse
+		}
+		idx, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, ErrMalformedResponse
+		}
+		lines := i[n:end]
+		n = end - 1
+		msg, err := g.decodeStored(f[1:], lines, cscs)
+		if err != nil {
+			errs = append(errs, g.storedErr(idx, f[1], lines, err))
+			continue
+		}
+		msg.Index = idx
+		msgs = append(msgs, msg)
+	}
+	if len(errs) > 0 {
+		return msgs, ErrStoredMessages{errs}
+	}
+	return msgs, nil
+}
+
+// ReadMessage returns the message with the index from the storage selected
+// for reading.
+//
+// Reading a received unread message typically marks it read.
+func (g *GSM) ReadMessage(index int, options ...at.CommandOption) (Message, error) {
+	cscs := g.storedCharset()
+	i, err := g.Command("+CMGR="+strconv.Itoa(index), options...)
+	if err != nil {
+		return Message{}, err
+	}
+	for n, l := range i {
+		if info.HasPrefix(l, "+CMGR") {
+			msg, err := g.decodeStored(splitFields(info.TrimPrefix(l, "+CMGR")), i[n:], cscs)
+			if err != nil {
+				return Message{}, err
+			}
+			msg.Index = index
+			return msg, nil
+		}
+	}
+	return Message{}, ErrMalformedResponse
+}
+
+// DeleteMessage deletes the message with the index from the storage selected
+// for reading and deleting.
+func (g *GSM) DeleteMessage(index int, options ...at.CommandOption) error {
+	_, err := g.Command("+CMGD="+strconv.Itoa(index), options...)
+	return err
+}
+
+// DeleteAll deletes the messages selected by the flag from the storage
+// selected for reading and deleting.
+func (g *GSM) DeleteAll(flag DeleteFlag, options ...at.CommandOption) error {
+	// the index is ignored by the modem, but is required.
+	_, err := g.Command(fmt.Sprintf("+CMGD=1,%d", flag), options...)
+	return err
+}
+
+// SelectStorage selects the storage used for reading and deleting (mem1),
+// writing and sending (mem2), and storing received messages (mem3).
+//
+// The mem2 and mem3 may be empty to leave those selections unchanged.
+//
+// The usage of the selected storages is returned.
+// The Mem of storages left unchanged is empty.
+func (g *GSM) SelectStorage(mem1, mem2, mem3 string, options ...at.CommandOption) ([]Storage, error) {
+	mems := []string{mem1, mem2, mem3}
+	for len(mems) > 1 && mems[len(mems)-1] == "" {
+		mems = mems[:len(mems)-1]
+	}
+	args := make([]string, len(mems))
+	for n, mem := range mems {
+		args[n] = `"` + mem + `"`
+	}
+	i, err := g.Command("+CPMS="+strings.Join(args, ","), options...)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range i {
+		if !info.HasPrefix(l, "+CPMS") {
+			continue
+		}
+		f := splitFields(info.TrimPrefix(l, "+CPMS"))
+		if len(f)%2 != 0 {
+			return nil, ErrMalformedResponse
+		}
+		var s []Storage
+		for n := 0; n < len(f); n += 2 {
+			u, err := parseUsage(f[n : n+2])
+			if err != nil {
+				return nil, err
+			}
+			if n/2 < len(mems) {
+				u.Mem = mems[n/2]
+			}
+			s = append(s, u)
+		}
+		return s, nil
+	}
+	return nil, ErrMalformedResponse
+}
+
+// StorageUsage returns the currently selected storages and their usage, in
+// the order mem1, mem2, mem3, as per SelectStorage.
+func (g *GSM) StorageUsage(options ...at.CommandOption) ([]Storage, error) {
+	i, err := g.Command("+CPMS?", options...)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range i {
+		if !info.HasPrefix(l, "+CPMS") {
+			continue
+		}
+		f := splitFields(info.TrimPrefix(l, "+CPMS"))
+		if len(f)%3 != 0 {
+			return nil, ErrMalformedResponse
+		}
+		var s []Storage
+		for n := 0; n < len(f); n += 3 {
+			u, err := parseUsage(f[n+1 : n+3])
+			if err != nil {
+				return nil, err
+			}
+			u.Mem = f[n]
+			s = append(s, u)
+		}
+		return s, nil
+	}
+	return nil, ErrMalformedResponse
+}
+
+// parseUsage parses the used and total fields of a +CPMS response.
+func parseUsage(f []string) (Storage, error) {
+	used, err := strconv.Atoi(f[0])
+	if err != nil {
+		return Storage{}, ErrMalformedResponse
+	}
+	total, err := strconv.Atoi(f[1])
+	if err != nil {
+		return Storage{}, ErrMalformedResponse
+	}
+	return Storage{Used: used, Total: total}, nil
+}
+
+// storedCharset returns the TE character set used to present text mode
+// messages, or an empty string in PDU mode or if the character set is
+// unknown, in which case the text is returned as presented.
+func (g *GSM) storedCharset() string {
+	if g.pduMode {
+		return ""
+	}
+	cscs, _ := g.charset()
+	return cscs
+}
+
+// storedErr returns the ErrStored for a message that could not be decoded,
+// with the status taken from the stat header field, if valid.
+func (g *GSM) storedErr(idx int, stat string, lines []string, err error) ErrStored {
+	e := ErrStored{Index: idx, Status: -1, Info: lines, Err: err}
+	if g.pduMode {
+		if v, err := strconv.Atoi(stat); err == nil && v >= 0 && v < int(StatusAll) {
+			e.Status = Status(v)
+		}
+	} else if st, err := parseStatus(stat); err == nil {
+		e.Status = st
+	}
+	return e
+}
+
+// decodeStored decodes a message returned by +CMGR or +CMGL.
+//
+// f are the header fields, starting from the status, and lines are the
+// header line followed by the message lines.
+// The cscs is the TE character set used to present text mode messages.
+func (g *GSM) decodeStored(f []string, lines []string, cscs string) (Message, error) {
+	if g.pduMode {
+		return decodeStoredPDU(f, lines)
+	}
+	return decodeStoredText(f, lines, cscs)
+}
+
+// decodeStoredPDU decodes a message in PDU mode, where the header fields are
+// <stat>,[<alpha>],<length> and the PDU follows on the next line.
+func decodeStoredPDU(f []string, lines []string) (Message, error) {
+	stat, err := strconv.Atoi(f[0])
+	if err != nil || len(f) < 2 || len(lines) < 2 {
+		return Message{}, ErrMalformedResponse
+	}
+	l, err := strconv.Atoi(f[len(f)-1])
+	if err != nil {
+		return Message{}, ErrMalformedResponse
+	}
+	pdu, err := pdumode.UnmarshalHexString(lines[1])
+	if err != nil {
+		return Message{}, ErrUnmarshal{lines[:2], err}
+	}
+	if l != len(pdu.TPDU) {
+		err = fmt.Errorf("length mismatch - expected %d, got %d", l, len(pdu.TPDU))
+		return Message{}, ErrUnmarshal{lines[:2], err}
+	}
+	msg := Message{Status: Status(stat)}
+	dir := sms.AsMT
+	if msg.Status == StatusStoUnsent || msg.Status == StatusStoSent {
+		dir = sms.AsMO
+	}
+	tp, err := sms.Unmarshal(pdu.TPDU, dir)
+	if err != nil {
+		return Message{}, ErrUnmarshal{lines[:2], err}
+	}
+	tpdus := []*tpdu.TPDU{tp}
+	if tp.SmsType() == tpdu.SmsStatusReport {
+		// status reports have no message
+		msg.TPDUs = tpdus
+		msg.Number = tp.RA.Number()
+		msg.SCTS = tp.SCTS
+		return msg, nil
+	}
+	m, err := sms.Decode(tpdus)
+	if err != nil {
+		return Message{}, ErrDecode{tpdus, err}
+	}
+	status := msg.Status
+	msg = newMessage(tpdus, m)
+	msg.Status = status
+	msg.SMSC = smscOf(pdu)
+	if tp.SmsType() == tpdu.SmsSubmit {
+		msg.Number = tp.DA.Number()
+	}
+	return msg, nil
+}
+
+// decodeStoredText decodes a message in text mode, where the header fields are
+// <stat>,<oa/da>,[<alpha>],[<scts>],... and the text follows on subsequent
+// lines.
+//
+// If +CSDH=1 the +CMGR header includes the full set of fields, i.e.
+// `<stat>,<oa>,[<alpha>],<scts>,<tooa>,<fo>,<pid>,<dcs>,<sca>,<tosca>,<length>`
+// for received messages and
+// `<stat>,<da>,[<alpha>],<toda>,<fo>,<pid>,<dcs>,[<vp>],<sca>,<tosca>,<length>`
+// for stored messages, and the message is decoded as per the DCS.
+// Otherwise the text is assumed to be presented in the TE character set.
+func decodeStoredText(f []string, lines []string, cscs string) (Message, error) {
+	if len(f) < 2 {
+		return Message{}, ErrMalformedResponse
+	}
+	stat, err := parseStatus(f[0])
+	if err != nil {
+		return Message{}, ErrMalformedResponse
+	}
+	number, err := decodeTE(f[1], cscs)
+	if err != nil {
+		return Message{}, ErrUnmarshal{lines[:1], err}
+	}
+	body := strings.Join(lines[1:], "\n")
+	submit := stat == StatusStoUnsent || stat == StatusStoSent
+	var scts tpdu.Timestamp
+	if !submit && len(f) > 3 && f[3] != "" {
+		if scts, err = parseSCTS(f[3]); err != nil {
+			return Message{}, ErrMalformedResponse
+		}
+	}
+	if len(f) < 11 {
+		text, err := decodeTE(body, cscs)
+		if err != nil {
+			return Message{}, ErrUnmarshal{lines, err}
+		}
+		return Message{
+			Status:    stat,
+			Number:    number,
+			Message:   text,
+			SCTS:      scts,
+			Data:      []byte(text),
+			Timestamp: scts.Time,
+		}, nil
+	}
+	// the full header, with <fo> following <tooa> or <toda>
+	fo := 5
+	if submit {
+		fo = 4
+	}
+	var v [3]int
+	for n := range v {
+		if v[n], err = strconv.Atoi(f[fo+n]); err != nil {
+			return Message{}, ErrMalformedResponse
+		}
+	}
+	var tp tpdu.TPDU
+	tp.FirstOctet = tpdu.FirstOctet(v[0])
+	tp.PID = byte(v[1])
+	tp.DCS = tpdu.DCS(v[2])
+	tp.SCTS = scts
+	udl, err := strconv.Atoi(f[10])
+	if err != nil {
+		return Message{}, ErrMalformedResponse
+	}
+	addr := tpdu.NewAddress(tpdu.FromNumber(number))
+	if submit {
+		tp.Direction = tpdu.MO
+		tp.DA = addr
+	} else {
+		tp.OA = addr
+	}
+	if err = unmarshalTextUD(&tp, body, udl, cscs); err != nil {
+		return Message{}, ErrUnmarshal{lines, err}
+	}
+	tpdus := []*tpdu.TPDU{&tp}
+	m, err := sms.Decode(tpdus)
+	if err != nil {
+		return Message{}, ErrDecode{tpdus, err}
+	}
+	msg := newMessage(tpdus, m)
+	msg.Status = stat
+	msg.Number = number
+	if msg.SMSC, err = decodeTE(f[8], cscs); err != nil {
+		return Message{}, ErrUnmarshal{lines[:1], err}
+	}
+	return msg, nil
+}
+
+// parseSCTS parses a text mode timestamp, "yy/MM/dd,hh:mm:ss±zz", where zz is
+// the offset from UTC in quarter hours.
+func parseSCTS(s string) (tpdu.Timestamp, error) {
+	if len(s) != 20 || (s[17] != '+' && s[17] != '-') {
+		return tpdu.Timestamp{}, fmt.Errorf("invalid timestamp '%s'", s)
+	}
+	qh, err := strconv.Atoi(s[18:])
+	if err != nil {
+		return tpdu.Timestamp{}, err
+	}
+	offset := qh * 15 * 60
+	if s[17] == '-' {
+		offset = -offset
+	}
+	t, err := time.ParseInLocation("06/01/02,15:04:05", s[:17], time.FixedZone("SCTS", offset))
+	if err != nil {
+		return tpdu.Timestamp{}, err
+	}
+	return tpdu.Timestamp{Time: t}, nil
+}
+
+// splitFields splits the parameters of an info line into fields, removing the
+// quotes from quoted fields.
+//
+// Commas within quoted fields do not split the field.
+func splitFields(s string) []string {
+	var f []string
+	var sb strings.Builder
+	quoted := false
+	for _, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			f = append(f, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return append(f, strings.TrimSpace(sb.String()))
+}
@@ -0,0 +1,357 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+var scts = time.Date(2026, time.October, 18, 9, 30, 0, 0, time.FixedZone("SCTS", 10*3600))
+
+func TestListMessages(t *testing.T) {
+	modes := []struct {
+		name   string
+		cmgf   int
+		option gsm.Option
+	}{
+		{"pdu", 0, gsm.WithPDUMode},
+		{"text", 1, gsm.WithTextMode},
+	}
+	for _, mode := range modes {
+		f := func(t *testing.T) {
+			m, g := storageModem(t, mode.cmgf, mode.option)
+			defer m.Close()
+			msgs, err := g.ListMessages(gsm.StatusAll)
+			require.Nil(t, err)
+			require.Equal(t, 3, len(msgs))
+			assert.Equal(t, 1, msgs[0].Index)
+			assert.Equal(t, gsm.StatusRecUnread, msgs[0].Status)
+			assert.Equal(t, "+61400000001", msgs[0].Number)
+			assert.Equal(t, "unread", msgs[0].Message)
+			assert.True(t, scts.Equal(msgs[0].SCTS.Time))
+			assert.Equal(t, 2, msgs[1].Index)
+			assert.Equal(t, gsm.StatusRecRead, msgs[1].Status)
+			assert.Equal(t, "read", msgs[1].Message)
+			assert.Equal(t, 3, msgs[2].Index)
+			assert.Equal(t, gsm.StatusStoUnsent, msgs[2].Status)
+			assert.Equal(t, "+61400000002", msgs[2].Number)
+			assert.Equal(t, "draft", msgs[2].Message)
+			if mode.cmgf == 0 {
+				require.Equal(t, 1, len(msgs[0].TPDUs))
+				assert.Equal(t, tpdu.SmsDeliver, msgs[0].TPDUs[0].SmsType())
+				assert.Equal(t, tpdu.SmsSubmit, msgs[2].TPDUs[0].SmsType())
+			}
+
+			// listing marks unread messages read
+			msgs, err = g.ListMessages(gsm.StatusRecRead)
+			require.Nil(t, err)
+			assert.Equal(t, 2, len(msgs))
+
+			msgs, err = g.ListMessages(gsm.StatusRecUnread)
+			require.Nil(t, err)
+			assert.Empty(t, msgs)
+		}
+		t.Run(mode.name, f)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	modes := []struct {
+		name   string
+		cmgf   int
+		option gsm.Option
+	}{
+		{"pdu", 0, gsm.WithPDUMode},
+		{"text", 1, gsm.WithTextMode},
+	}
+	for _, mode := range modes {
+		f := func(t *testing.T) {
+			m, g := storageModem(t, mode.cmgf, mode.option)
+			defer m.Close()
+			msg, err := g.ReadMessage(1)
+			require.Nil(t, err)
+			assert.Equal(t, 1, msg.Index)
+			assert.Equal(t, gsm.StatusRecUnread, msg.Status)
+			assert.Equal(t, "+61400000001", msg.Number)
+			assert.Equal(t, "unread", msg.Message)
+			assert.True(t, scts.Equal(msg.SCTS.Time))
+
+			msg, err = g.ReadMessage(3)
+			require.Nil(t, err)
+			assert.Equal(t, gsm.StatusStoUnsent, msg.Status)
+			assert.Equal(t, "+61400000002", msg.Number)
+			assert.Equal(t, "draft", msg.Message)
+
+			_, err = g.ReadMessage(4)
+			assert.Equal(t, at.CMSError("321"), err)
+		}
+		t.Run(mode.name, f)
+	}
+}
+
+func TestStoredTextUCS2(t *testing.T) {
+	for _, csdh := range []int{0, 1} {
+		f := func(t *testing.T) {
+			m := sim.New(
+				sim.WithSMSC("+61411000000"),
+				sim.WithSettings(sim.Settings{CMGF: 1, CMEE: 1, CSCS: "UCS2", CSDH: csdh}))
+			defer m.Close()
+			for _, text := range []string{"héllo", "こんにちは"} {
+				pdus, err := sms.Encode([]byte(text), sms.AsDeliver, sms.From("+61400000001"))
+				require.Nil(t, err)
+				pdus[0].SCTS = tpdu.Timestamp{Time: scts}
+				b, err := pdus[0].MarshalBinary()
+				require.Nil(t, err)
+				_, err = m.StoreMessage("SM", sim.StatusRecUnread, b)
+				require.Nil(t, err)
+			}
+			pdus, err := sms.Encode([]byte("ドラフト"), sms.To("+61400000002"))
+			require.Nil(t, err)
+			b, err := pdus[0].MarshalBinary()
+			require.Nil(t, err)
+			_, err = m.StoreMessage("SM", sim.StatusStoUnsent, b)
+			require.Nil(t, err)
+			g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithTextMode)
+
+			msg, err := g.ReadMessage(1)
+			require.Nil(t, err)
+			assert.Equal(t, "+61400000001", msg.Number)
+			assert.Equal(t, "héllo", msg.Message)
+			assert.True(t, scts.Equal(msg.SCTS.Time))
+			msg, err = g.ReadMessage(2)
+			require.Nil(t, err)
+			assert.Equal(t, "こんにちは", msg.Message)
+			if csdh == 1 {
+				assert.Equal(t, gsm.AlphaUCS2, msg.Alphabet)
+				assert.Equal(t, "+61411000000", msg.SMSC)
+				require.Equal(t, 1, len(msg.TPDUs))
+				assert.Equal(t, tpdu.SmsDeliver, msg.TPDUs[0].SmsType())
+			}
+			msg, err = g.ReadMessage(3)
+			require.Nil(t, err)
+			assert.Equal(t, gsm.StatusStoUnsent, msg.Status)
+			assert.Equal(t, "+61400000002", msg.Number)
+			assert.Equal(t, "ドラフト", msg.Message)
+			if csdh == 1 {
+				require.Equal(t, 1, len(msg.TPDUs))
+				assert.Equal(t, tpdu.SmsSubmit, msg.TPDUs[0].SmsType())
+			}
+
+			msgs, err := g.ListMessages(gsm.StatusAll)
+			require.Nil(t, err)
+			require.Equal(t, 3, len(msgs))
+			assert.Equal(t, "+61400000001", msgs[0].Number)
+			assert.Equal(t, "héllo", msgs[0].Message)
+			assert.True(t, scts.Equal(msgs[0].SCTS.Time))
+			assert.Equal(t, "こんにちは", msgs[1].Message)
+			assert.Equal(t, "+61400000002", msgs[2].Number)
+			assert.Equal(t, "ドラフト", msgs[2].Message)
+		}
+		t.Run(fmt.Sprintf("csdh%d", csdh), f)
+	}
+}
+
+func TestStoredTextMultiLine(t *testing.T) {
+	for _, csdh := range []int{0, 1} {
+		f := func(t *testing.T) {
+			m := sim.New(sim.WithSettings(sim.Settings{CMGF: 1, CMEE: 1, CSCS: "IRA", CSDH: csdh}))
+			defer m.Close()
+			pdus, err := sms.Encode([]byte("first\nsecond"), sms.AsDeliver, sms.From("+61400000001"))
+			require.Nil(t, err)
+			b, err := pdus[0].MarshalBinary()
+			require.Nil(t, err)
+			_, err = m.StoreMessage("SM", sim.StatusRecUnread, b)
+			require.Nil(t, err)
+			g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithTextMode)
+
+			msg, err := g.ReadMessage(1)
+			require.Nil(t, err)
+			assert.Equal(t, "first\nsecond", msg.Message)
+		}
+		t.Run(fmt.Sprintf("csdh%d", csdh), f)
+	}
+}
+
+func TestListMessagesUndecodable(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{CMEE: 1}))
+	defer m.Close()
+	// UCS2 with a dangling surrogate
+	bad := tpdu.TPDU{
+		OA: tpdu.NewAddress(tpdu.FromNumber("+61400000001")),
+		UD: []byte{0xd8, 0x00},
+	}
+	bad.SetSmsType(tpdu.SmsDeliver)
+	bad.SetDCS(0x08)
+	b, err := bad.MarshalBinary()
+	require.Nil(t, err)
+	_, err = m.StoreMessage("SM", sim.StatusRecUnread, b)
+	require.Nil(t, err)
+	pdus, err := sms.Encode([]byte("good"), sms.AsDeliver, sms.From("+61400000001"))
+	require.Nil(t, err)
+	b, err = pdus[0].MarshalBinary()
+	require.Nil(t, err)
+	_, err = m.StoreMessage("SM", sim.StatusRecUnread, b)
+	require.Nil(t, err)
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+
+	msgs, err := g.ListMessages(gsm.StatusAll)
+	require.Equal(t, 1, len(msgs))
+	assert.Equal(t, 2, msgs[0].Index)
+	assert.Equal(t, "good", msgs[0].Message)
+	require.IsType(t, gsm.ErrStoredMessages{}, err)
+	errs := err.(gsm.ErrStoredMessages).Errs
+	require.Equal(t, 1, len(errs))
+	assert.Equal(t, 1, errs[0].Index)
+	assert.Equal(t, gsm.StatusRecUnread, errs[0].Status)
+	assert.Equal(t, 2, len(errs[0].Info))
+	assert.IsType(t, gsm.ErrDecode{}, errs[0].Err)
+
+	// the bad message can still be deleted
+	require.Nil(t, g.DeleteMessage(errs[0].Index))
+	msgs, err = g.ListMessages(gsm.StatusAll)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(msgs))
+}
+
+func TestDeleteMessage(t *testing.T) {
+	m, g := storageModem(t, 0, gsm.WithPDUMode)
+	defer m.Close()
+	require.Nil(t, g.DeleteMessage(2))
+	assert.Equal(t, 2, len(m.Messages("SM")))
+	assert.Equal(t, at.CMSError("321"), g.DeleteMessage(2))
+}
+
+func TestDeleteAll(t *testing.T) {
+	patterns := []struct {
+		name      string
+		flag      gsm.DeleteFlag
+		remaining int
+	}{
+		{"read", gsm.DeleteRead, 2},
+		{"read and sent", gsm.DeleteReadAndSent, 2},
+		{"read sent and unsent", gsm.DeleteReadSentAndUnsent, 1},
+		{"everything", gsm.DeleteEverything, 0},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m, g := storageModem(t, 0, gsm.WithPDUMode)
+			defer m.Close()
+			require.Nil(t, g.DeleteAll(p.flag))
+			assert.Equal(t, p.remaining, len(m.Messages("SM")))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestSelectStorage(t *testing.T) {
+	m, g := storageModem(t, 0, gsm.WithPDUMode)
+	defer m.Close()
+	s, err := g.SelectStorage("ME", "", "")
+	require.Nil(t, err)
+	require.Equal(t, 3, len(s))
+	assert.Equal(t, gsm.Storage{Mem: "ME", Used: 0, Total: s[0].Total}, s[0])
+	assert.Equal(t, gsm.Storage{Used: 3, Total: s[1].Total}, s[1])
+	assert.True(t, s[0].Total > 0)
+
+	msgs, err := g.ListMessages(gsm.StatusAll)
+	require.Nil(t, err)
+	assert.Empty(t, msgs)
+
+	s, err = g.SelectStorage("SM", "ME", "ME")
+	require.Nil(t, err)
+	require.Equal(t, 3, len(s))
+	assert.Equal(t, "SM", s[0].Mem)
+	assert.Equal(t, 3, s[0].Used)
+	assert.Equal(t, "ME", s[2].Mem)
+
+	s, err = g.StorageUsage()
+	require.Nil(t, err)
+	require.Equal(t, 3, len(s))
+	assert.Equal(t, "SM", s[0].Mem)
+	assert.Equal(t, 3, s[0].Used)
+	assert.Equal(t, "ME", s[1].Mem)
+	assert.Equal(t, 0, s[1].Used)
+
+	_, err = g.SelectStorage("XX", "", "")
+	assert.Equal(t, at.CMSError("302"), err)
+}
+
+func TestStorageMalformed(t *testing.T) {
+	cmdSet := map[string][]string{
+		"AT+CMGL=4\r\n":      {"\r\n+CMGL: x,0,,24\r\n", "00\r\n", "\r\nOK\r\n"},
+		"AT+CMGR=1\r\n":      {"\r\n+CMGR: 0,,24\r\n", "\r\nOK\r\n"},
+		"AT+CMGR=2\r\n":      {"\r\n+CMGR: 0,,3\r\n", "00ZZ\r\n", "\r\nOK\r\n"},
+		"AT+CMGR=3\r\n":      {"\r\n+CMGR: 0,,3\r\n", "00040000\r\n", "\r\nOK\r\n"},
+		"AT+CMGR=4\r\n":      {"\r\nOK\r\n"},
+		"AT+CPMS?\r\n":       {"\r\n+CPMS: \"SM\",1\r\n", "\r\nOK\r\n"},
+		"AT+CPMS=\"SM\"\r\n": {"\r\n+CPMS: 1,x\r\n", "\r\nOK\r\n"},
+	}
+	g, mm := setupModem(t, cmdSet)
+	defer teardownModem(mm)
+
+	_, err := g.ListMessages(gsm.StatusAll)
+	assert.Equal(t, gsm.ErrMalformedResponse, err)
+	_, err = g.ReadMessage(1)
+	assert.Equal(t, gsm.ErrMalformedResponse, err)
+	_, err = g.ReadMessage(2)
+	_, ok := err.(gsm.ErrUnmarshal)
+	assert.True(t, ok, err)
+	_, err = g.ReadMessage(3)
+	_, ok = err.(gsm.ErrUnmarshal)
+	assert.True(t, ok, err)
+	_, err = g.ReadMessage(4)
+	assert.Equal(t, gsm.ErrMalformedResponse, err)
+	_, err = g.StorageUsage()
+	assert.Equal(t, gsm.ErrMalformedResponse, err)
+	_, err = g.SelectStorage("SM", "", "")
+	assert.Equal(t, gsm.ErrMalformedResponse, err)
+}
+
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "REC UNREAD", gsm.StatusRecUnread.String())
+	assert.Equal(t, "STO SENT", gsm.StatusStoSent.String())
+	assert.Equal(t, "ALL", gsm.StatusAll.String())
+	assert.Equal(t, "unknown", gsm.Status(-1).String())
+}
+
+// storageModem returns a simulated modem with the SIM storage containing an
+// unread message, a read message and an unsent message, and a GSM in the
+// corresponding mode on that modem.
+func storageModem(t *testing.T, cmgf int, option gsm.Option) (*sim.Modem, *gsm.GSM) {
+	t.Helper()
+	m := sim.New(sim.WithSettings(sim.Settings{CMGF: cmgf, CMEE: 1}))
+	store := func(status sim.Status, tp tpdu.TPDU) {
+		b, err := tp.MarshalBinary()
+		require.Nil(t, err)
+		_, err = m.StoreMessage("SM", status, b)
+		require.Nil(t, err)
+	}
+	for _, text := range []string{"unread", "read"} {
+		pdus, err := sms.Encode([]byte(text), sms.AsDeliver, sms.From("+61400000001"))
+		require.Nil(t, err)
+		pdus[0].SCTS = tpdu.Timestamp{Time: scts}
+		status := sim.StatusRecUnread
+		if text == "read" {
+			status = sim.StatusRecRead
+		}
+		store(status, pdus[0])
+	}
+	pdus, err := sms.Encode([]byte("draft"), sms.To("+61400000002"))
+	require.Nil(t, err)
+	store(sim.StatusStoUnsent, pdus[0])
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), option)
+	return m, g
+}
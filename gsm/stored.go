@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// storedRx collects received messages from storage, as notified by +CMTI
+// indications, and status reports, as notified by +CDSI indications.
+type storedRx struct {
+	g  *GSM
+	eh ErrorHandler
+
+	// tracker receives stored status reports, if status reports are enabled.
+	tracker *Tracker
+
+	// timeout is the time allowed for all segments of a concatenated message
+	// to be received before those stored are deleted.
+	timeout time.Duration
+
+	// collect collects the TPDU, delivered by the SMSC, returning the TPDUs of
+	// the complete message once passed to the message handler, false if the
+	// TPDU could not be handled, and the error returned by the handler if it
+	// rejected the message.
+	collect func(tp tpdu.TPDU, smsc string) ([]*tpdu.TPDU, bool, error)
+
+	// mu serialises the processing of stored messages, and protects the
+	// fields following.
+	mu sync.Mutex
+
+	// segments contains the storage indices of the segments of partially
+	// collected concatenated messages.
+	segments map[segmentKey][]int
+
+	// timers contains the reassembly timers of partially collected
+	// concatenated messages.
+	timers map[segmentKey]*time.Timer
+
+	// swept contains the storage indices of the messages collected by the
+	// sweep, which may also be notified.
+	swept map[int]bool
+
+	// stopped is set once reception has been stopped.
+	stopped bool
+}
+
+// segmentKey identifies a concatenated message.
+type segmentKey struct {
+	number   string
+	mref     int
+	segments int
+}
+
+func newStoredRx(g *GSM, eh ErrorHandler, t *Tracker, timeout time.Duration) *storedRx {
+	return &storedRx{
+		g:        g,
+		eh:       eh,
+		tracker:  t,
+		timeout:  timeout,
+		segments: make(map[segmentKey][]int),
+		timers:   make(map[segmentKey]*time.Timer),
+		swept:    make(map[int]bool),
+	}
+}
+
+// notify is the handler for +CMTI and +CDSI indications, e.g.
+// `+CMTI: "SM",3`.
+func (r *storedRx) notify(i []string) {
+	f := splitFields(i[0][strings.Index(i[0], ":")+1:])
+	idx, err := strconv.Atoi(f[len(f)-1])
+	if err != nil || len(f) < 2 {
+		r.eh(ErrUnmarshal{i, ErrMalformedResponse})
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	msg, err := r.g.ReadMessage(idx)
+	if err != nil {
+		swept := r.swept[idx]
+		delete(r.swept, idx)
+		if undecodable(err) {
+			// present but cannot be collected, so would otherwise remain in
+			// storage indefinitely
+			r.eh(ErrStored{Index: idx, Status: -1, Err: err})
+			r.delete(idx)
+			return
+		}
+		if !swept {
+			// else already collected and deleted by the sweep
+			r.eh(err)
+		}
+		return
+	}
+	delete(r.swept, idx)
+	r.process(msg)
+}
+
+// sweep collects any received messages in storage.
+//
+// Received messages that cannot be decoded are reported and deleted, so they
+// do not block subsequent sweeps.
+func (r *storedRx) sweep() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msgs, err := r.g.ListMessages(StatusAll)
+	if err != nil {
+		se, ok := err.(ErrStoredMessages)
+		if !ok {
+			r.eh(err)
+			return
+		}
+		for _, e := range se.Errs {
+			r.eh(e)
+			if e.Status == StatusRecUnread || e.Status == StatusRecRead {
+				r.swept[e.Index] = true
+				r.delete(e.Index)
+			}
+		}
+	}
+	for _, msg := range msgs {
+		if msg.Status != StatusRecUnread && msg.Status != StatusRecRead {
+			continue
+		}
+		r.swept[msg.Index] = true
+		r.process(msg)
+	}
+}
+
+// process collects the message, and deletes it from storage once it has been
+// handled.
+//
+// The segments of concatenated messages are retained in storage until the
+// complete message has been handled, or deleted if the message is not
+// reassembled within the reassembly timeout.
+// Messages rejected by the handler are retained in storage, to be collected
+// again by the next sweep.
+//
+// Must be called with the lock held.
+func (r *storedRx) process(msg Message) {
+	tp := msg.TPDUs[0]
+	if tp.SmsType() == tpdu.SmsStatusReport {
+		if r.tracker == nil {
+			// leave for the user
+			return
+		}
+		r.tracker.Report(tp)
+		r.delete(msg.Index)
+		return
+	}
+	segments, _, mref, ok := tp.ConcatInfo()
+	if !ok || segments < 2 {
+		if _, _, err := r.collect(*tp, msg.SMSC); err != nil {
+			// rejected by the handler
+			return
+		}
+		r.delete(msg.Index)
+		return
+	}
+	key := segmentKey{tp.OA.Number(), mref, segments}
+	tpdus, ok, err := r.collect(*tp, msg.SMSC)
+	if err != nil {
+		// rejected by the handler, so retain the segments, which the next
+		// sweep will collect again
+		r.forget(key)
+		return
+	}
+	if tpdus == nil {
+		if ok {
+			r.segments[key] = append(r.segments[key], msg.Index)
+			if r.timers[key] == nil {
+				var t *time.Timer
+				t = time.AfterFunc(r.timeout, func() {
+					r.mu.Lock()
+					defer r.mu.Unlock()
+					if r.timers[key] != t {
+						// already completed or stopped
+						return
+					}
+					r.discard(key)
+				})
+				r.timers[key] = t
+			}
+		} else {
+			// rejected by the collector, e.g. a duplicate segment
+			r.delete(msg.Index)
+		}
+		return
+	}
+	r.delete(msg.Index)
+	r.discard(key)
+}
+
+// discard deletes the stored segments of a concatenated message.
+//
+// Must be called with the lock held.
+func (r *storedRx) discard(key segmentKey) {
+	for _, idx := range r.segments[key] {
+		r.delete(idx)
+	}
+	r.forget(key)
+}
+
+// forget stops tracking the stored segments of a concatenated message.
+//
+// Must be called with the lock held.
+func (r *storedRx) forget(key segmentKey) {
+	if t := r.timers[key]; t != nil {
+		t.Stop()
+	}
+	delete(r.timers, key)
+	delete(r.segments, key)
+}
+
+// stop stops the reassembly timers, leaving any stored segments in storage to
+// be collected when reception is restarted.
+func (r *storedRx) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	for key := range r.segments {
+		r.forget(key)
+	}
+}
+
+// undecodable returns true if the error indicates a stored message could be
+// read but not decoded, rather than that the read failed.
+func undecodable(err error) bool {
+	switch err.(type) {
+	case ErrUnmarshal, ErrDecode:
+		return true
+	}
+	return err == ErrMalformedResponse
+}
+
+// delete deletes the message from storage.
+//
+// Must be called with the lock held.
+func (r *storedRx) delete(idx int) {
+	if err := r.g.DeleteMessage(idx); err != nil {
+		r.eh(err)
+	}
+}
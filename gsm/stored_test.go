@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+	"github.com/warthog618/modem/transcript"
+)
+
+func TestStoreAndNotify(t *testing.T) {
+	long := strings.Repeat("0123456789", 40)
+	patterns := []struct {
+		name    string
+		message string
+		order   []int
+	}{
+		{"short", "hello", nil},
+		{"long", long, nil},
+		{"long reversed", long, []int{2, 1, 0}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork(sim.WithHold())
+			defer n.Close()
+			a := networkGSM(t, n, "+61400000001")
+			b := networkGSM(t, n, "+61400000002")
+			msgs, errs := storedRx(t, b)
+
+			_, err := a.SendLongMessage("+61400000002", p.message)
+			require.Nil(t, err)
+			n.Release(p.order...)
+			select {
+			case msg := <-msgs:
+				assert.Equal(t, "+61400000001", msg.Number)
+				assert.Equal(t, p.message, msg.Message)
+			case err := <-errs:
+				t.Errorf("unexpected rx error: %v", err)
+			case <-time.After(time.Second):
+				t.Error("no message received")
+			}
+			waitEmpty(t, n.Modem("+61400000002"))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestStoreAndNotifySweep(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	m := n.Attach("+61400000002")
+	pdus, err := sms.Encode([]byte(strings.Repeat("0123456789", 20)), sms.AsDeliver, sms.From("+61400000001"))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(pdus))
+	for _, p := range pdus {
+		tp, err := p.MarshalBinary()
+		require.Nil(t, err)
+		_, err = m.StoreMessage("SM", sim.StatusRecUnread, tp)
+		require.Nil(t, err)
+	}
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	require.Nil(t, g.Init())
+	msgs, _ := storedRx(t, g)
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, strings.Repeat("0123456789", 20), msg.Message)
+	case <-time.After(time.Second):
+		t.Error("no message received")
+	}
+	waitEmpty(t, m)
+}
+
+func TestStoreAndNotifyLostSegment(t *testing.T) {
+	n := sim.NewNetwork(sim.WithHold())
+	defer n.Close()
+	a := networkGSM(t, n, "+61400000001")
+	b := networkGSM(t, n, "+61400000002")
+	errs := make(chan error, 1)
+	err := b.StartMessageRx(
+		func(msg gsm.Message) { t.Errorf("unexpected message: %v", msg) },
+		func(err error) { errs <- err },
+		gsm.WithStoreAndNotify,
+		gsm.WithReassemblyTimeout(50*time.Millisecond))
+	require.Nil(t, err)
+
+	_, err = a.SendLongMessage("+61400000002", strings.Repeat("0123456789", 40))
+	require.Nil(t, err)
+	n.Discard(1)
+	n.Release()
+	select {
+	case err := <-errs:
+		_, ok := err.(gsm.ErrReassemblyTimeout)
+		assert.True(t, ok, err)
+	case <-time.After(time.Second):
+		t.Error("no reassembly timeout")
+	}
+	waitEmpty(t, n.Modem("+61400000002"))
+}
+
+func TestStoreAndNotifyLostSegmentCollector(t *testing.T) {
+	n := sim.NewNetwork(sim.WithHold())
+	defer n.Close()
+	a := networkGSM(t, n, "+61400000001")
+	b := networkGSM(t, n, "+61400000002")
+	// a collector that never times out, so the stored segments must be
+	// discarded independently
+	err := b.StartMessageRx(
+		func(msg gsm.Message) { t.Errorf("unexpected message: %v", msg) },
+		func(err error) { t.Errorf("unexpected rx error: %v", err) },
+		gsm.WithStoreAndNotify,
+		gsm.WithCollector(sms.NewCollector()),
+		gsm.WithReassemblyTimeout(50*time.Millisecond))
+	require.Nil(t, err)
+	defer b.StopMessageRx()
+
+	_, err = a.SendLongMessage("+61400000002", strings.Repeat("0123456789", 40))
+	require.Nil(t, err)
+	n.Discard(1)
+	n.Release()
+	waitEmpty(t, n.Modem("+61400000002"))
+}
+
+func TestStoreAndNotifyTranscript(t *testing.T) {
+	tr := transcript.New(t, "testdata/cmti.txt")
+	g := gsm.New(at.New(tr, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	msgs, errs := storedRx(t, g)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-msgs:
+			assert.Equal(t, "+61400000001", msg.Number)
+			assert.Equal(t, "swept", msg.Message)
+		case err := <-errs:
+			t.Errorf("unexpected rx error: %v", err)
+		case <-time.After(time.Second):
+			t.Fatal("no message received")
+		}
+	}
+	select {
+	case <-tr.Done():
+	case <-time.After(time.Second):
+		t.Error("transcript not completed")
+	}
+}
+
+func TestStoreAndNotifyUndecodable(t *testing.T) {
+	// UCS2 with a dangling surrogate
+	bad := tpdu.TPDU{
+		OA: tpdu.NewAddress(tpdu.FromNumber("+61400000001")),
+		UD: []byte{0xd8, 0x00},
+	}
+	bad.SetSmsType(tpdu.SmsDeliver)
+	bad.SetDCS(0x08)
+	btp, err := bad.MarshalBinary()
+	require.Nil(t, err)
+	pdus, err := sms.Encode([]byte("good"), sms.AsDeliver, sms.From("+61400000001"))
+	require.Nil(t, err)
+	gtp, err := pdus[0].MarshalBinary()
+	require.Nil(t, err)
+
+	n := sim.NewNetwork()
+	defer n.Close()
+	m := n.Attach("+61400000002")
+	for _, tp := range [][]byte{btp, gtp} {
+		_, err = m.StoreMessage("SM", sim.StatusRecUnread, tp)
+		require.Nil(t, err)
+	}
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	require.Nil(t, g.Init())
+	msgs, errs := storedRx(t, g)
+	expect := func(idx int) {
+		t.Helper()
+		select {
+		case err := <-errs:
+			require.IsType(t, gsm.ErrStored{}, err)
+			assert.Equal(t, idx, err.(gsm.ErrStored).Index)
+		case <-time.After(time.Second):
+			t.Fatal("no error reported")
+		}
+		select {
+		case msg := <-msgs:
+			assert.Equal(t, "good", msg.Message)
+		case <-time.After(time.Second):
+			t.Fatal("no message received")
+		}
+		waitEmpty(t, m)
+	}
+	// swept
+	expect(1)
+
+	// notified
+	require.Nil(t, m.Deliver(btp))
+	require.Nil(t, m.Deliver(gtp))
+	expect(1)
+}
+
+func TestStoreAndNotifyRejected(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	m := n.Attach("+61400000002")
+	pdus, err := sms.Encode([]byte("hello"), sms.AsDeliver, sms.From("+61400000001"))
+	require.Nil(t, err)
+	tp, err := pdus[0].MarshalBinary()
+	require.Nil(t, err)
+	_, err = m.StoreMessage("SM", sim.StatusRecUnread, tp)
+	require.Nil(t, err)
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	require.Nil(t, g.Init())
+
+	// rejected, so retained
+	msgs := make(chan gsm.Message, 1)
+	eh := func(err error) { t.Errorf("unexpected rx error: %v", err) }
+	reject := func(msg gsm.Message) error {
+		msgs <- msg
+		return gsm.ErrNack{FCS: 0xd3}
+	}
+	err = g.StartMessageRx(nil, eh, gsm.WithStoreAndNotify, gsm.WithAckHandler(reject))
+	require.Nil(t, err)
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, "hello", msg.Message)
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	assert.Equal(t, 1, len(m.Messages("SM")))
+	g.StopMessageRx()
+
+	// collected again by the next sweep
+	accept := func(msg gsm.Message) error {
+		msgs <- msg
+		return nil
+	}
+	err = g.StartMessageRx(nil, eh, gsm.WithStoreAndNotify, gsm.WithAckHandler(accept))
+	require.Nil(t, err)
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, "hello", msg.Message)
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	waitEmpty(t, m)
+}
+
+// networkGSM attaches a modem to the network and returns an initialised GSM
+// in PDU mode on that modem.
+func networkGSM(t *testing.T, n *sim.Network, msisdn string) *gsm.GSM {
+	t.Helper()
+	m := n.Attach(msisdn)
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	require.Nil(t, g.Init())
+	return g
+}
+
+// storedRx starts message reception in store and notify mode, returning the
+// channels of received messages and errors.
+func storedRx(t *testing.T, g *gsm.GSM) (chan gsm.Message, chan error) {
+	t.Helper()
+	msgs := make(chan gsm.Message, 2)
+	errs := make(chan error, 2)
+	err := g.StartMessageRx(
+		func(msg gsm.Message) { msgs <- msg },
+		func(err error) { errs <- err },
+		gsm.WithStoreAndNotify)
+	require.Nil(t, err)
+	return msgs, errs
+}
+
+// waitEmpty waits for the collected messages to be deleted from the modem
+// storage.
+func waitEmpty(t *testing.T, m *sim.Modem) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(m.Messages("SM")) != 0 {
+		if time.Now().After(deadline) {
+			t.Errorf("messages not deleted: %d remaining", len(m.Messages("SM")))
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitFor waits for the condition to be satisfied, failing the test if it is
+// not satisfied within a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Error("condition never satisfied")
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+
+	"github.com/warthog618/modem/info"
+)
+
+// charset returns the TE character set, as per +CSCS.
+func (g *GSM) charset() (string, error) {
+	i, err := g.Command("+CSCS?")
+	if err != nil {
+		return "", err
+	}
+	for _, l := range i {
+		if info.HasPrefix(l, "+CSCS") {
+			return strings.Trim(info.TrimPrefix(l, "+CSCS"), `"`), nil
+		}
+	}
+	return "", ErrMalformedResponse
+}
+
+// unmarshalText converts text mode +CMT info into the equivalent SMS-DELIVER
+// TPDU, and returns the SMSC address, if present in the header.
+//
+// The header is `+CMT: <oa>,[<alpha>],<scts>` and, if enabled by +CSDH=1, is
+// followed by `,<tooa>,<fo>,<pid>,<dcs>,<sca>,<tosca>,<length>`.
+// Without the full header the message is assumed to be GSM7 encoded.
+//
+// The text may span several lines, which are rejoined with newlines.
+//
+// The cscs is the TE character set used to present the text, e.g. "IRA",
+// "GSM" or "UCS2".
+func unmarshalText(i []string, cscs string) (tp tpdu.TPDU, sca string, err error) {
+	if len(i) < 2 {
+		err = ErrUnderlength
+		return
+	}
+	f := splitFields(i[0][strings.Index(i[0], ":")+1:])
+	if len(f) < 3 {
+		err = ErrMalformedResponse
+		return
+	}
+	tp.SetSmsType(tpdu.SmsDeliver)
+	udl := -1
+	oa, err := decodeTE(f[0], cscs)
+	if err != nil {
+		return
+	}
+	tooa := 129
+	if strings.HasPrefix(oa, "+") {
+		tooa = 145
+	}
+	if f[2] != "" {
+		if tp.SCTS, err = parseSCTS(f[2]); err != nil {
+			return
+		}
+	}
+	if len(f) >= 10 {
+		var v [4]int
+		for n := range v {
+			if v[n], err = strconv.Atoi(f[3+n]); err != nil {
+				return
+			}
+		}
+		tooa = v[0]
+		tp.FirstOctet = tpdu.FirstOctet(v[1])
+		tp.PID = byte(v[2])
+		tp.DCS = tpdu.DCS(v[3])
+		if sca, err = decodeTE(f[7], cscs); err != nil {
+			return
+		}
+		if udl, err = strconv.Atoi(f[9]); err != nil {
+			return
+		}
+	}
+	tp.OA = tpdu.Address{TOA: byte(tooa) | 0x80, Addr: strings.TrimPrefix(oa, "+")}
+	err = unmarshalTextUD(&tp, strings.Join(i[1:], "\n"), udl, cscs)
+	return
+}
+
+// unmarshalTextUD sets the UDH and UD of the TPDU from the text mode
+// presentation of the message, which is hex for data that is not GSM7 or that
+// contains a UDH, else text in the TE character set.
+//
+// The udl is the <length> from a +CSDH=1 header, which for GSM7 hex data is the
+// TP-UDL in septets, or -1 if not available.
+//
+// The DCS and first octet of the TPDU must already be set.
+func unmarshalTextUD(tp *tpdu.TPDU, body string, udl int, cscs string) error {
+	alpha, err := tp.Alphabet()
+	if err != nil {
+		return err
+	}
+	if tp.UDHI() || alpha != tpdu.Alpha7Bit {
+		// user data presented as hex
+		ud, err := hex.DecodeString(body)
+		if err != nil {
+			return err
+		}
+		return unmarshalUserData(tp, alpha, ud, udl)
+	}
+	if cscs == "GSM" {
+		// already septets
+		tp.UD = []byte(body)
+		return nil
+	}
+	text, err := decodeTE(body, cscs)
+	if err != nil {
+		return err
+	}
+	tp.UD, err = gsm7.Encode([]byte(text))
+	return err
+}
+
+// unmarshalUserData sets the UDH and UD of the TPDU from the TP-UD, excluding
+// the TP-UDL.
+//
+// The udl is the TP-UDL, in septets for GSM7, or -1 if not available.
+func unmarshalUserData(tp *tpdu.TPDU, alpha tpdu.Alphabet, ud []byte, udl int) error {
+	udhl := 0
+	if tp.UDHI() {
+		var udh tpdu.UserDataHeader
+		n, err := udh.UnmarshalBinary(ud)
+		if err != nil {
+			return err
+		}
+		tp.UDH = udh
+		udhl = n
+	}
+	if alpha != tpdu.Alpha7Bit {
+		tp.UD = ud[udhl:]
+		return nil
+	}
+	fillBits := 0
+	if dangling := udhl % 7; dangling != 0 {
+		fillBits = 7 - dangling
+	}
+	sm := gsm7.Unpack7Bit(ud[udhl:], fillBits)
+	if udl >= 0 {
+		n := udl - (udhl*8+fillBits)/7
+		if n < 0 || n > len(sm) {
+			return ErrMalformedResponse
+		}
+		sm = sm[:n]
+	} else if l := len(sm); l > 0 && sm[l-1] == 0 && ((len(ud)-udhl)*8-fillBits)%7 == 0 {
+		// as the TP-UDL is not available, a trailing 0 septet filling the
+		// final octet is assumed to be padding.
+		sm = sm[:l-1]
+	}
+	tp.UD = sm
+	return nil
+}
+
+// decodeTE decodes text presented in the TE character set into UTF-8.
+func decodeTE(s string, cscs string) (string, error) {
+	switch cscs {
+	case "UCS2":
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		r, err := ucs2.Decode(b)
+		if err != nil {
+			return "", err
+		}
+		return string(r), nil
+	case "GSM":
+		b, err := gsm7.Decode([]byte(s))
+		return string(b), err
+	}
+	return s, nil
+}
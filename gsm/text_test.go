@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package gsm_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestTextMessageRx(t *testing.T) {
+	long := strings.Repeat("0123456789", 40)
+	longUCS2 := strings.Repeat("Grüße €0123", 20)
+	patterns := []struct {
+		name    string
+		cscs    string
+		message string
+	}{
+		{"short", "IRA", "hello"},
+		{"short gsm", "GSM", "hello"},
+		{"short ucs2", "UCS2", "hello €"},
+		{"ucs2 alphabet", "IRA", "hello 😁"},
+		{"long", "IRA", long},
+		{"long ucs2 alphabet", "UCS2", longUCS2},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork()
+			defer n.Close()
+			g := networkGSM(t, n, "+61400000001")
+			m := n.Attach("+61400000002")
+			r := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithTextMode)
+			require.Nil(t, r.Init())
+			_, err := r.Command(`+CSCS="` + p.cscs + `"`)
+			require.Nil(t, err)
+			msgs := make(chan gsm.Message, 2)
+			errs := make(chan error, 2)
+			err = r.StartMessageRx(
+				func(msg gsm.Message) { msgs <- msg },
+				func(err error) { errs <- err })
+			require.Nil(t, err)
+			assert.Equal(t, 1, m.Settings().CSDH)
+
+			before := time.Now().Add(-time.Second)
+			_, err = g.SendLongMessage("+61400000002", p.message)
+			require.Nil(t, err)
+			select {
+			case msg := <-msgs:
+				assert.Equal(t, "+61400000001", msg.Number)
+				assert.Equal(t, p.message, msg.Message)
+				assert.True(t, msg.SCTS.After(before))
+				assert.NotEmpty(t, msg.TPDUs)
+			case err := <-errs:
+				t.Errorf("unexpected error: %v", err)
+			case <-time.After(time.Second):
+				t.Error("no message received")
+			}
+			waitFor(t, func() bool { return m.PendingAcks() == 0 })
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTextMessageRxHeader(t *testing.T) {
+	patterns := []struct {
+		name string
+		rx   string
+		msg  gsm.Message
+		err  bool
+	}{
+		{
+			"short header",
+			"+CMT: \"+61400000001\",,\"26/10/18,09:30:00+40\"\r\nhello\r\n",
+			gsm.Message{Number: "+61400000001", Message: "hello"},
+			false,
+		},
+		{
+			"national number",
+			"+CMT: \"0400000001\",,\"26/10/18,09:30:00+40\",129,4,0,0,\"+61411000000\",145,5\r\nhello\r\n",
+			gsm.Message{Number: "0400000001", Message: "hello", SMSC: "+61411000000"},
+			false,
+		},
+		{
+			"8bit",
+			"+CMT: \"+61400000001\",,\"26/10/18,09:30:00+40\",145,4,0,4,\"+61411000000\",145,2\r\n6869\r\n",
+			gsm.Message{Number: "+61400000001", Message: "hi", SMSC: "+61411000000"},
+			false,
+		},
+		{
+			"trailing @",
+			"+CMT: \"+61400000001\",,\"26/10/18,09:30:00+40\",145,68,0,0,\"+61411000000\",145,16\r\n050003010101C2E231B96C3EA301\r\n",
+			gsm.Message{Number: "+61400000001", Message: "abcdefgh@", SMSC: "+61411000000"},
+			false,
+		},
+		{
+			"trailing padding",
+			"+CMT: \"+61400000001\",,\"26/10/18,09:30:00+40\",145,68,0,0,\"+61411000000\",145,15\r\n050003010101C2E231B96C3EA301\r\n",
+			gsm.Message{Number: "+61400000001", Message: "abcdefgh", SMSC: "+61411000000"},
+			false,
+		},
+		{
+			"bad length",
+			"+CMT: \"+61400000001\",,\"26/10/18,09:30:00+40\",145,68,0,0,\"+61411000000\",145,30\r\n050003010101C2E231B96C3EA301\r\n",
+			gsm.Message{},
+			true,
+		},
+		{
+			"bad scts",
+			"+CMT: \"+61400000001\",,\"26/10/18\"\r\nhello\r\n",
+			gsm.Message{},
+			true,
+		},
+		{
+			"bad fo",
+			"+CMT: \"+61400000001\",,\"26/10/18,09:30:00+40\",145,x,0,0,\"+61411000000\",145,5\r\nhello\r\n",
+			gsm.Message{},
+			true,
+		},
+		{
+			"bad hex",
+			"+CMT: \"+61400000001\",,\"26/10/18,09:30:00+40\",145,4,0,8,\"+61411000000\",145,2\r\nzz\r\n",
+			gsm.Message{},
+			true,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := sim.New(sim.WithSettings(sim.Settings{CMGF: 1, CSCS: "IRA"}))
+			defer m.Close()
+			g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithTextMode)
+			msgs := make(chan gsm.Message, 1)
+			errs := make(chan error, 1)
+			// phase 2 service, so injected messages are not acknowledged
+			err := g.StartMessageRx(
+				func(msg gsm.Message) { msgs <- msg },
+				func(err error) { errs <- err },
+				gsm.WithInitCmds("+CSDH=1", "+CNMI=1,2,0,0,0"))
+			require.Nil(t, err)
+			m.Inject("\r\n" + p.rx)
+			select {
+			case msg := <-msgs:
+				assert.False(t, p.err)
+				assert.Equal(t, p.msg.Number, msg.Number)
+				assert.Equal(t, p.msg.Message, msg.Message)
+				assert.Equal(t, p.msg.SMSC, msg.SMSC)
+			case err := <-errs:
+				assert.True(t, p.err, err)
+				_, ok := err.(gsm.ErrUnmarshal)
+				assert.True(t, ok, err)
+			case <-time.After(time.Second):
+				t.Error("no message received")
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
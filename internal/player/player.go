@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+// Package player provides the playback of a scripted exchange with a modem,
+// as shared by the replay and transcript packages.
+package player
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/warthog618/modem/trace"
+)
+
+// Entry is one read or write in the exchange.
+type Entry struct {
+	// Dir is the direction of the entry, either trace.DirRead or
+	// trace.DirWrite.
+	Dir string
+
+	// Time is the time the entry was recorded, which is only relevant if
+	// playing WithTiming.
+	Time time.Time
+
+	// Data is the data read from or written to the modem.
+	Data []byte
+}
+
+// Player is an io.ReadWriter that plays the modem side of an exchange.
+//
+// Reads return the entries read from the modem, in order, but only once all
+// the writes preceding them have been made.
+// Writes must match the entries written to the modem, though they need not be
+// chunked the same as the entries.
+type Player struct {
+	entries    []Entry
+	timing     bool
+	mismatch   func(idx int, remaining, got []byte) string
+	incomplete func(idx int, remaining []byte) string
+
+	mu      sync.Mutex
+	idx     int // index of the current entry
+	off     int // offset into the data of the current entry
+	ready   time.Time
+	err     error
+	closed  bool
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// Option modifies a Player created by New.
+type Option func(*Player)
+
+// New creates a Player that plays the entries.
+func New(entries []Entry, options ...Option) *Player {
+	p := &Player{
+		entries:    entries,
+		mismatch:   defaultMismatch,
+		incomplete: defaultIncomplete(len(entries)),
+		changed:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	p.advance(0)
+	return p
+}
+
+// WithTiming delays reads to match the times of the entries.
+//
+// Each read is delayed by the time between it and the preceding entry,
+// measured from when that preceding entry was played.
+func WithTiming() Option {
+	return func(p *Player) {
+		p.timing = true
+	}
+}
+
+// WithMismatch sets the function describing a write that does not match the
+// entries.
+//
+// The idx is the index of the current entry, or len(entries) if the entries
+// are exhausted, and remaining is the unplayed data of that entry.
+func WithMismatch(f func(idx int, remaining, got []byte) string) Option {
+	return func(p *Player) {
+		p.mismatch = f
+	}
+}
+
+// WithIncomplete sets the function describing the entries remaining when
+// verifying.
+//
+// The idx is the index of the first unplayed entry, and remaining is the
+// unplayed data of that entry.
+func WithIncomplete(f func(idx int, remaining []byte) string) Option {
+	return func(p *Player) {
+		p.incomplete = f
+	}
+}
+
+var (
+	// ErrClosed indicates the Player has been closed.
+	ErrClosed = errors.New("closed")
+
+	// ErrUnexpectedWrite indicates a write did not match the entries.
+	ErrUnexpectedWrite = errors.New("unexpected write")
+
+	// ErrIncomplete indicates the entries were not completely played.
+	ErrIncomplete = errors.New("incomplete")
+)
+
+// Done returns a channel that is closed once the entries have been completely
+// played.
+func (p *Player) Done() <-chan struct{} {
+	return p.done
+}
+
+// Read returns the next entry read from the modem.
+//
+// Read blocks until the entry becomes available, and returns io.EOF once the
+// Player is closed.
+// Once the entries are exhausted Read blocks until the Player is closed.
+func (p *Player) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	for {
+		if p.closed {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		if p.idx < len(p.entries) && p.entries[p.idx].Dir == trace.DirRead {
+			if wait := time.Until(p.ready); p.timing && wait > 0 {
+				changed := p.changed
+				p.mu.Unlock()
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-changed:
+					t.Stop()
+				}
+				p.mu.Lock()
+				continue
+			}
+			e := p.entries[p.idx]
+			n := copy(b, e.Data[p.off:])
+			p.off += n
+			if p.off == len(e.Data) {
+				p.advance(p.idx + 1)
+			}
+			p.mu.Unlock()
+			return n, nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+		<-changed
+		p.mu.Lock()
+	}
+}
+
+// Write checks the data matches the next entry written to the modem.
+//
+// Returns ErrUnexpectedWrite if the data does not match the entries, in which
+// case the remainder of the entries are not played.
+func (p *Player) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, ErrClosed
+	}
+	if p.err != nil {
+		return 0, p.err
+	}
+	n := 0
+	for n < len(b) {
+		if p.idx >= len(p.entries) || p.entries[p.idx].Dir != trace.DirWrite {
+			return n, p.fail(b[n:])
+		}
+		e := p.entries[p.idx]
+		expected := e.Data[p.off:]
+		l := min(len(expected), len(b)-n)
+		if !bytes.Equal(b[n:n+l], expected[:l]) {
+			return n, p.fail(b[n:])
+		}
+		n += l
+		p.off += l
+		if p.off == len(e.Data) {
+			p.advance(p.idx + 1)
+		}
+	}
+	return n, nil
+}
+
+// Close ends the playback, unblocking any pending reads.
+func (p *Player) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.changed)
+	}
+	return nil
+}
+
+// Verify returns an error if a write did not match the entries or if the
+// entries have not been completely played.
+func (p *Player) Verify() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if p.idx >= len(p.entries) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrIncomplete, p.incomplete(p.idx, p.remaining()))
+}
+
+// advance moves playback to the entry at idx, and wakes any blocked reads.
+//
+// Must be called with the lock held.
+func (p *Player) advance(idx int) {
+	if p.timing && idx < len(p.entries) {
+		var gap time.Duration
+		if idx > 0 {
+			gap = p.entries[idx].Time.Sub(p.entries[idx-1].Time)
+		}
+		p.ready = time.Now().Add(gap)
+	}
+	p.idx = idx
+	p.off = 0
+	if idx == len(p.entries) {
+		close(p.done)
+	}
+	if !p.closed {
+		close(p.changed)
+		p.changed = make(chan struct{})
+	}
+}
+
+// fail records the failure of a write.
+//
+// Must be called with the lock held.
+func (p *Player) fail(got []byte) error {
+	p.err = fmt.Errorf("%w: %s", ErrUnexpectedWrite, p.mismatch(p.idx, p.remaining(), got))
+	return p.err
+}
+
+// remaining returns the unplayed data of the current entry, if any.
+//
+// Must be called with the lock held.
+func (p *Player) remaining() []byte {
+	if p.idx >= len(p.entries) {
+		return nil
+	}
+	return p.entries[p.idx].Data[p.off:]
+}
+
+func defaultMismatch(idx int, remaining, got []byte) string {
+	return fmt.Sprintf("entry %d: got %q, expected %q", idx, got, remaining)
+}
+
+func defaultIncomplete(n int) func(idx int, remaining []byte) string {
+	return func(idx int, remaining []byte) string {
+		return fmt.Sprintf("%d of %d entries remaining, next %q", n-idx, n, remaining)
+	}
+}
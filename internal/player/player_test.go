@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package player_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/internal/player"
+	"github.com/warthog618/modem/trace"
+)
+
+func entries() []player.Entry {
+	return []player.Entry{
+		{Dir: trace.DirWrite, Data: []byte("AT\r\n")},
+		{Dir: trace.DirRead, Data: []byte("\r\nOK\r\n")},
+	}
+}
+
+func TestPlay(t *testing.T) {
+	p := player.New(entries())
+	n, err := p.Write([]byte("AT"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t,
+		"incomplete: 2 of 2 entries remaining, next \"\\r\\n\"",
+		p.Verify().Error())
+	n, err = p.Write([]byte("\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	b := make([]byte, 10)
+	n, err = p.Read(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "\r\nOK\r\n", string(b[:n]))
+	select {
+	case <-p.Done():
+	default:
+		t.Error("not done")
+	}
+	assert.Nil(t, p.Verify())
+	require.Nil(t, p.Close())
+	_, err = p.Read(b)
+	assert.Equal(t, io.EOF, err)
+	_, err = p.Write([]byte("AT"))
+	assert.Equal(t, player.ErrClosed, err)
+}
+
+func TestMismatch(t *testing.T) {
+	p := player.New(entries())
+	_, err := p.Write([]byte("ATI\r\n"))
+	assert.True(t, errors.Is(err, player.ErrUnexpectedWrite))
+	assert.Equal(t, "unexpected write: entry 0: got \"ATI\\r\\n\", expected \"AT\\r\\n\"", err.Error())
+	assert.Equal(t, err, p.Verify())
+
+	p = player.New(entries(),
+		player.WithMismatch(func(idx int, remaining, got []byte) string {
+			return "custom"
+		}),
+		player.WithIncomplete(func(idx int, remaining []byte) string {
+			return "remaining"
+		}))
+	assert.Equal(t, "incomplete: remaining", p.Verify().Error())
+	_, err = p.Write([]byte("X"))
+	assert.Equal(t, "unexpected write: custom", err.Error())
+}
+
+func TestTiming(t *testing.T) {
+	now := time.Now()
+	p := player.New([]player.Entry{
+		{Dir: trace.DirRead, Time: now, Data: []byte("one")},
+		{Dir: trace.DirRead, Time: now.Add(50 * time.Millisecond), Data: []byte("two")},
+	}, player.WithTiming())
+	b := make([]byte, 10)
+	_, err := p.Read(b)
+	require.Nil(t, err)
+	start := time.Now()
+	n, err := p.Read(b)
+	require.Nil(t, err)
+	assert.Equal(t, "two", string(b[:n]))
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+// Package replay provides an io.ReadWriter that plays back a session recorded
+// by trace.WithRecorder.
+//
+// The Replay acts as a mock modem, checking that writes match the recorded
+// writes and returning the recorded reads, so a session captured from a modem
+// may be used as a deterministic regression test.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/warthog618/modem/internal/player"
+	"github.com/warthog618/modem/trace"
+)
+
+// Replay is an io.ReadWriter that plays back a recorded session.
+//
+// Reads return the recorded reads, in order, but only once all the writes
+// preceding them in the recording have been made.
+// Writes must match the recorded writes, though they need not be chunked the
+// same as the recording.
+type Replay struct {
+	records []player.Entry
+	timing  bool
+	p       *player.Player
+}
+
+// Option modifies a Replay created by New.
+type Option func(*Replay)
+
+// New creates a Replay from the JSON lines recording read from r.
+func New(r io.Reader, options ...Option) (*Replay, error) {
+	rp := &Replay{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var tr trace.Record
+		if err := json.Unmarshal(s.Bytes(), &tr); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if tr.Dir != trace.DirRead && tr.Dir != trace.DirWrite {
+			return nil, fmt.Errorf("line %d: unknown direction %q", line, tr.Dir)
+		}
+		data, err := tr.Data()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(data) == 0 {
+			continue
+		}
+		rp.records = append(rp.records, player.Entry{Dir: tr.Dir, Time: tr.Time, Data: data})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for _, option := range options {
+		option(rp)
+	}
+	popts := []player.Option{
+		player.WithMismatch(rp.mismatch),
+		player.WithIncomplete(rp.incomplete),
+	}
+	if rp.timing {
+		popts = append(popts, player.WithTiming())
+	}
+	rp.p = player.New(rp.records, popts...)
+	return rp, nil
+}
+
+// WithTiming delays reads to match the timing of the original recording.
+//
+// Each read is delayed by the time between it and the preceding record in the
+// recording, measured from when that preceding record was played.
+// By default reads are returned as soon as they become available.
+func WithTiming() Option {
+	return func(r *Replay) {
+		r.timing = true
+	}
+}
+
+var (
+	// ErrClosed indicates the Replay has been closed.
+	ErrClosed = player.ErrClosed
+
+	// ErrUnexpectedWrite indicates a write did not match the recording.
+	ErrUnexpectedWrite = player.ErrUnexpectedWrite
+
+	// ErrIncomplete indicates the recording was not completely played back.
+	ErrIncomplete = player.ErrIncomplete
+)
+
+// Read returns the next recorded read.
+//
+// Read blocks until the read becomes available, and returns io.EOF once the
+// Replay is closed.
+// Once the recording is exhausted Read blocks until the Replay is closed.
+func (r *Replay) Read(p []byte) (int, error) {
+	return r.p.Read(p)
+}
+
+// Write checks the data matches the next recorded write.
+//
+// Returns ErrUnexpectedWrite if the data does not match the recording, in which
+// case the remainder of the recording is not played.
+func (r *Replay) Write(p []byte) (int, error) {
+	return r.p.Write(p)
+}
+
+// Close ends the playback, unblocking any pending reads.
+func (r *Replay) Close() error {
+	return r.p.Close()
+}
+
+// Verify returns an error if a write did not match the recording or if the
+// recording has not been completely played back.
+func (r *Replay) Verify() error {
+	return r.p.Verify()
+}
+
+// mismatch describes a write that does not match the recording.
+func (r *Replay) mismatch(idx int, remaining, got []byte) string {
+	if idx >= len(r.records) || r.records[idx].Dir != trace.DirWrite {
+		return fmt.Sprintf("record %d: got %q", idx, got)
+	}
+	return fmt.Sprintf("record %d: got %q, expected %q", idx, got, remaining)
+}
+
+// incomplete describes the records remaining to be played.
+func (r *Replay) incomplete(idx int, remaining []byte) string {
+	return fmt.Sprintf("%d of %d records remaining, next %s %q",
+		len(r.records)-idx, len(r.records), r.records[idx].Dir, remaining)
+}
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package replay_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/replay"
+	"github.com/warthog618/modem/trace"
+)
+
+func TestNew(t *testing.T) {
+	patterns := []struct {
+		name string
+		rec  string
+		err  bool
+	}{
+		{"empty", "", false},
+		{"blank lines", "\n\n", false},
+		{"text", `{"dir":"w","time":"2026-10-18T09:15:02Z","text":"ATI\r\n"}`, false},
+		{"hex", `{"dir":"r","time":"2026-10-18T09:15:02Z","hex":"ff00"}`, false},
+		{"bad json", `{"dir":"w",`, true},
+		{"bad dir", `{"dir":"x","time":"2026-10-18T09:15:02Z","text":"ATI"}`, true},
+		{"bad hex", `{"dir":"r","time":"2026-10-18T09:15:02Z","hex":"fg"}`, true},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := replay.New(strings.NewReader(p.rec))
+			if p.err {
+				assert.NotNil(t, err)
+				assert.Nil(t, r)
+			} else {
+				assert.Nil(t, err)
+				assert.NotNil(t, r)
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	f, err := os.Open("testdata/ati.jsonl")
+	require.Nil(t, err)
+	defer f.Close()
+	r, err := replay.New(f)
+	require.Nil(t, err)
+	defer r.Close()
+	a := at.New(r)
+	info, err := a.Command("I")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Manufacturer: huawei", "Model: E173"}, info)
+	assert.True(t, errors.Is(r.Verify(), replay.ErrIncomplete))
+
+	info, err = a.SMSCommand("+CMGS=23", "00010005910180f200000bc8329bfd06dddf723619")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"+CMGS: 42"}, info)
+	assert.Nil(t, r.Verify())
+}
+
+func TestReplayMismatch(t *testing.T) {
+	rec := `{"dir":"w","time":"2026-10-18T09:15:02Z","text":"ATI\r\n"}
+{"dir":"r","time":"2026-10-18T09:15:02Z","text":"\r\nOK\r\n"}`
+	r, err := replay.New(strings.NewReader(rec))
+	require.Nil(t, err)
+	defer r.Close()
+
+	// partial match
+	n, err := r.Write([]byte("AT"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	// mismatch
+	n, err = r.Write([]byte("Z\r\n"))
+	assert.True(t, errors.Is(err, replay.ErrUnexpectedWrite))
+	assert.Equal(t, 0, n)
+	assert.True(t, errors.Is(r.Verify(), replay.ErrUnexpectedWrite))
+
+	// subsequent writes fail too
+	_, err = r.Write([]byte("I\r\n"))
+	assert.True(t, errors.Is(err, replay.ErrUnexpectedWrite))
+
+	// write when read expected
+	r, err = replay.New(strings.NewReader(rec))
+	require.Nil(t, err)
+	defer r.Close()
+	n, err = r.Write([]byte("ATI\r\nATZ\r\n"))
+	assert.True(t, errors.Is(err, replay.ErrUnexpectedWrite))
+	assert.Equal(t, 5, n)
+
+	// write past end
+	r, err = replay.New(strings.NewReader(""))
+	require.Nil(t, err)
+	defer r.Close()
+	_, err = r.Write([]byte("ATI\r\n"))
+	assert.True(t, errors.Is(err, replay.ErrUnexpectedWrite))
+}
+
+func TestReplayRead(t *testing.T) {
+	rec := `{"dir":"r","time":"2026-10-18T09:15:02Z","text":"\r\nRING\r\n"}
+{"dir":"w","time":"2026-10-18T09:15:02Z","text":"ATA\r\n"}
+{"dir":"r","time":"2026-10-18T09:15:02Z","hex":"0d0aff0d0a"}`
+	r, err := replay.New(strings.NewReader(rec))
+	require.Nil(t, err)
+
+	// leading read available immediately, in chunks
+	b := make([]byte, 4)
+	n, err := r.Read(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "\r\nRI", string(b[:n]))
+	n, err = r.Read(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "NG\r\n", string(b[:n]))
+
+	// blocked until write
+	done := make(chan []byte)
+	go func() {
+		b := make([]byte, 10)
+		n, _ := r.Read(b)
+		done <- b[:n]
+	}()
+	select {
+	case <-done:
+		t.Error("read not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	_, err = r.Write([]byte("ATA\r\n"))
+	assert.Nil(t, err)
+	select {
+	case d := <-done:
+		assert.Equal(t, []byte("\r\n\xff\r\n"), d)
+	case <-time.After(100 * time.Millisecond):
+		t.Error("read still blocked")
+	}
+	assert.Nil(t, r.Verify())
+
+	// blocked at end until closed
+	go func() {
+		n, err := r.Read(b)
+		assert.Equal(t, io.EOF, err)
+		done <- b[:n]
+	}()
+	select {
+	case <-done:
+		t.Error("read not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	r.Close()
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Error("read still blocked")
+	}
+	_, err = r.Write([]byte("ATA\r\n"))
+	assert.Equal(t, replay.ErrClosed, err)
+}
+
+func TestWithTiming(t *testing.T) {
+	rec := `{"dir":"w","time":"2026-10-18T09:15:02.000Z","text":"ATI\r\n"}
+{"dir":"r","time":"2026-10-18T09:15:02.050Z","text":"\r\nOK\r\n"}`
+	r, err := replay.New(strings.NewReader(rec), replay.WithTiming())
+	require.Nil(t, err)
+	defer r.Close()
+	_, err = r.Write([]byte("ATI\r\n"))
+	assert.Nil(t, err)
+	start := time.Now()
+	b := make([]byte, 10)
+	_, err = r.Read(b)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	// record a session with a scripted modem
+	m := &scriptModem{rsp: map[string]string{
+		"ATI\r\n":      "\r\nModel: E173\r\n\r\nOK\r\n",
+		"AT+CSQ\r\n":   "\r\n+CSQ: 12,99\r\n\r\nOK\r\n",
+		"AT+CPIN?\r\n": "\r\n+CME ERROR: 10\r\n",
+	}, r: make(chan []byte, 10)}
+	var rec bytes.Buffer
+	a := at.New(trace.New(m, trace.WithRecorder(&rec), trace.WithLogger(nopLogger{})))
+	cmds := []string{"I", "+CSQ", "+CPIN?"}
+	var infos [][]string
+	var errs []error
+	for _, cmd := range cmds {
+		info, err := a.Command(cmd)
+		infos = append(infos, info)
+		errs = append(errs, err)
+	}
+	close(m.r)
+
+	// replay it
+	r, err := replay.New(&rec)
+	require.Nil(t, err)
+	defer r.Close()
+	a = at.New(r)
+	for i, cmd := range cmds {
+		info, err := a.Command(cmd)
+		assert.Equal(t, infos[i], info)
+		assert.Equal(t, errs[i], err)
+	}
+	assert.Nil(t, r.Verify())
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// scriptModem returns a scripted response to each command.
+type scriptModem struct {
+	rsp map[string]string
+	r   chan []byte
+}
+
+func (m *scriptModem) Read(p []byte) (int, error) {
+	data, ok := <-m.r
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(p, data), nil
+}
+
+func (m *scriptModem) Write(p []byte) (int, error) {
+	if rsp, ok := m.rsp[string(p)]; ok {
+		m.r <- []byte(rsp)
+	}
+	return len(p), nil
+}
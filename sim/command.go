@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Op is the operation requested by a command.
+type Op int
+
+const (
+	// OpExec executes the command, e.g. ATI or AT+CIMI.
+	OpExec Op = iota
+
+	// OpSet sets a parameter, e.g. AT+CMGF=0.
+	OpSet
+
+	// OpRead reads a parameter, e.g. AT+CMGF?.
+	OpRead
+
+	// OpTest requests the supported values, e.g. AT+CMGF=?.
+	OpTest
+)
+
+// Command is a single command from a command line.
+type Command struct {
+	// Name is the name of the command, in upper case, e.g. "+CMGF", "E" or
+	// "&F".
+	Name string
+
+	// Op is the operation requested.
+	Op Op
+
+	// Args contains the unparsed arguments to the command.
+	//
+	// For basic commands, such as E0, this is the numeric argument, while for
+	// extended set commands this is everything following the "=".
+	Args string
+}
+
+// Handler handles a command, returning the info lines of the response.
+//
+// Returning an error produces an error result code.  Errors of type
+// at.CMEError and at.CMSError are reported as per the +CMEE setting, while
+// other errors are reported as ERROR.
+//
+// Handlers are not called with any locks held, so may safely call the Modem
+// methods.
+type Handler func(m *Modem, cmd Command) ([]string, error)
+
+// SMSHandler handles a command that prompts for a message body, such as
+// +CMGS, once the body has been received.
+//
+// The body excludes the terminating ctrl-Z.  A body terminated by escape is
+// discarded and the handler is not called.
+type SMSHandler func(m *Modem, cmd Command, body string) ([]string, error)
+
+// Reply returns a Handler that returns the lines as the info in its response.
+func Reply(lines ...string) Handler {
+	return func(m *Modem, cmd Command) ([]string, error) {
+		return lines, nil
+	}
+}
+
+// Fail returns a Handler that fails with the error.
+func Fail(err error) Handler {
+	return func(m *Modem, cmd Command) ([]string, error) {
+		return nil, err
+	}
+}
+
+// errSyntax indicates a command line could not be parsed.
+var errSyntax = errors.New("syntax error")
+
+// parseCommandLine parses the commands in a command line, excluding the
+// leading "AT".
+func parseCommandLine(line string) ([]Command, error) {
+	var cmds []Command
+	s := line
+	for len(s) > 0 {
+		c := s[0]
+		switch {
+		case c == ';' || c == ' ':
+			s = s[1:]
+		case strings.IndexByte("+^%$#*", c) >= 0:
+			end := strings.IndexAny(s, "=?;")
+			if end < 0 {
+				end = len(s)
+			}
+			cmd := Command{Name: strings.ToUpper(s[:end])}
+			s = s[end:]
+			switch {
+			case strings.HasPrefix(s, "=?"):
+				cmd.Op = OpTest
+				s = s[2:]
+			case strings.HasPrefix(s, "?"):
+				cmd.Op = OpRead
+				s = s[1:]
+			case strings.HasPrefix(s, "="):
+				cmd.Op = OpSet
+				end = argsEnd(s[1:])
+				cmd.Args = s[1 : end+1]
+				s = s[end+1:]
+			}
+			cmds = append(cmds, cmd)
+		case c == 'D' || c == 'd':
+			cmds = append(cmds, Command{Name: "D", Args: s[1:]})
+			s = ""
+		case c == '&':
+			if len(s) < 2 || !isLetter(s[1]) {
+				return nil, errSyntax
+			}
+			end := digitsEnd(s[2:]) + 2
+			cmds = append(cmds, Command{Name: "&" + strings.ToUpper(s[1:2]), Args: s[2:end]})
+			s = s[end:]
+		case c == 'S' || c == 's':
+			end := digitsEnd(s[1:]) + 1
+			if end == 1 {
+				return nil, errSyntax
+			}
+			cmd := Command{Name: "S" + s[1:end]}
+			s = s[end:]
+			switch {
+			case strings.HasPrefix(s, "?"):
+				cmd.Op = OpRead
+				s = s[1:]
+			case strings.HasPrefix(s, "="):
+				cmd.Op = OpSet
+				end = digitsEnd(s[1:]) + 1
+				cmd.Args = s[1:end]
+				s = s[end:]
+			}
+			cmds = append(cmds, cmd)
+		case isLetter(c):
+			end := digitsEnd(s[1:]) + 1
+			cmds = append(cmds, Command{Name: strings.ToUpper(s[:1]), Args: s[1:end]})
+			s = s[end:]
+		default:
+			return nil, errSyntax
+		}
+	}
+	return cmds, nil
+}
+
+// argsEnd returns the index of the end of extended command arguments, which
+// are terminated by a semicolon outside of a quoted string, or the end of
+// line.
+func argsEnd(s string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+func digitsEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// Params returns the comma separated parameters in the arguments.
+//
+// Surrounding whitespace and quotes are removed from each parameter.
+func (c Command) Params() []string {
+	if c.Args == "" {
+		return nil
+	}
+	var params []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(c.Args); i++ {
+		switch c.Args[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, unquote(c.Args[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(params, unquote(c.Args[start:]))
+}
+
+// IntParams returns the parameters as integers.
+//
+// Empty parameters are returned as the default, def.
+// Returns an error if any non-empty parameter is not an integer.
+func (c Command) IntParams(def int) ([]int, error) {
+	params := c.Params()
+	ints := make([]int, len(params))
+	for i, p := range params {
+		if p == "" {
+			ints[i] = def
+			continue
+		}
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = v
+	}
+	return ints, nil
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim
+
+import (
+	"strings"
+
+	"github.com/warthog618/modem/at"
+)
+
+// builtinHandlers are the handlers provided by New, other than those for
+// commands prompting for a message body.
+var builtinHandlers = map[string]Handler{
+	"E":      echoHandler,
+	"Z":      resetHandler,
+	"&F":     resetHandler,
+	"Q":      basicHandler("0"),
+	"V":      basicHandler("1"),
+	"I":      infoHandler,
+	"+GCAP":  Reply("+GCAP: +CGSM,+DS,+ES"),
+	"+CGMI":  identityHandler(func(id Identity) string { return id.Manufacturer }),
+	"+CGMM":  identityHandler(func(id Identity) string { return id.Model }),
+	"+CGMR":  identityHandler(func(id Identity) string { return id.Revision }),
+	"+CGSN":  identityHandler(func(id Identity) string { return id.IMEI }),
+	"+GSN":   identityHandler(func(id Identity) string { return id.IMEI }),
+	"+CIMI":  identityHandler(func(id Identity) string { return id.IMSI }),
+	"+CNUM":  cnumHandler,
+	"+CPIN":  cpinHandler,
+	"+CFUN":  cfunHandler,
+	"+CREG":  cregHandler,
+	"+CSQ":   csqHandler,
+	"+CMGF":  intSetting("+CMGF", "(0,1)", 1, func(s *Settings) *int { return &s.CMGF }),
+	"+CMEE":  intSetting("+CMEE", "(0-2)", 2, func(s *Settings) *int { return &s.CMEE }),
+	"+CSDH":  intSetting("+CSDH", "(0,1)", 1, func(s *Settings) *int { return &s.CSDH }),
+	"+CNMI":  cnmiHandler,
+	"+CSCS":  cscsHandler,
+	"+CSMS":  csmsHandler,
+	"+CNMA":  cnmaHandler,
+	"+CSCA":  cscaHandler,
+	"+CPMS":  cpmsHandler,
+	"+CMGR":  cmgrHandler,
+	"+CMGL":  cmglHandler,
+	"+CMGD":  cmgdHandler,
+	"+CMSS":  cmssHandler,
+	"+CPBS":  cpbsHandler,
+	"+CPBR":  cpbrHandler,
+	"+CPBW":  cpbwHandler,
+	"+CPINR": Reply("+CPINR: SIM PIN,3,3", "+CPINR: SIM PUK,10,10"),
+}
+
+// update calls the function to modify the settings.
+func (m *Modem) update(f func(s *Settings)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f(&m.settings)
+}
+
+func echoHandler(m *Modem, cmd Command) ([]string, error) {
+	switch cmd.Args {
+	case "", "0":
+		m.update(func(s *Settings) { s.Echo = false })
+	case "1":
+		m.update(func(s *Settings) { s.Echo = true })
+	default:
+		return nil, ErrError
+	}
+	return nil, nil
+}
+
+func resetHandler(m *Modem, cmd Command) ([]string, error) {
+	if cmd.Args != "" && cmd.Args != "0" {
+		return nil, ErrError
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings = m.defaults
+	m.creg = 0
+	return nil, nil
+}
+
+// basicHandler returns a handler for a basic command that supports only the
+// given argument, such as V1.
+func basicHandler(arg string) Handler {
+	return func(m *Modem, cmd Command) ([]string, error) {
+		if cmd.Args != arg && !(arg == "0" && cmd.Args == "") {
+			return nil, ErrError
+		}
+		return nil, nil
+	}
+}
+
+func infoHandler(m *Modem, cmd Command) ([]string, error) {
+	id := m.identity
+	return []string{
+		"Manufacturer: " + id.Manufacturer,
+		"Model: " + id.Model,
+		"Revision: " + id.Revision,
+		"IMEI: " + id.IMEI,
+		"+GCAP: +CGSM,+DS,+ES",
+	}, nil
+}
+
+// identityHandler returns a handler for commands that return a field of the
+// identity.
+func identityHandler(field func(Identity) string) Handler {
+	return func(m *Modem, cmd Command) ([]string, error) {
+		switch cmd.Op {
+		case OpExec:
+			return []string{field(m.identity)}, nil
+		case OpTest:
+			return nil, nil
+		}
+		return nil, ErrError
+	}
+}
+
+func cnumHandler(m *Modem, cmd Command) ([]string, error) {
+	switch cmd.Op {
+	case OpExec:
+		n := m.identity.MSISDN
+		if n == "" {
+			return nil, nil
+		}
+		t := 129
+		if strings.HasPrefix(n, "+") {
+			t = 145
+		}
+		return []string{`+CNUM: "","` + n + `",` + itoa(t)}, nil
+	case OpTest:
+		return nil, nil
+	}
+	return nil, ErrError
+}
+
+func cpinHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		if m.locked {
+			return []string{"+CPIN: SIM PIN"}, nil
+		}
+		return []string{"+CPIN: READY"}, nil
+	case OpSet:
+		params := cmd.Params()
+		if len(params) == 0 {
+			return nil, at.CMEError("50")
+		}
+		if !m.locked {
+			return nil, at.CMEError("3")
+		}
+		if params[0] != m.pin {
+			return nil, at.CMEError("16")
+		}
+		m.locked = false
+		return nil, nil
+	case OpTest:
+		return nil, nil
+	}
+	return nil, ErrError
+}
+
+func cfunHandler(m *Modem, cmd Command) ([]string, error) {
+	switch cmd.Op {
+	case OpRead:
+		return []string{"+CFUN: 1"}, nil
+	case OpSet:
+		return nil, nil
+	case OpTest:
+		return []string{"+CFUN: (0,1,4),(0,1)"}, nil
+	}
+	return nil, ErrError
+}
+
+func cregHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		return []string{"+CREG: " + itoa(m.creg) + "," + itoa(m.registered)}, nil
+	case OpSet:
+		p, err := cmd.IntParams(0)
+		if err != nil || len(p) != 1 || p[0] < 0 || p[0] > 1 {
+			return nil, at.CMEError("50")
+		}
+		m.creg = p[0]
+		return nil, nil
+	case OpTest:
+		return []string{"+CREG: (0,1)"}, nil
+	}
+	return nil, ErrError
+}
+
+func csqHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpExec:
+		return []string{"+CSQ: " + itoa(m.rssi) + "," + itoa(m.ber)}, nil
+	case OpTest:
+		return []string{"+CSQ: (0-31,99),(0-7,99)"}, nil
+	}
+	return nil, ErrError
+}
+
+// intSetting returns a handler for a command setting a single integer
+// setting in the range 0 to max.
+func intSetting(name, rng string, max int, field func(*Settings) *int) Handler {
+	return func(m *Modem, cmd Command) ([]string, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		f := field(&m.settings)
+		switch cmd.Op {
+		case OpRead:
+			return []string{name + ": " + itoa(*f)}, nil
+		case OpSet:
+			p, err := cmd.IntParams(0)
+			if err != nil || len(p) != 1 || p[0] < 0 || p[0] > max {
+				return nil, at.CMEError("50")
+			}
+			*f = p[0]
+			return nil, nil
+		case OpTest:
+			return []string{name + ": " + rng}, nil
+		}
+		return nil, ErrError
+	}
+}
+
+// cnmiLimits are the maximum values for each CNMI parameter.
+var cnmiLimits = [5]int{2, 3, 3, 2, 1}
+
+func cnmiHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		v := m.settings.CNMI
+		return []string{"+CNMI: " + joinInts(v[:])}, nil
+	case OpSet:
+		p, err := cmd.IntParams(0)
+		if err != nil || len(p) > 5 {
+			return nil, at.CMSError("302")
+		}
+		var v [5]int
+		for i, n := range p {
+			if n < 0 || n > cnmiLimits[i] {
+				return nil, at.CMSError("302")
+			}
+			v[i] = n
+		}
+		m.settings.CNMI = v
+		return nil, nil
+	case OpTest:
+		return []string{"+CNMI: (0-2),(0-3),(0-3),(0-2),(0,1)"}, nil
+	}
+	return nil, ErrError
+}
+
+// charsets are the supported TE character sets.
+var charsets = []string{"IRA", "GSM", "UCS2"}
+
+func cscsHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		return []string{`+CSCS: "` + m.settings.CSCS + `"`}, nil
+	case OpSet:
+		p := cmd.Params()
+		if len(p) != 1 {
+			return nil, at.CMEError("50")
+		}
+		cs := strings.ToUpper(p[0])
+		for _, c := range charsets {
+			if c == cs {
+				m.settings.CSCS = cs
+				return nil, nil
+			}
+		}
+		return nil, at.CMEError("50")
+	case OpTest:
+		return []string{`+CSCS: ("IRA","GSM","UCS2")`}, nil
+	}
+	return nil, ErrError
+}
+
+func csmsHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		return []string{"+CSMS: " + itoa(m.settings.CSMS) + ",1,1,1"}, nil
+	case OpSet:
+		p, err := cmd.IntParams(0)
+		if err != nil || len(p) != 1 || p[0] < 0 || p[0] > 1 {
+			return nil, at.CMSError("302")
+		}
+		m.settings.CSMS = p[0]
+		return []string{"+CSMS: 1,1,1"}, nil
+	case OpTest:
+		return []string{"+CSMS: (0,1)"}, nil
+	}
+	return nil, ErrError
+}
+
+func joinInts(v []int) string {
+	s := make([]string, len(v))
+	for i, n := range v {
+		s[i] = itoa(n)
+	}
+	return strings.Join(s, ",")
+}
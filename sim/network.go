@@ -0,0 +1,296 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim
+
+import (
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+)
+
+// Network is a simulated GSM network.
+//
+// Messages sent by the Modems attached to the Network are routed, via a
+// simulated SMSC, to the Modem with the destination MSISDN, and are delivered
+// to that Modem as per its +CNMI setting.
+//
+// Deliveries are performed asynchronously, after the delivery delay, unless
+// the Network holds deliveries until they are explicitly released.
+type Network struct {
+	smsc  string
+	delay time.Duration
+	hold  bool
+
+	// mu protects the fields following.
+	mu      sync.Mutex
+	modems  map[string]*Modem
+	pending []Delivery
+	closed  bool
+}
+
+// Delivery is a message routed by the Network.
+type Delivery struct {
+	// From is the MSISDN of the sender.
+	From string
+
+	// To is the MSISDN of the recipient.
+	To string
+
+	// MR is the message reference assigned by the sender.
+	MR int
+
+	// SRR indicates the sender requested a status report.
+	SRR bool
+
+	// SCTS is the time the message was received by the SMSC.
+	SCTS time.Time
+
+	// TPDU is the binary SMS-DELIVER TPDU to be delivered to the recipient.
+	TPDU []byte
+}
+
+// NetworkOption modifies a Network created by NewNetwork.
+type NetworkOption func(*Network)
+
+// NewNetwork creates a simulated network.
+func NewNetwork(options ...NetworkOption) *Network {
+	n := &Network{
+		smsc:   DefaultSMSC,
+		modems: make(map[string]*Modem),
+	}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// DefaultSMSC is the SMSC address of a Network, unless overridden using
+// WithSMSCAddress.
+const DefaultSMSC = "+10000000000"
+
+// WithSMSCAddress sets the SMSC address used by the Modems attached to the
+// Network.
+func WithSMSCAddress(number string) NetworkOption {
+	return func(n *Network) {
+		n.smsc = number
+	}
+}
+
+// WithDeliveryDelay sets the delay between a message being sent and it being
+// delivered.
+//
+// The default is no delay.
+func WithDeliveryDelay(d time.Duration) NetworkOption {
+	return func(n *Network) {
+		n.delay = d
+	}
+}
+
+// WithHold holds messages at the SMSC until they are explicitly released by
+// Release or dropped by Discard.
+//
+// This allows the order in which messages, or segments of concatenated
+// messages, are delivered to be controlled, and for messages to be lost.
+func WithHold() NetworkOption {
+	return func(n *Network) {
+		n.hold = true
+	}
+}
+
+// Attach creates a Modem with the MSISDN and attaches it to the Network.
+//
+// The Modem has the SMSC address of the Network, and the messages it sends
+// are routed by the Network.  The options are applied to the Modem as per New.
+func (n *Network) Attach(msisdn string, options ...Option) *Modem {
+	opts := append([]Option{WithSMSC(n.smsc)}, options...)
+	opts = append(opts,
+		func(m *Modem) { m.identity.MSISDN = msisdn },
+		WithSubmitHandler(n.submit))
+	m := New(opts...)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.modems[msisdn] = m
+	return m
+}
+
+// Modem returns the Modem attached with the MSISDN, or nil if there is no such
+// Modem.
+func (n *Network) Modem(msisdn string) *Modem {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.modems[msisdn]
+}
+
+// Pending returns the messages held by the SMSC, in the order they were sent.
+func (n *Network) Pending() []Delivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Delivery{}, n.pending...)
+}
+
+// Release delivers the held messages with the indices into the list returned
+// by Pending, in the order provided.
+//
+// With no indices all held messages are delivered, in the order they were
+// sent.  Out of range indices are ignored.
+func (n *Network) Release(indices ...int) {
+	for _, d := range n.take(indices) {
+		n.deliver(d)
+	}
+}
+
+// Discard drops the held messages with the indices into the list returned by
+// Pending, as if they were lost by the network.
+//
+// With no indices all held messages are discarded.  Out of range indices are
+// ignored.
+func (n *Network) Discard(indices ...int) {
+	n.take(indices)
+}
+
+// take removes the held messages with the indices, returning them in the
+// order of the indices.
+func (n *Network) take(indices []int) []Delivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(indices) == 0 {
+		ds := n.pending
+		n.pending = nil
+		return ds
+	}
+	var ds []Delivery
+	taken := make(map[int]bool)
+	for _, i := range indices {
+		if i < 0 || i >= len(n.pending) || taken[i] {
+			continue
+		}
+		taken[i] = true
+		ds = append(ds, n.pending[i])
+	}
+	var remaining []Delivery
+	for i, d := range n.pending {
+		if !taken[i] {
+			remaining = append(remaining, d)
+		}
+	}
+	n.pending = remaining
+	return ds
+}
+
+// Close closes the Network and all the attached Modems.
+//
+// Held and delayed messages are discarded.
+func (n *Network) Close() error {
+	n.mu.Lock()
+	n.closed = true
+	n.pending = nil
+	modems := n.modems
+	n.mu.Unlock()
+	for _, m := range modems {
+		m.Close()
+	}
+	return nil
+}
+
+// submit is the SubmitHandler for the attached Modems.
+func (n *Network) submit(m *Modem, s Submit) error {
+	t, err := sms.Unmarshal(s.TPDU, sms.AsMO)
+	if err != nil || t.SmsType() != tpdu.SmsSubmit {
+		return at.CMSError("304")
+	}
+	now := time.Now()
+	dt := tpdu.TPDU{
+		OA:   tpdu.NewAddress(tpdu.FromNumber(m.identity.MSISDN)),
+		SCTS: tpdu.Timestamp{Time: now},
+		PID:  t.PID,
+		DCS:  t.DCS,
+		UDH:  t.UDH,
+		UD:   t.UD,
+	}
+	dt.SetSmsType(tpdu.SmsDeliver)
+	if t.UDHI() {
+		dt.FirstOctet |= tpdu.FoUDHI
+	}
+	if t.FirstOctet.SRR() {
+		dt.FirstOctet |= tpdu.FoSRI
+	}
+	tp, err := dt.MarshalBinary()
+	if err != nil {
+		return at.CMSError("304")
+	}
+	d := Delivery{
+		From: m.identity.MSISDN,
+		To:   t.DA.Number(),
+		MR:   s.MR,
+		SRR:  t.FirstOctet.SRR(),
+		SCTS: now,
+		TPDU: tp,
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return at.CMSError("331")
+	}
+	if n.hold {
+		n.pending = append(n.pending, d)
+		return nil
+	}
+	time.AfterFunc(n.delay, func() {
+		n.deliver(d)
+	})
+	return nil
+}
+
+// TP-ST status values reported in status reports.
+const (
+	// stDelivered indicates the message was received by the recipient.
+	stDelivered = 0x00
+
+	// stIncompatibleDestination indicates the recipient is unknown.
+	stIncompatibleDestination = 0x41
+
+	// stRejected indicates the recipient rejected the message, e.g. as its
+	// storage is full.
+	stRejected = 0x42
+)
+
+// deliver delivers the message to the recipient, and a status report to the
+// sender if requested.
+func (n *Network) deliver(d Delivery) {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	dst := n.modems[d.To]
+	src := n.modems[d.From]
+	n.mu.Unlock()
+	st := byte(stDelivered)
+	if dst == nil {
+		st = stIncompatibleDestination
+	} else if err := dst.Deliver(d.TPDU); err != nil {
+		st = stRejected
+	}
+	if !d.SRR || src == nil {
+		return
+	}
+	sr := tpdu.TPDU{
+		MR:   byte(d.MR),
+		RA:   tpdu.NewAddress(tpdu.FromNumber(d.To)),
+		SCTS: tpdu.Timestamp{Time: d.SCTS},
+		DT:   tpdu.Timestamp{Time: time.Now()},
+		ST:   st,
+	}
+	sr.SetSmsType(tpdu.SmsStatusReport)
+	tp, err := sr.MarshalBinary()
+	if err != nil {
+		return
+	}
+	src.DeliverReport(tp)
+}
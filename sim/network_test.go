@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+const (
+	alice = "+61400000001"
+	bob   = "+61400000002"
+)
+
+func TestNetworkAttach(t *testing.T) {
+	n := sim.NewNetwork(sim.WithSMSCAddress("+61411000000"))
+	defer n.Close()
+	m := n.Attach(alice, sim.WithSettings(sim.Settings{}))
+	assert.Equal(t, m, n.Modem(alice))
+	assert.Nil(t, n.Modem(bob))
+	write(t, m, "AT+CNUM;+CSCA?\r")
+	assert.Equal(t, "\r\n+CNUM: \"\",\""+alice+"\",145\r\n+CSCA: \"+61411000000\",145\r\n\r\nOK\r\n",
+		readResponse(t, m))
+}
+
+func TestNetworkShortMessage(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	a := newGSM(t, n, alice)
+	b := newGSM(t, n, bob)
+	msgs := startRx(t, b, nil)
+
+	_, err := a.SendShortMessage(bob, "hello bob")
+	require.Nil(t, err)
+	msg := waitMessage(t, msgs)
+	assert.Equal(t, alice, msg.Number)
+	assert.Equal(t, "hello bob", msg.Message)
+	assert.Equal(t, 1, n.Modem(bob).Acks())
+}
+
+func TestNetworkLongMessage(t *testing.T) {
+	long := strings.Repeat("0123456789", 40)
+	patterns := []struct {
+		name  string
+		order []int
+	}{
+		{"in order", nil},
+		{"reversed", []int{2, 1, 0}},
+		{"shuffled", []int{1, 2, 0}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork(sim.WithHold())
+			defer n.Close()
+			a := newGSM(t, n, alice)
+			b := newGSM(t, n, bob)
+			msgs := startRx(t, b, nil)
+
+			mrs, err := a.SendLongMessage(bob, long)
+			require.Nil(t, err)
+			assert.Equal(t, 3, len(mrs))
+			pending := n.Pending()
+			require.Equal(t, 3, len(pending))
+			for i, d := range pending {
+				assert.Equal(t, alice, d.From)
+				assert.Equal(t, bob, d.To)
+				assert.Equal(t, i+1, d.MR)
+			}
+			n.Release(p.order...)
+			assert.Empty(t, n.Pending())
+			msg := waitMessage(t, msgs)
+			assert.Equal(t, alice, msg.Number)
+			assert.Equal(t, long, msg.Message)
+			assert.Equal(t, 3, len(msg.TPDUs))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestNetworkLostSegment(t *testing.T) {
+	n := sim.NewNetwork(sim.WithHold())
+	defer n.Close()
+	a := newGSM(t, n, alice)
+	b := newGSM(t, n, bob)
+	errs := make(chan error, 1)
+	msgs := startRx(t, b, errs, gsm.WithReassemblyTimeout(50*time.Millisecond))
+
+	_, err := a.SendLongMessage(bob, strings.Repeat("0123456789", 40))
+	require.Nil(t, err)
+	n.Discard(1)
+	n.Release()
+	select {
+	case err := <-errs:
+		rto, ok := err.(gsm.ErrReassemblyTimeout)
+		require.True(t, ok, err)
+		require.Equal(t, 3, len(rto.TPDUs))
+		assert.NotNil(t, rto.TPDUs[0])
+		assert.Nil(t, rto.TPDUs[1])
+		assert.NotNil(t, rto.TPDUs[2])
+	case msg := <-msgs:
+		t.Errorf("unexpected message: %v", msg)
+	case <-time.After(time.Second):
+		t.Error("no reassembly timeout")
+	}
+}
+
+func TestNetworkDelay(t *testing.T) {
+	n := sim.NewNetwork(sim.WithDeliveryDelay(50 * time.Millisecond))
+	defer n.Close()
+	a := newGSM(t, n, alice)
+	b := newGSM(t, n, bob)
+	msgs := startRx(t, b, nil)
+	start := time.Now()
+	_, err := a.SendShortMessage(bob, "later")
+	require.Nil(t, err)
+	msg := waitMessage(t, msgs)
+	assert.Equal(t, "later", msg.Message)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestNetworkStored(t *testing.T) {
+	n := sim.NewNetwork()
+	defer n.Close()
+	a := newGSM(t, n, alice)
+	b := n.Attach(bob, sim.WithSettings(sim.Settings{CNMI: [5]int{1, 1, 0, 0, 0}, CPMS: [3]string{"SM", "SM", "ME"}}))
+	_, err := a.SendShortMessage(bob, "stored")
+	require.Nil(t, err)
+	assert.Equal(t, "\r\n+CMTI: \"ME\",1\r\n", read(t, b))
+	msgs := b.Messages("ME")
+	require.Equal(t, 1, len(msgs))
+	assert.Equal(t, sim.StatusRecUnread, msgs[0].Status)
+	tp, err := sms.Unmarshal(msgs[0].TPDU, sms.AsMT)
+	require.Nil(t, err)
+	assert.Equal(t, tpdu.SmsDeliver, tp.SmsType())
+	assert.Equal(t, alice, tp.OA.Number())
+}
+
+func TestNetworkStatusReport(t *testing.T) {
+	patterns := []struct {
+		name string
+		to   string
+		st   byte
+	}{
+		{"delivered", bob, 0x00},
+		{"unknown", "+61400000009", 0x41},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			n := sim.NewNetwork()
+			defer n.Close()
+			n.Attach(bob, sim.WithSettings(sim.Settings{}))
+			am := n.Attach(alice, sim.WithSettings(sim.Settings{CNMI: [5]int{1, 0, 0, 1, 0}}))
+			a := at.New(am, at.WithTimeout(100*time.Millisecond))
+			reports := make(chan []string, 1)
+			err := a.AddIndication("+CDS:", func(info []string) { reports <- info }, at.WithTrailingLine)
+			require.Nil(t, err)
+
+			tp := submitTPDU(t, p.to, "report please")
+			tp[0] |= tpdu.FoSRR
+			info, err := a.SMSCommand("+CMGS="+strconv.Itoa(len(tp)), pduHex(t, "", tp))
+			require.Nil(t, err)
+			assert.Equal(t, []string{"+CMGS: 1"}, info)
+			select {
+			case info := <-reports:
+				require.Equal(t, 2, len(info))
+				pdu, err := pdumode.UnmarshalHexString(info[1])
+				require.Nil(t, err)
+				sr, err := sms.Unmarshal(pdu.TPDU, sms.AsMT)
+				require.Nil(t, err)
+				assert.Equal(t, tpdu.SmsStatusReport, sr.SmsType())
+				assert.Equal(t, byte(1), sr.MR)
+				assert.Equal(t, p.to, sr.RA.Number())
+				assert.Equal(t, p.st, sr.ST)
+			case <-time.After(100 * time.Millisecond):
+				t.Error("no status report")
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
+// newGSM attaches a modem to the network and returns an initialised GSM in
+// PDU mode on that modem.
+func newGSM(t *testing.T, n *sim.Network, msisdn string) *gsm.GSM {
+	t.Helper()
+	m := n.Attach(msisdn)
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)), gsm.WithPDUMode)
+	require.Nil(t, g.Init())
+	return g
+}
+
+// startRx starts message reception on the GSM, returning the channel of
+// received messages.
+//
+// Errors are passed to errs, or are test errors if errs is nil.
+func startRx(t *testing.T, g *gsm.GSM, errs chan error, options ...gsm.RxOption) chan gsm.Message {
+	t.Helper()
+	msgs := make(chan gsm.Message, 1)
+	eh := func(err error) {
+		if errs == nil {
+			t.Errorf("unexpected rx error: %v", err)
+			return
+		}
+		errs <- err
+	}
+	require.Nil(t, g.StartMessageRx(func(msg gsm.Message) { msgs <- msg }, eh, options...))
+	return msgs
+}
+
+func waitMessage(t *testing.T, msgs chan gsm.Message) gsm.Message {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return gsm.Message{}
+}
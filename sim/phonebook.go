@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/modem/at"
+)
+
+// PhonebookEntry is an entry in a Modem phonebook.
+type PhonebookEntry struct {
+	// Index is the location of the entry in the phonebook.
+	Index int
+
+	// Number is the phone number.
+	Number string
+
+	// Type is the type of address, 145 for international numbers, else 129.
+	//
+	// If zero, the type is determined from the Number.
+	Type int
+
+	// Name is the text associated with the number.
+	Name string
+}
+
+// phonebook is a phonebook memory, such as the SIM phonebook.
+type phonebook struct {
+	capacity int
+	entries  map[int]*PhonebookEntry
+}
+
+func newPhonebook(capacity int) *phonebook {
+	return &phonebook{capacity: capacity, entries: make(map[int]*PhonebookEntry)}
+}
+
+// maxNumber and maxName are the maximum length of phonebook numbers and
+// names.
+const (
+	maxNumber = 40
+	maxName   = 18
+)
+
+// WithPhonebook adds the entries to the phonebook, e.g. "SM" or "ME".
+//
+// Entries with an Index of zero are added to the first free location.
+func WithPhonebook(mem string, entries ...PhonebookEntry) Option {
+	return func(m *Modem) {
+		pb, ok := m.pbs[mem]
+		if !ok {
+			pb = newPhonebook(250)
+			m.pbs[mem] = pb
+		}
+		for _, e := range entries {
+			pb.write(e)
+		}
+	}
+}
+
+// Phonebook returns a copy of the entries in the phonebook, e.g. "SM" or
+// "ME", in index order.
+func (m *Modem) Phonebook(mem string) []PhonebookEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pb, ok := m.pbs[mem]
+	if !ok {
+		return nil
+	}
+	var entries []PhonebookEntry
+	for _, e := range pb.list() {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+// write adds the entry to the phonebook, replacing any existing entry at that
+// index.
+func (pb *phonebook) write(e PhonebookEntry) (int, error) {
+	if e.Index == 0 {
+		for i := 1; i <= pb.capacity; i++ {
+			if _, ok := pb.entries[i]; !ok {
+				e.Index = i
+				break
+			}
+		}
+		if e.Index == 0 {
+			return 0, at.CMEError("20")
+		}
+	}
+	if e.Index < 1 || e.Index > pb.capacity {
+		return 0, at.CMEError("21")
+	}
+	if e.Type == 0 {
+		e.Type = 129
+		if strings.HasPrefix(e.Number, "+") {
+			e.Type = 145
+		}
+	}
+	pb.entries[e.Index] = &e
+	return e.Index, nil
+}
+
+// list returns the entries in the phonebook, in index order.
+func (pb *phonebook) list() []*PhonebookEntry {
+	entries := make([]*PhonebookEntry, 0, len(pb.entries))
+	for _, e := range pb.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Index < entries[j].Index })
+	return entries
+}
+
+func cpbsHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		pb := m.pbs[m.pbMem]
+		return []string{`+CPBS: "` + m.pbMem + `",` + itoa(len(pb.entries)) + "," + itoa(pb.capacity)}, nil
+	case OpSet:
+		p := cmd.Params()
+		if len(p) < 1 {
+			return nil, at.CMEError("50")
+		}
+		mem := strings.ToUpper(p[0])
+		if _, ok := m.pbs[mem]; !ok {
+			return nil, at.CMEError("3")
+		}
+		m.pbMem = mem
+		return nil, nil
+	case OpTest:
+		mems := make([]string, 0, len(m.pbs))
+		for mem := range m.pbs {
+			mems = append(mems, `"`+mem+`"`)
+		}
+		sort.Strings(mems)
+		return []string{"+CPBS: (" + strings.Join(mems, ",") + ")"}, nil
+	}
+	return nil, ErrError
+}
+
+func cpbrHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pb := m.pbs[m.pbMem]
+	switch cmd.Op {
+	case OpTest:
+		return []string{"+CPBR: (1-" + itoa(pb.capacity) + ")," + itoa(maxNumber) + "," + itoa(maxName)}, nil
+	case OpSet:
+	default:
+		return nil, ErrError
+	}
+	p, err := cmd.IntParams(0)
+	if err != nil || len(p) < 1 || len(p) > 2 {
+		return nil, at.CMEError("50")
+	}
+	first, last := p[0], p[0]
+	if len(p) > 1 {
+		last = p[1]
+	}
+	if first < 1 || last > pb.capacity || first > last {
+		return nil, at.CMEError("21")
+	}
+	var lines []string
+	for _, e := range pb.list() {
+		if e.Index < first || e.Index > last {
+			continue
+		}
+		lines = append(lines, "+CPBR: "+itoa(e.Index)+`,"`+e.Number+`",`+
+			itoa(e.Type)+`,"`+m.encodeText(e.Name)+`"`)
+	}
+	if len(lines) == 0 && len(p) == 1 {
+		return nil, at.CMEError("22")
+	}
+	return lines, nil
+}
+
+func cpbwHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pb := m.pbs[m.pbMem]
+	switch cmd.Op {
+	case OpTest:
+		return []string{"+CPBW: (1-" + itoa(pb.capacity) + ")," + itoa(maxNumber) +
+			",(129,145)," + itoa(maxName)}, nil
+	case OpSet:
+	default:
+		return nil, ErrError
+	}
+	p := cmd.Params()
+	if len(p) < 1 {
+		return nil, at.CMEError("50")
+	}
+	var e PhonebookEntry
+	if p[0] != "" {
+		idx, err := strconv.Atoi(p[0])
+		if err != nil {
+			return nil, at.CMEError("50")
+		}
+		e.Index = idx
+	}
+	if len(p) == 1 {
+		if _, ok := pb.entries[e.Index]; !ok {
+			return nil, at.CMEError("21")
+		}
+		delete(pb.entries, e.Index)
+		return nil, nil
+	}
+	e.Number = p[1]
+	if len(e.Number) > maxNumber {
+		return nil, at.CMEError("26")
+	}
+	if len(p) > 2 && p[2] != "" {
+		t, err := strconv.Atoi(p[2])
+		if err != nil {
+			return nil, at.CMEError("50")
+		}
+		e.Type = t
+	}
+	if len(p) > 3 {
+		name, err := m.decodeText(p[3])
+		if err != nil {
+			return nil, at.CMEError("50")
+		}
+		if len([]rune(name)) > maxName {
+			return nil, at.CMEError("24")
+		}
+		e.Name = name
+	}
+	_, err := pb.write(e)
+	return nil, err
+}
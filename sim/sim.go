@@ -0,0 +1,608 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+// Package sim provides a simulated GSM modem for testing.
+//
+// The Modem implements io.ReadWriter, so it can replace a physical modem
+// under the at and gsm packages, allowing code built on them to be tested
+// without hardware.
+//
+// The Modem parses the AT command lines written to it and dispatches the
+// commands to handlers, which may be overridden or extended using WithHandler.
+// The built-in handlers maintain the state of common settings, such as echo
+// (E), +CMGF, +CMEE, +CNMI, +CSCS, +CSDH and +CSMS, as well as SMS storage and
+// a phonebook.
+//
+// Unsolicited result codes may be injected using Inject, and SMS-DELIVERs
+// received from the network may be simulated using Deliver.
+//
+// Multiple Modems may be attached to a Network, which routes the messages sent
+// by one Modem to another, for end to end testing.
+package sim
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/warthog618/modem/at"
+)
+
+// Modem is a simulated GSM modem.
+type Modem struct {
+	latency     time.Duration
+	identity    Identity
+	handlers    map[string]Handler
+	smsHandlers map[string]SMSHandler
+	submit      SubmitHandler
+	defaults    Settings
+
+	// wmu serialises writes.
+	wmu sync.Mutex
+
+	// mu protects the fields following.
+	mu          sync.Mutex
+	settings    Settings
+	pin         string
+	locked      bool
+	creg        int
+	registered  int
+	rssi        int
+	ber         int
+	smsc        string
+	mr          int
+	pendingAcks int
+	sent        []Submit
+	acks        int
+	nacks       []byte
+	mems        map[string]*storage
+	pbs         map[string]*phonebook
+	pbMem       string
+	in          []byte
+	smsCmd      *Command
+	failures    map[string][]error
+	out         []chunk
+	changed     chan struct{}
+	closed      bool
+}
+
+// chunk is a block of data returned by Read.
+type chunk struct {
+	data  []byte
+	ready time.Time
+}
+
+// Settings are the modem settings modified by the built-in handlers.
+type Settings struct {
+	// Echo enables echoing of command lines, as per ATE.
+	Echo bool
+
+	// CMGF is the SMS format, 0 for PDU mode, 1 for text mode.
+	CMGF int
+
+	// CMEE is the error reporting mode, 0 for ERROR, 1 for numeric error
+	// codes, and 2 for verbose error codes.
+	CMEE int
+
+	// CNMI is the new message indication mode: mode, mt, bm, ds and bfr.
+	CNMI [5]int
+
+	// CSDH enables the display of the full header in text mode, 0 or 1.
+	CSDH int
+
+	// CSCS is the TE character set, e.g. "IRA", "GSM" or "UCS2".
+	CSCS string
+
+	// CSMS is the message service, 0 or 1.
+	//
+	// When 1, messages forwarded by +CMT indications must be acknowledged
+	// using +CNMA.
+	CSMS int
+
+	// CPMS is the preferred message storage for reading and deleting,
+	// writing and sending, and receiving.
+	//
+	// Empty storage names default to "SM".
+	CPMS [3]string
+}
+
+// DefaultSettings are the settings of a Modem after New or a reset, unless
+// overridden using WithSettings.
+var DefaultSettings = Settings{
+	Echo: true,
+	CSCS: "IRA",
+	CPMS: [3]string{"SM", "SM", "SM"},
+}
+
+// Identity is the identity reported by the Modem.
+type Identity struct {
+	Manufacturer string
+	Model        string
+	Revision     string
+	IMEI         string
+	IMSI         string
+
+	// MSISDN is the subscriber number returned by +CNUM, if any.
+	MSISDN string
+}
+
+// DefaultIdentity is the identity reported by a Modem, unless overridden
+// using WithIdentity.
+var DefaultIdentity = Identity{
+	Manufacturer: "warthog618",
+	Model:        "sim",
+	Revision:     "1.0",
+	IMEI:         "123456789012347",
+	IMSI:         "001010123456789",
+}
+
+// Option modifies a Modem created by New.
+type Option func(*Modem)
+
+// New creates a simulated modem.
+func New(options ...Option) *Modem {
+	m := &Modem{
+		identity:    DefaultIdentity,
+		handlers:    make(map[string]Handler),
+		smsHandlers: make(map[string]SMSHandler),
+		defaults:    DefaultSettings,
+		registered:  1,
+		rssi:        20,
+		ber:         99,
+		mems: map[string]*storage{
+			"SM": newStorage(30),
+			"ME": newStorage(100),
+		},
+		pbs: map[string]*phonebook{
+			"SM": newPhonebook(250),
+			"ME": newPhonebook(500),
+		},
+		pbMem:    "SM",
+		failures: make(map[string][]error),
+		changed:  make(chan struct{}),
+	}
+	for k, v := range builtinHandlers {
+		m.handlers[k] = v
+	}
+	for k, v := range builtinSMSHandlers {
+		m.smsHandlers[k] = v
+	}
+	for _, option := range options {
+		option(m)
+	}
+	for i, mem := range m.defaults.CPMS {
+		if mem == "" {
+			m.defaults.CPMS[i] = "SM"
+		}
+	}
+	m.settings = m.defaults
+	m.locked = m.pin != ""
+	return m
+}
+
+// WithLatency sets the delay between a command being written to the Modem and
+// the response becoming available to Read.
+//
+// The default is no delay.
+func WithLatency(d time.Duration) Option {
+	return func(m *Modem) {
+		m.latency = d
+	}
+}
+
+// WithSettings sets the initial settings of the Modem, which are also
+// restored by ATZ and AT&F.
+func WithSettings(s Settings) Option {
+	return func(m *Modem) {
+		m.defaults = s
+	}
+}
+
+// WithIdentity sets the identity reported by the Modem.
+func WithIdentity(id Identity) Option {
+	return func(m *Modem) {
+		m.identity = id
+	}
+}
+
+// WithPIN locks the SIM with the PIN, which must be provided via +CPIN before
+// commands requiring the SIM are accepted.
+func WithPIN(pin string) Option {
+	return func(m *Modem) {
+		m.pin = pin
+	}
+}
+
+// WithSMSC sets the SMSC address, as per +CSCA.
+func WithSMSC(number string) Option {
+	return func(m *Modem) {
+		m.smsc = number
+	}
+}
+
+// WithHandler sets the handler for the command, overriding any built-in
+// handler.
+//
+// The name is the command name, e.g. "+CSQ" or "I", in upper case.
+func WithHandler(name string, h Handler) Option {
+	return func(m *Modem) {
+		m.handlers[name] = h
+	}
+}
+
+// WithSMSHandler sets the handler for a command that prompts for a message
+// body, such as +CMGS, overriding any built-in handler.
+func WithSMSHandler(name string, h SMSHandler) Option {
+	return func(m *Modem) {
+		m.smsHandlers[name] = h
+	}
+}
+
+// WithSubmitHandler sets the handler called when a message is sent using
+// +CMGS or +CMSS.
+//
+// By default sent messages are discarded.
+func WithSubmitHandler(h SubmitHandler) Option {
+	return func(m *Modem) {
+		m.submit = h
+	}
+}
+
+var (
+	// ErrClosed indicates the Modem has been closed.
+	ErrClosed = errors.New("closed")
+
+	// ErrError is returned by a handler to produce a plain ERROR result code,
+	// irrespective of the +CMEE setting.
+	ErrError = errors.New("ERROR")
+)
+
+// Read returns the data output by the modem.
+//
+// Read blocks until data is available, and returns io.EOF once the Modem is
+// closed.
+func (m *Modem) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	for {
+		if m.closed {
+			m.mu.Unlock()
+			return 0, io.EOF
+		}
+		if len(m.out) > 0 {
+			c := &m.out[0]
+			if wait := time.Until(c.ready); wait > 0 {
+				changed := m.changed
+				m.mu.Unlock()
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-changed:
+					t.Stop()
+				}
+				m.mu.Lock()
+				continue
+			}
+			n := copy(p, c.data)
+			c.data = c.data[n:]
+			if len(c.data) == 0 {
+				m.out = m.out[1:]
+			}
+			m.mu.Unlock()
+			return n, nil
+		}
+		changed := m.changed
+		m.mu.Unlock()
+		<-changed
+		m.mu.Lock()
+	}
+}
+
+// Write passes data to the modem.
+//
+// Complete command lines, and message bodies following a prompt, are
+// processed before Write returns, though the response may be delayed by the
+// latency.
+func (m *Modem) Write(p []byte) (int, error) {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return 0, ErrClosed
+	}
+	if m.settings.Echo {
+		m.queue(p, 0)
+	}
+	m.in = append(m.in, p...)
+	m.mu.Unlock()
+	m.process()
+	return len(p), nil
+}
+
+// Close closes the Modem, unblocking any pending reads.
+func (m *Modem) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.changed)
+	}
+	return nil
+}
+
+// Inject outputs the lines as an unsolicited result code.
+//
+// The lines are output immediately, e.g. Inject("RING") outputs "\r\nRING\r\n".
+func (m *Modem) Inject(lines ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue([]byte(formatLines(lines)), 0)
+}
+
+// Settings returns the current settings.
+func (m *Modem) Settings() Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settings
+}
+
+// SetRegistration sets the network registration status, as reported by
+// +CREG, e.g. 0 not registered, 1 registered on the home network, 5 roaming.
+//
+// A +CREG unsolicited result code is output if enabled and the status changes.
+func (m *Modem) SetRegistration(stat int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stat != m.registered && m.creg != 0 {
+		m.queue([]byte(formatLines([]string{"+CREG: " + itoa(stat)})), 0)
+	}
+	m.registered = stat
+}
+
+// SetSignal sets the signal quality, as reported by +CSQ.
+func (m *Modem) SetSignal(rssi, ber int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rssi = rssi
+	m.ber = ber
+}
+
+// FailNext causes the next execution of the named command to fail with the
+// error, rather than being passed to its handler.
+//
+// Errors for the same command are queued, so calling FailNext twice fails the
+// next two executions.  For commands that prompt for a message body, such as
+// +CMGS, the error is returned once the body has been received.
+func (m *Modem) FailNext(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[name] = append(m.failures[name], err)
+}
+
+// failure returns the next error queued for the command by FailNext, if any.
+func (m *Modem) failure(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	errs := m.failures[name]
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		delete(m.failures, name)
+	} else {
+		m.failures[name] = errs[1:]
+	}
+	return errs[0]
+}
+
+// queue adds the data to the output, to be returned by Read after the delay.
+//
+// Must be called with the lock held.
+func (m *Modem) queue(data []byte, delay time.Duration) {
+	if m.closed || len(data) == 0 {
+		return
+	}
+	m.out = append(m.out, chunk{append([]byte{}, data...), time.Now().Add(delay)})
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// process executes any complete command lines or message bodies in the input.
+func (m *Modem) process() {
+	for {
+		m.mu.Lock()
+		if cmd := m.smsCmd; cmd != nil {
+			i := bytes.IndexAny(m.in, "\x1a\x1b")
+			if i < 0 {
+				m.mu.Unlock()
+				return
+			}
+			body := strings.TrimLeft(string(m.in[:i]), "\n")
+			term := m.in[i]
+			m.in = m.in[i+1:]
+			m.smsCmd = nil
+			h := m.smsHandlers[cmd.Name]
+			m.mu.Unlock()
+			if term == 0x1b {
+				// cancelled
+				continue
+			}
+			if err := m.failure(cmd.Name); err != nil {
+				m.respond(nil, err)
+				continue
+			}
+			info, err := h(m, *cmd, body)
+			m.respond(info, err)
+			continue
+		}
+		i := bytes.IndexByte(m.in, '\r')
+		if i < 0 {
+			m.mu.Unlock()
+			return
+		}
+		line := strings.TrimLeft(string(m.in[:i]), "\n\x1b ")
+		m.in = m.in[i+1:]
+		m.mu.Unlock()
+		m.execLine(line)
+	}
+}
+
+// execLine executes the commands in a command line.
+func (m *Modem) execLine(line string) {
+	if len(line) < 2 || !strings.EqualFold(line[:2], "AT") {
+		// not a command line, so ignore it
+		return
+	}
+	cmds, err := parseCommandLine(line[2:])
+	if err != nil {
+		m.respond(nil, ErrError)
+		return
+	}
+	var info []string
+	for i, cmd := range cmds {
+		m.mu.Lock()
+		sh := m.smsHandlers[cmd.Name]
+		h := m.handlers[cmd.Name]
+		locked := m.locked && !unlockedCmds[cmd.Name]
+		m.mu.Unlock()
+		if locked {
+			m.respond(nil, at.CMEError("11"))
+			return
+		}
+		if sh != nil && cmd.Op == OpSet && i == len(cmds)-1 && prompts(cmd) {
+			m.mu.Lock()
+			m.smsCmd = &cmd
+			m.queue([]byte("\r\n> "), m.latency)
+			m.mu.Unlock()
+			return
+		}
+		if h == nil {
+			m.respond(nil, ErrError)
+			return
+		}
+		if err := m.failure(cmd.Name); err != nil {
+			m.respond(nil, err)
+			return
+		}
+		ci, err := h(m, cmd)
+		if err != nil {
+			m.respond(nil, err)
+			return
+		}
+		info = append(info, ci...)
+	}
+	m.respond(info, nil)
+}
+
+// respond outputs the response to a command.
+func (m *Modem) respond(info []string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rsp := formatLines(info) + formatLines([]string{m.resultCode(err)})
+	m.queue([]byte(rsp), m.latency)
+}
+
+// resultCode returns the final result code corresponding to the error.
+//
+// Must be called with the lock held.
+func (m *Modem) resultCode(err error) string {
+	if err == nil {
+		return "OK"
+	}
+	var cme at.CMEError
+	if errors.As(err, &cme) {
+		switch m.settings.CMEE {
+		case 1:
+			return "+CME ERROR: " + string(cme)
+		case 2:
+			if text, ok := cmeText[string(cme)]; ok {
+				return "+CME ERROR: " + text
+			}
+			return "+CME ERROR: " + string(cme)
+		}
+		return "ERROR"
+	}
+	var cms at.CMSError
+	if errors.As(err, &cms) {
+		switch m.settings.CMEE {
+		case 1:
+			return "+CMS ERROR: " + string(cms)
+		case 2:
+			if text, ok := cmsText[string(cms)]; ok {
+				return "+CMS ERROR: " + text
+			}
+			return "+CMS ERROR: " + string(cms)
+		}
+		return "ERROR"
+	}
+	return "ERROR"
+}
+
+// formatLines formats lines as output by the modem.
+func formatLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\r\n" + strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// unlockedCmds are the commands that may be executed while the SIM is locked.
+var unlockedCmds = map[string]bool{
+	"":       true,
+	"E":      true,
+	"Z":      true,
+	"&F":     true,
+	"I":      true,
+	"+GCAP":  true,
+	"+CGMI":  true,
+	"+CGMM":  true,
+	"+CGMR":  true,
+	"+CGSN":  true,
+	"+CMEE":  true,
+	"+CPIN":  true,
+	"+CSQ":   true,
+	"+CREG":  true,
+	"+CFUN":  true,
+	"+CSCS":  true,
+	"+CMGF":  true,
+	"+CPINR": true,
+}
+
+// cmeText is the verbose form of common CME error codes.
+var cmeText = map[string]string{
+	"3":   "operation not allowed",
+	"4":   "operation not supported",
+	"10":  "SIM not inserted",
+	"11":  "SIM PIN required",
+	"12":  "SIM PUK required",
+	"16":  "incorrect password",
+	"20":  "memory full",
+	"21":  "invalid index",
+	"22":  "not found",
+	"24":  "text string too long",
+	"26":  "dial string too long",
+	"30":  "no network service",
+	"50":  "incorrect parameters",
+	"100": "unknown",
+}
+
+// cmsText is the verbose form of common CMS error codes.
+var cmsText = map[string]string{
+	"300": "ME failure",
+	"302": "operation not allowed",
+	"303": "operation not supported",
+	"304": "invalid PDU mode parameter",
+	"305": "invalid text mode parameter",
+	"310": "SIM not inserted",
+	"311": "SIM PIN required",
+	"321": "invalid memory index",
+	"322": "memory full",
+	"330": "SMSC address unknown",
+	"331": "no network service",
+	"340": "no +CNMA acknowledgement expected",
+	"500": "unknown error",
+}
@@ -0,0 +1,328 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestCommands(t *testing.T) {
+	patterns := []struct {
+		name    string
+		options []sim.Option
+		cmds    []string
+		rsps    []string
+	}{
+		{
+			"empty",
+			nil,
+			[]string{"AT"},
+			[]string{"\r\nOK\r\n"},
+		},
+		{
+			"echo",
+			[]sim.Option{sim.WithSettings(sim.DefaultSettings)},
+			[]string{"ATE0", "ATI"},
+			[]string{"ATE0\r\r\nOK\r\n", "\r\nManufacturer: warthog618\r\nModel: sim\r\nRevision: 1.0\r\n" +
+				"IMEI: 123456789012347\r\n+GCAP: +CGSM,+DS,+ES\r\n\r\nOK\r\n"},
+		},
+		{
+			"reset",
+			nil,
+			[]string{"ATE1", "ATZ", "AT&F"},
+			[]string{"\r\nOK\r\n", "ATZ\r\r\nOK\r\n", "\r\nOK\r\n"},
+		},
+		{
+			"unknown",
+			nil,
+			[]string{"AT+FOO", "ATX1", "AT+CMEE=1;+FOO"},
+			[]string{"\r\nERROR\r\n", "\r\nERROR\r\n", "\r\nERROR\r\n"},
+		},
+		{
+			"not a command",
+			nil,
+			[]string{"hello", "AT"},
+			[]string{"\r\nOK\r\n"},
+		},
+		{
+			"multiple",
+			nil,
+			[]string{"AT+CMGF=1;+CMGF?;E0", "at+cmgf?"},
+			[]string{"\r\n+CMGF: 1\r\n\r\nOK\r\n", "\r\n+CMGF: 1\r\n\r\nOK\r\n"},
+		},
+		{
+			"cmee",
+			nil,
+			[]string{"AT+CMEE=3", "AT+CMEE=1", "AT+CMEE=3", "AT+CMEE=2", "AT+CMEE=3", "AT+CMEE?", "AT+CMEE=?"},
+			[]string{
+				"\r\nERROR\r\n",
+				"\r\nOK\r\n",
+				"\r\n+CME ERROR: 50\r\n",
+				"\r\nOK\r\n",
+				"\r\n+CME ERROR: incorrect parameters\r\n",
+				"\r\n+CMEE: 2\r\n\r\nOK\r\n",
+				"\r\n+CMEE: (0-2)\r\n\r\nOK\r\n",
+			},
+		},
+		{
+			"cnmi",
+			nil,
+			[]string{"AT+CNMI=1,2,0,1,0", "AT+CNMI?", "AT+CNMI=1,4", "AT+CNMI=2", "AT+CNMI?"},
+			[]string{
+				"\r\nOK\r\n",
+				"\r\n+CNMI: 1,2,0,1,0\r\n\r\nOK\r\n",
+				"\r\nERROR\r\n",
+				"\r\nOK\r\n",
+				"\r\n+CNMI: 2,0,0,0,0\r\n\r\nOK\r\n",
+			},
+		},
+		{
+			"cscs",
+			nil,
+			[]string{"AT+CSCS?", "AT+CSCS=\"ucs2\"", "AT+CSCS?", "AT+CSCS=\"8859-1\"", "AT+CSCS=?"},
+			[]string{
+				"\r\n+CSCS: \"IRA\"\r\n\r\nOK\r\n",
+				"\r\nOK\r\n",
+				"\r\n+CSCS: \"UCS2\"\r\n\r\nOK\r\n",
+				"\r\nERROR\r\n",
+				"\r\n+CSCS: (\"IRA\",\"GSM\",\"UCS2\")\r\n\r\nOK\r\n",
+			},
+		},
+		{
+			"csms",
+			nil,
+			[]string{"AT+CSMS?", "AT+CSMS=1", "AT+CSMS?"},
+			[]string{
+				"\r\n+CSMS: 0,1,1,1\r\n\r\nOK\r\n",
+				"\r\n+CSMS: 1,1,1\r\n\r\nOK\r\n",
+				"\r\n+CSMS: 1,1,1,1\r\n\r\nOK\r\n",
+			},
+		},
+		{
+			"identity",
+			[]sim.Option{sim.WithIdentity(sim.Identity{Manufacturer: "acme", IMEI: "1234", IMSI: "5678"})},
+			[]string{"AT+CGMI", "AT+CGSN", "AT+CIMI", "AT+GCAP"},
+			[]string{
+				"\r\nacme\r\n\r\nOK\r\n",
+				"\r\n1234\r\n\r\nOK\r\n",
+				"\r\n5678\r\n\r\nOK\r\n",
+				"\r\n+GCAP: +CGSM,+DS,+ES\r\n\r\nOK\r\n",
+			},
+		},
+		{
+			"pin",
+			[]sim.Option{sim.WithPIN("1234"), sim.WithSettings(sim.Settings{CMEE: 1})},
+			[]string{"AT+CPIN?", "AT+CIMI", "AT+CPIN=\"4321\"", "AT+CPIN=\"1234\"", "AT+CPIN?", "AT+CIMI"},
+			[]string{
+				"\r\n+CPIN: SIM PIN\r\n\r\nOK\r\n",
+				"\r\n+CME ERROR: 11\r\n",
+				"\r\n+CME ERROR: 16\r\n",
+				"\r\nOK\r\n",
+				"\r\n+CPIN: READY\r\n\r\nOK\r\n",
+				"\r\n001010123456789\r\n\r\nOK\r\n",
+			},
+		},
+		{
+			"handler",
+			[]sim.Option{
+				sim.WithHandler("+CSQ", sim.Reply("+CSQ: 31,0")),
+				sim.WithHandler("+FOO", sim.Fail(at.CMEError("4"))),
+				sim.WithSettings(sim.Settings{CMEE: 2}),
+			},
+			[]string{"AT+CSQ", "AT+FOO"},
+			[]string{
+				"\r\n+CSQ: 31,0\r\n\r\nOK\r\n",
+				"\r\n+CME ERROR: operation not supported\r\n",
+			},
+		},
+		{
+			"custom handler",
+			[]sim.Option{
+				sim.WithHandler("+CUSD", func(m *sim.Modem, cmd sim.Command) ([]string, error) {
+					p := cmd.Params()
+					m.Inject(`+CUSD: 0,"balance for ` + p[1] + `",15`)
+					return nil, nil
+				}),
+			},
+			[]string{"AT+CUSD=1,\"*123#\",15"},
+			[]string{"\r\n+CUSD: 0,\"balance for *123#\",15\r\n\r\nOK\r\n"},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			s := sim.DefaultSettings
+			s.Echo = false
+			opts := append([]sim.Option{sim.WithSettings(s)}, p.options...)
+			m := sim.New(opts...)
+			defer m.Close()
+			rsps := p.rsps
+			for _, cmd := range p.cmds {
+				write(t, m, cmd+"\r")
+				if !strings.HasPrefix(strings.ToUpper(cmd), "AT") {
+					continue
+				}
+				require.NotEmpty(t, rsps, cmd)
+				assert.Equal(t, rsps[0], readResponse(t, m), cmd)
+				rsps = rsps[1:]
+			}
+			assert.Empty(t, rsps)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{}))
+	defer m.Close()
+	write(t, m, "AT+CREG?\r")
+	assert.Equal(t, "\r\n+CREG: 0,1\r\n\r\nOK\r\n", readResponse(t, m))
+
+	// disabled
+	m.SetRegistration(0)
+	write(t, m, "AT+CREG?\r")
+	assert.Equal(t, "\r\n+CREG: 0,0\r\n\r\nOK\r\n", readResponse(t, m))
+
+	// enabled
+	write(t, m, "AT+CREG=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	m.SetRegistration(5)
+	assert.Equal(t, "\r\n+CREG: 5\r\n", read(t, m))
+
+	// signal
+	m.SetSignal(12, 3)
+	write(t, m, "AT+CSQ\r")
+	assert.Equal(t, "\r\n+CSQ: 12,3\r\n\r\nOK\r\n", readResponse(t, m))
+}
+
+func TestInject(t *testing.T) {
+	m := sim.New()
+	defer m.Close()
+	m.Inject("RING")
+	assert.Equal(t, "\r\nRING\r\n", read(t, m))
+	m.Inject("+CMT: ,3", "000000")
+	assert.Equal(t, "\r\n+CMT: ,3\r\n000000\r\n", read(t, m))
+}
+
+func TestFailNext(t *testing.T) {
+	m := sim.New(sim.WithSettings(sim.Settings{CMEE: 1}))
+	defer m.Close()
+	m.FailNext("+CSQ", at.CMEError("30"))
+	m.FailNext("+CSQ", sim.ErrError)
+	m.FailNext("+CMGS", at.CMSError("500"))
+	write(t, m, "AT+CSQ\r")
+	assert.Equal(t, "\r\n+CME ERROR: 30\r\n", readResponse(t, m))
+	write(t, m, "AT+CSQ\r")
+	assert.Equal(t, "\r\nERROR\r\n", readResponse(t, m))
+	write(t, m, "AT+CSQ\r")
+	assert.Equal(t, "\r\n+CSQ: 20,99\r\n\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMGS=3\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, "00\x1a")
+	assert.Equal(t, "\r\n+CMS ERROR: 500\r\n", readResponse(t, m))
+}
+
+func TestLatency(t *testing.T) {
+	m := sim.New(sim.WithLatency(50*time.Millisecond), sim.WithSettings(sim.Settings{Echo: true}))
+	defer m.Close()
+	start := time.Now()
+	write(t, m, "AT\r")
+	// echo is immediate
+	assert.Equal(t, "AT\r", read(t, m))
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+	assert.Equal(t, "\r\nOK\r\n", read(t, m))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestClose(t *testing.T) {
+	m := sim.New()
+	done := make(chan error)
+	go func() {
+		b := make([]byte, 10)
+		_, err := m.Read(b)
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Error("read not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Nil(t, m.Close())
+	select {
+	case err := <-done:
+		assert.Equal(t, io.EOF, err)
+	case <-time.After(100 * time.Millisecond):
+		t.Error("read still blocked")
+	}
+	assert.Nil(t, m.Close())
+	_, err := m.Write([]byte("AT\r"))
+	assert.Equal(t, sim.ErrClosed, err)
+}
+
+func TestWithAT(t *testing.T) {
+	m := sim.New(sim.WithLatency(time.Millisecond))
+	defer m.Close()
+	a := at.New(m, at.WithTimeout(100*time.Millisecond))
+	require.Nil(t, a.Init())
+	assert.False(t, m.Settings().Echo)
+	info, err := a.Command("+CGMI")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"warthog618"}, info)
+	_, err = a.Command("+CMEE=1")
+	assert.Nil(t, err)
+	_, err = a.Command("+CPBR=1")
+	assert.Equal(t, at.CMEError("22"), err)
+}
+
+// write writes the data to the modem.
+func write(t *testing.T, m *sim.Modem, data string) {
+	t.Helper()
+	n, err := m.Write([]byte(data))
+	require.Nil(t, err)
+	require.Equal(t, len(data), n)
+}
+
+// read returns the next chunk of output from the modem.
+func read(t *testing.T, m *sim.Modem) string {
+	t.Helper()
+	done := make(chan string)
+	go func() {
+		b := make([]byte, 1024)
+		n, _ := m.Read(b)
+		done <- string(b[:n])
+	}()
+	select {
+	case s := <-done:
+		return s
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("read timed out")
+	}
+	return ""
+}
+
+// readResponse returns the output from the modem up to and including a final
+// result code or prompt.
+func readResponse(t *testing.T, m *sim.Modem) string {
+	t.Helper()
+	var sb strings.Builder
+	for {
+		sb.WriteString(read(t, m))
+		s := sb.String()
+		if strings.HasSuffix(s, "OK\r\n") ||
+			strings.HasSuffix(s, "ERROR\r\n") ||
+			strings.HasSuffix(s, "> ") ||
+			strings.Contains(s, "ERROR: ") {
+			return s
+		}
+	}
+}
@@ -0,0 +1,910 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+)
+
+// Status is the status of a stored message.
+type Status int
+
+const (
+	// StatusRecUnread is a received message that has not been read.
+	StatusRecUnread Status = iota
+
+	// StatusRecRead is a received message that has been read.
+	StatusRecRead
+
+	// StatusStoUnsent is a stored message that has not been sent.
+	StatusStoUnsent
+
+	// StatusStoSent is a stored message that has been sent.
+	StatusStoSent
+
+	// statusAll selects all messages when listing.
+	statusAll
+)
+
+var statusText = []string{"REC UNREAD", "REC READ", "STO UNSENT", "STO SENT", "ALL"}
+
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusText) {
+		return "unknown"
+	}
+	return statusText[s]
+}
+
+// Message is a message held in the Modem message storage.
+type Message struct {
+	// Index is the location of the message in the storage.
+	Index int
+
+	// Status is the status of the message.
+	Status Status
+
+	// SMSC is the number of the SMSC, if known.
+	SMSC string
+
+	// TPDU is the binary SMS-DELIVER or SMS-SUBMIT TPDU.
+	TPDU []byte
+}
+
+// Submit is a message sent by the Modem.
+type Submit struct {
+	// MR is the message reference assigned by the Modem.
+	MR int
+
+	// Number is the destination address.
+	Number string
+
+	// Text is the message, if sent in text mode.
+	Text string
+
+	// SMSC is the number of the SMSC, if provided in the PDU or set by
+	// +CSCA.
+	SMSC string
+
+	// TPDU is the binary SMS-SUBMIT TPDU.
+	//
+	// For messages sent in text mode this is generated from the Number and
+	// Text.
+	TPDU []byte
+}
+
+// SubmitHandler handles a message sent by the Modem.
+//
+// Returning an error fails the send command with that error, so the handler
+// may return an at.CMSError to simulate a network failure.
+type SubmitHandler func(m *Modem, s Submit) error
+
+// builtinSMSHandlers are the handlers for commands prompting for a message
+// body.
+var builtinSMSHandlers = map[string]SMSHandler{
+	"+CMGS": cmgsHandler,
+	"+CMGW": cmgwHandler,
+	"+CNMA": cnmaPDUHandler,
+}
+
+// prompts returns true if the command prompts for a message body.
+//
+// +CNMA only prompts for a PDU if given a length.
+func prompts(cmd Command) bool {
+	if cmd.Name == "+CNMA" {
+		return len(cmd.Params()) > 1
+	}
+	return true
+}
+
+// storage is a message storage, such as the SIM.
+type storage struct {
+	capacity int
+	msgs     map[int]*Message
+}
+
+func newStorage(capacity int) *storage {
+	return &storage{capacity: capacity, msgs: make(map[int]*Message)}
+}
+
+// add adds the message to the first free location in the storage.
+func (s *storage) add(msg Message) (int, error) {
+	for i := 1; i <= s.capacity; i++ {
+		if _, ok := s.msgs[i]; !ok {
+			msg.Index = i
+			s.msgs[i] = &msg
+			return i, nil
+		}
+	}
+	return 0, at.CMSError("322")
+}
+
+// list returns the messages in the storage, in index order.
+func (s *storage) list() []*Message {
+	msgs := make([]*Message, 0, len(s.msgs))
+	for _, msg := range s.msgs {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Index < msgs[j].Index })
+	return msgs
+}
+
+// Messages returns a copy of the messages in the storage, e.g. "SM" or "ME",
+// in index order.
+func (m *Modem) Messages(mem string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.mems[mem]
+	if !ok {
+		return nil
+	}
+	var msgs []Message
+	for _, msg := range s.list() {
+		msgs = append(msgs, *msg)
+	}
+	return msgs
+}
+
+// StoreMessage adds a message, with the binary TPDU, to the storage, e.g.
+// "SM" or "ME", returning the index of the stored message.
+func (m *Modem) StoreMessage(mem string, status Status, tp []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.mems[mem]
+	if !ok {
+		return 0, at.CMSError("302")
+	}
+	return s.add(Message{Status: status, SMSC: m.smsc, TPDU: tp})
+}
+
+// Sent returns the messages sent by the Modem.
+func (m *Modem) Sent() []Submit {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Submit(nil), m.sent...)
+}
+
+// Acks returns the number of +CMT indications acknowledged with +CNMA.
+func (m *Modem) Acks() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acks
+}
+
+// Nacks returns the TP-FCS of each +CMT indication rejected with +CNMA=2.
+func (m *Modem) Nacks() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]byte(nil), m.nacks...)
+}
+
+// PendingAcks returns the number of +CMT indications awaiting
+// acknowledgement.
+func (m *Modem) PendingAcks() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pendingAcks
+}
+
+// Deliver simulates the receipt of an SMS-DELIVER from the network.
+//
+// The binary TPDU is routed as per the +CNMI setting - either forwarded
+// directly via a +CMT indication, or stored in the receive storage and
+// optionally indicated via a +CMTI indication.
+//
+// Returns an error if the message cannot be stored.
+func (m *Modem) Deliver(tp []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mode, mt := m.settings.CNMI[0], m.settings.CNMI[1]
+	if mode != 0 && (mt == 2 || mt == 3) {
+		lines, err := m.cmt(tp)
+		if err != nil {
+			return err
+		}
+		m.queue([]byte(formatLines(lines)), 0)
+		if m.settings.CSMS == 1 {
+			m.pendingAcks++
+		}
+		return nil
+	}
+	mem := m.settings.CPMS[2]
+	idx, err := m.mems[mem].add(Message{Status: StatusRecUnread, SMSC: m.smsc, TPDU: tp})
+	if err != nil {
+		return err
+	}
+	if mode != 0 && mt == 1 {
+		m.queue([]byte(formatLines([]string{`+CMTI: "` + mem + `",` + itoa(idx)})), 0)
+	}
+	return nil
+}
+
+// DeliverReport simulates the receipt of an SMS-STATUS-REPORT from the
+// network.
+//
+// The binary TPDU is routed as per the +CNMI setting - either forwarded
+// directly via a +CDS indication, or stored in the receive storage and
+// indicated via a +CDSI indication.  Reports are discarded if not enabled by
+// +CNMI.
+//
+// Returns an error if the report cannot be stored.
+func (m *Modem) DeliverReport(tp []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mode, ds := m.settings.CNMI[0], m.settings.CNMI[3]
+	if mode == 0 || ds == 0 {
+		return nil
+	}
+	if ds == 1 {
+		lines, err := m.cds(tp)
+		if err != nil {
+			return err
+		}
+		m.queue([]byte(formatLines(lines)), 0)
+		if m.settings.CSMS == 1 {
+			m.pendingAcks++
+		}
+		return nil
+	}
+	mem := m.settings.CPMS[2]
+	idx, err := m.mems[mem].add(Message{Status: StatusRecUnread, SMSC: m.smsc, TPDU: tp})
+	if err != nil {
+		return err
+	}
+	m.queue([]byte(formatLines([]string{`+CDSI: "` + mem + `",` + itoa(idx)})), 0)
+	return nil
+}
+
+// cds returns the lines of a +CDS indication for the TPDU.
+//
+// Must be called with the lock held.
+func (m *Modem) cds(tp []byte) ([]string, error) {
+	if m.settings.CMGF == 0 {
+		h, err := pduHex(m.smsc, tp)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"+CDS: " + itoa(len(tp)), h}, nil
+	}
+	t, err := sms.Unmarshal(tp, sms.AsMT)
+	if err != nil {
+		return nil, err
+	}
+	if t.SmsType() != tpdu.SmsStatusReport {
+		return nil, at.CMSError("304")
+	}
+	ra := t.RA.Number()
+	tora := 129
+	if strings.HasPrefix(ra, "+") {
+		tora = 145
+	}
+	return []string{"+CDS: " + itoa(int(t.FirstOctet)) + "," + itoa(int(t.MR)) + `,"` +
+		m.encodeText(ra) + `",` + itoa(tora) + `,"` + formatSCTS(t.SCTS.Time) + `","` +
+		formatSCTS(t.DT.Time) + `",` + itoa(int(t.ST))}, nil
+}
+
+// cmt returns the lines of a +CMT indication for the TPDU.
+//
+// Must be called with the lock held.
+func (m *Modem) cmt(tp []byte) ([]string, error) {
+	if m.settings.CMGF == 0 {
+		h, err := pduHex(m.smsc, tp)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"+CMT: ," + itoa(len(tp)), h}, nil
+	}
+	t, text, err := m.decodeTPDU(tp, sms.AsMT)
+	if err != nil {
+		return nil, err
+	}
+	hdr := `+CMT: "` + m.encodeText(t.OA.Number()) + `",,"` + formatSCTS(t.SCTS.Time) + `"`
+	if m.settings.CSDH == 1 {
+		hdr += "," + itoa(int(t.OA.TOA)) + "," + itoa(int(t.FirstOctet)) + "," +
+			itoa(int(t.PID)) + "," + itoa(int(t.DCS)) + "," + m.sca(m.smsc) + "," +
+			itoa(textLength(t, text))
+	}
+	return []string{hdr, text}, nil
+}
+
+// sca returns the SMSC address and type fields of a +CSDH=1 header.
+//
+// Must be called with the lock held.
+func (m *Modem) sca(smsc string) string {
+	tosca := 129
+	if strings.HasPrefix(smsc, "+") {
+		tosca = 145
+	}
+	return `"` + m.encodeText(smsc) + `",` + itoa(tosca)
+}
+
+// textLength returns the length of the message in a +CSDH=1 header, which is
+// in characters for text and in octets for hex encoded data, other than GSM7
+// data, which is the TP-UDL in septets.
+func textLength(t *tpdu.TPDU, text string) int {
+	if a, _ := t.Alphabet(); a == tpdu.Alpha7Bit {
+		udh, _ := t.UDH.MarshalBinary()
+		return (len(udh)*8+6)/7 + len(t.UD)
+	}
+	if hexData(t) {
+		return len(text) / 2
+	}
+	return len(t.UD)
+}
+
+// hexData returns true if the text mode data of the TPDU is hex encoded, i.e.
+// the TPDU is 8-bit or UCS2 encoded, or contains a user data header.
+func hexData(t *tpdu.TPDU) bool {
+	if t.UDHI() {
+		return true
+	}
+	a, _ := t.Alphabet()
+	return a == tpdu.Alpha8Bit || a == tpdu.AlphaUCS2
+}
+
+// pduHex returns the hex string of the PDU, as per PDU mode, containing the
+// SMSC address and the TPDU.
+func pduHex(smsc string, tp []byte) (string, error) {
+	p := pdumode.PDU{TPDU: tp}
+	if smsc != "" {
+		p.SMSC = pdumode.SMSCAddress{Address: tpdu.NewAddress(tpdu.FromNumber(smsc))}
+	}
+	h, err := p.MarshalHexString()
+	return strings.ToUpper(h), err
+}
+
+// userData returns the TP-UD of the TPDU, including any user data header,
+// with GSM7 septets packed.
+func userData(t *tpdu.TPDU) []byte {
+	ud, _ := t.UDH.MarshalBinary()
+	if a, _ := t.Alphabet(); a != tpdu.Alpha7Bit {
+		return append(ud, t.UD...)
+	}
+	fillBits := 0
+	if dangling := len(ud) % 7; dangling != 0 {
+		fillBits = 7 - dangling
+	}
+	return append(ud, gsm7.Pack7Bit(t.UD, fillBits)...)
+}
+
+// decodeTPDU unmarshals the TPDU and decodes the message it contains, as
+// presented in text mode.
+//
+// Must be called with the lock held.
+func (m *Modem) decodeTPDU(tp []byte, dirn sms.UnmarshalOption) (*tpdu.TPDU, string, error) {
+	t, err := sms.Unmarshal(tp, dirn)
+	if err != nil {
+		return nil, "", err
+	}
+	if hexData(t) {
+		return t, strings.ToUpper(hex.EncodeToString(userData(t))), nil
+	}
+	msg, err := sms.Decode([]*tpdu.TPDU{t})
+	if err != nil {
+		return nil, "", err
+	}
+	return t, m.encodeText(string(msg)), nil
+}
+
+// encodeText encodes the text in the TE character set.
+//
+// Must be called with the lock held.
+func (m *Modem) encodeText(s string) string {
+	if m.settings.CSCS != "UCS2" {
+		return s
+	}
+	var sb strings.Builder
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&sb, "%04X", u)
+	}
+	return sb.String()
+}
+
+// decodeText decodes text from the TE character set.
+//
+// Must be called with the lock held.
+func (m *Modem) decodeText(s string) (string, error) {
+	if m.settings.CSCS != "UCS2" {
+		return s, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b)%2 != 0 {
+		return "", at.CMSError("305")
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// formatSCTS formats the time as per the text mode SCTS,
+// "yy/MM/dd,hh:mm:ss±zz", where zz is the zone offset in quarter hours.
+func formatSCTS(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%s%02d", t.Format("06/01/02,15:04:05"), sign, offset/(15*60))
+}
+
+// submitPDU parses the body of a PDU mode +CMGS or +CMGW, which is
+// expected to contain an SMS-SUBMIT of the given TPDU length.
+func submitPDU(length string, body string) (*Submit, error) {
+	l, err := strconv.Atoi(length)
+	if err != nil {
+		return nil, at.CMSError("304")
+	}
+	p, err := pdumode.UnmarshalHexString(body)
+	if err != nil || len(p.TPDU) != l {
+		return nil, at.CMSError("304")
+	}
+	t, err := sms.Unmarshal(p.TPDU, sms.AsMO)
+	if err != nil || t.SmsType() != tpdu.SmsSubmit {
+		return nil, at.CMSError("304")
+	}
+	return &Submit{Number: t.DA.Number(), SMSC: p.SMSC.Number(), TPDU: p.TPDU}, nil
+}
+
+// submitText creates the Submit for a text mode +CMGS or +CMGW.
+//
+// Must be called with the lock held.
+func (m *Modem) submitText(number, body string) (*Submit, error) {
+	number, err := m.decodeText(number)
+	if err != nil {
+		return nil, err
+	}
+	text, err := m.decodeText(body)
+	if err != nil {
+		return nil, err
+	}
+	tpdus, err := sms.Encode([]byte(text), sms.To(number))
+	if err != nil || len(tpdus) != 1 {
+		return nil, at.CMSError("305")
+	}
+	tp, err := tpdus[0].MarshalBinary()
+	if err != nil {
+		return nil, at.CMSError("305")
+	}
+	return &Submit{Number: number, Text: text, SMSC: m.smsc, TPDU: tp}, nil
+}
+
+// send passes the message to the submit handler, returning the assigned
+// message reference.
+func (m *Modem) send(s *Submit) (int, error) {
+	m.mu.Lock()
+	m.mr = (m.mr + 1) % 256
+	s.MR = m.mr
+	if s.SMSC == "" {
+		s.SMSC = m.smsc
+	}
+	submit := m.submit
+	m.mu.Unlock()
+	if submit != nil {
+		if err := submit(m, *s); err != nil {
+			return 0, err
+		}
+	}
+	m.mu.Lock()
+	m.sent = append(m.sent, *s)
+	m.mu.Unlock()
+	return s.MR, nil
+}
+
+func cmgsHandler(m *Modem, cmd Command, body string) ([]string, error) {
+	var s *Submit
+	var err error
+	m.mu.Lock()
+	if m.settings.CMGF == 0 {
+		s, err = submitPDU(cmd.Args, body)
+	} else if p := cmd.Params(); len(p) > 0 {
+		s, err = m.submitText(p[0], body)
+	} else {
+		err = at.CMSError("305")
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	mr, err := m.send(s)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"+CMGS: " + itoa(mr)}, nil
+}
+
+func cmgwHandler(m *Modem, cmd Command, body string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := cmd.Params()
+	if len(p) < 1 {
+		return nil, at.CMSError("302")
+	}
+	var s *Submit
+	var err error
+	stat := StatusStoUnsent
+	if m.settings.CMGF == 0 {
+		s, err = submitPDU(p[0], body)
+		if len(p) > 1 {
+			v, err := strconv.Atoi(p[1])
+			if err != nil || v < 0 || v > int(StatusStoSent) {
+				return nil, at.CMSError("304")
+			}
+			stat = Status(v)
+		}
+	} else {
+		s, err = m.submitText(p[0], body)
+		if len(p) > 2 {
+			if stat = parseStatus(p[2]); stat == statusAll || stat < 0 {
+				return nil, at.CMSError("305")
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx, err := m.mems[m.settings.CPMS[1]].add(Message{Status: stat, SMSC: s.SMSC, TPDU: s.TPDU})
+	if err != nil {
+		return nil, err
+	}
+	return []string{"+CMGW: " + itoa(idx)}, nil
+}
+
+func cmssHandler(m *Modem, cmd Command) ([]string, error) {
+	if cmd.Op == OpTest {
+		return nil, nil
+	}
+	if cmd.Op != OpSet {
+		return nil, ErrError
+	}
+	p, err := cmd.IntParams(0)
+	if err != nil || len(p) < 1 {
+		return nil, at.CMSError("302")
+	}
+	m.mu.Lock()
+	msg, ok := m.mems[m.settings.CPMS[1]].msgs[p[0]]
+	if !ok {
+		m.mu.Unlock()
+		return nil, at.CMSError("321")
+	}
+	t, err := sms.Unmarshal(msg.TPDU, sms.AsMO)
+	if err != nil || t.SmsType() != tpdu.SmsSubmit {
+		m.mu.Unlock()
+		return nil, at.CMSError("302")
+	}
+	s := &Submit{Number: t.DA.Number(), SMSC: msg.SMSC, TPDU: msg.TPDU}
+	m.mu.Unlock()
+	mr, err := m.send(s)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	msg.Status = StatusStoSent
+	m.mu.Unlock()
+	return []string{"+CMSS: " + itoa(mr)}, nil
+}
+
+func cnmaHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpExec:
+		if m.settings.CSMS != 1 || m.pendingAcks == 0 {
+			return nil, at.CMSError("340")
+		}
+		m.pendingAcks--
+		m.acks++
+		return nil, nil
+	case OpSet:
+		p, err := cmd.IntParams(0)
+		if err != nil || len(p) != 1 || p[0] < 0 || p[0] > 2 {
+			return nil, at.CMSError("302")
+		}
+		if m.settings.CSMS != 1 || m.pendingAcks == 0 {
+			return nil, at.CMSError("340")
+		}
+		m.pendingAcks--
+		if p[0] == 2 {
+			m.nacks = append(m.nacks, 0xff)
+		} else {
+			m.acks++
+		}
+		return nil, nil
+	case OpTest:
+		return []string{"+CNMA: (0-2)"}, nil
+	}
+	return nil, ErrError
+}
+
+// cnmaPDUHandler handles a PDU mode +CNMA carrying an SMS-DELIVER-REPORT.
+func cnmaPDUHandler(m *Modem, cmd Command, body string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := cmd.IntParams(0)
+	if err != nil || len(p) != 2 || p[0] < 1 || p[0] > 2 || m.settings.CMGF != 0 {
+		return nil, at.CMSError("302")
+	}
+	if m.settings.CSMS != 1 || m.pendingAcks == 0 {
+		return nil, at.CMSError("340")
+	}
+	b, err := hex.DecodeString(body)
+	if err != nil || len(b) != p[1] {
+		return nil, at.CMSError("304")
+	}
+	t, err := sms.Unmarshal(b, sms.AsMO)
+	if err != nil || t.SmsType() != tpdu.SmsDeliverReport {
+		return nil, at.CMSError("304")
+	}
+	m.pendingAcks--
+	if p[0] == 2 {
+		m.nacks = append(m.nacks, t.FCS)
+	} else {
+		m.acks++
+	}
+	return nil, nil
+}
+
+func cscaHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		toa := "129"
+		if strings.HasPrefix(m.smsc, "+") {
+			toa = "145"
+		}
+		return []string{`+CSCA: "` + m.encodeText(m.smsc) + `",` + toa}, nil
+	case OpSet:
+		p := cmd.Params()
+		if len(p) < 1 {
+			return nil, at.CMSError("302")
+		}
+		number, err := m.decodeText(p[0])
+		if err != nil {
+			return nil, err
+		}
+		m.smsc = number
+		return nil, nil
+	case OpTest:
+		return nil, nil
+	}
+	return nil, ErrError
+}
+
+func cpmsHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch cmd.Op {
+	case OpRead:
+		var f []string
+		for _, mem := range m.settings.CPMS {
+			s := m.mems[mem]
+			f = append(f, `"`+mem+`"`, itoa(len(s.msgs)), itoa(s.capacity))
+		}
+		return []string{"+CPMS: " + strings.Join(f, ",")}, nil
+	case OpSet:
+		p := cmd.Params()
+		if len(p) < 1 || len(p) > 3 {
+			return nil, at.CMSError("302")
+		}
+		cpms := m.settings.CPMS
+		for i, mem := range p {
+			mem = strings.ToUpper(mem)
+			if _, ok := m.mems[mem]; !ok {
+				return nil, at.CMSError("302")
+			}
+			cpms[i] = mem
+		}
+		m.settings.CPMS = cpms
+		var f []string
+		for _, mem := range cpms {
+			s := m.mems[mem]
+			f = append(f, itoa(len(s.msgs)), itoa(s.capacity))
+		}
+		return []string{"+CPMS: " + strings.Join(f, ",")}, nil
+	case OpTest:
+		return []string{`+CPMS: ("SM","ME"),("SM","ME"),("SM","ME")`}, nil
+	}
+	return nil, ErrError
+}
+
+func cmgrHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cmd.Op == OpTest {
+		return nil, nil
+	}
+	if cmd.Op != OpSet {
+		return nil, ErrError
+	}
+	p, err := cmd.IntParams(0)
+	if err != nil || len(p) != 1 {
+		return nil, at.CMSError("302")
+	}
+	msg, ok := m.mems[m.settings.CPMS[0]].msgs[p[0]]
+	if !ok {
+		return nil, at.CMSError("321")
+	}
+	lines, err := m.formatMessage("+CMGR: ", msg)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Status == StatusRecUnread {
+		msg.Status = StatusRecRead
+	}
+	return lines, nil
+}
+
+func cmglHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stat := StatusRecUnread
+	switch cmd.Op {
+	case OpTest:
+		if m.settings.CMGF == 0 {
+			return []string{"+CMGL: (0-4)"}, nil
+		}
+		return []string{`+CMGL: ("REC UNREAD","REC READ","STO UNSENT","STO SENT","ALL")`}, nil
+	case OpSet:
+		p := cmd.Params()
+		if len(p) != 1 {
+			return nil, at.CMSError("302")
+		}
+		if m.settings.CMGF == 0 {
+			v, err := strconv.Atoi(p[0])
+			if err != nil || v < 0 || v > int(statusAll) {
+				return nil, at.CMSError("304")
+			}
+			stat = Status(v)
+		} else if stat = parseStatus(p[0]); stat < 0 {
+			return nil, at.CMSError("305")
+		}
+	case OpExec:
+	default:
+		return nil, ErrError
+	}
+	var lines []string
+	for _, msg := range m.mems[m.settings.CPMS[0]].list() {
+		if stat != statusAll && msg.Status != stat {
+			continue
+		}
+		ml, err := m.formatMessage("+CMGL: "+itoa(msg.Index)+",", msg)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, ml...)
+		if msg.Status == StatusRecUnread {
+			msg.Status = StatusRecRead
+		}
+	}
+	return lines, nil
+}
+
+// formatMessage returns the lines for a message in a +CMGR or +CMGL
+// response.
+//
+// In text mode with +CSDH=1 the header is extended with the full set of
+// fields for +CMGR, or the address type and length for +CMGL.
+//
+// Must be called with the lock held.
+func (m *Modem) formatMessage(prefix string, msg *Message) ([]string, error) {
+	if m.settings.CMGF == 0 {
+		h, err := pduHex(msg.SMSC, msg.TPDU)
+		if err != nil {
+			return nil, at.CMSError("500")
+		}
+		return []string{prefix + itoa(int(msg.Status)) + ",," + itoa(len(msg.TPDU)), h}, nil
+	}
+	read := strings.HasPrefix(prefix, "+CMGR")
+	if msg.Status == StatusRecUnread || msg.Status == StatusRecRead {
+		t, text, err := m.decodeTPDU(msg.TPDU, sms.AsMT)
+		if err != nil {
+			return nil, at.CMSError("500")
+		}
+		hdr := prefix + `"` + msg.Status.String() + `","` + m.encodeText(t.OA.Number()) +
+			`",,"` + formatSCTS(t.SCTS.Time) + `"`
+		if m.settings.CSDH == 1 {
+			hdr += "," + itoa(int(t.OA.TOA))
+			if read {
+				hdr += "," + itoa(int(t.FirstOctet)) + "," + itoa(int(t.PID)) + "," +
+					itoa(int(t.DCS)) + "," + m.sca(msg.SMSC)
+			}
+			hdr += "," + itoa(textLength(t, text))
+		}
+		return []string{hdr, text}, nil
+	}
+	t, text, err := m.decodeTPDU(msg.TPDU, sms.AsMO)
+	if err != nil {
+		return nil, at.CMSError("500")
+	}
+	hdr := prefix + `"` + msg.Status.String() + `","` + m.encodeText(t.DA.Number()) + `",`
+	if m.settings.CSDH == 1 {
+		if read {
+			// <toda>,<fo>,<pid>,<dcs>,[<vp>],<sca>,<tosca>
+			hdr += "," + itoa(int(t.DA.TOA)) + "," + itoa(int(t.FirstOctet)) + "," +
+				itoa(int(t.PID)) + "," + itoa(int(t.DCS)) + ",," + m.sca(msg.SMSC)
+		} else {
+			hdr += "," + itoa(int(t.DA.TOA))
+		}
+		hdr += "," + itoa(textLength(t, text))
+	}
+	return []string{hdr, text}, nil
+}
+
+// parseStatus returns the Status corresponding to the text mode status, or -1
+// if the status is not recognised.
+func parseStatus(s string) Status {
+	for i, st := range statusText {
+		if strings.EqualFold(s, st) {
+			return Status(i)
+		}
+	}
+	return -1
+}
+
+func cmgdHandler(m *Modem, cmd Command) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.mems[m.settings.CPMS[0]]
+	switch cmd.Op {
+	case OpTest:
+		var idxs []string
+		for _, msg := range s.list() {
+			idxs = append(idxs, itoa(msg.Index))
+		}
+		return []string{"+CMGD: (" + strings.Join(idxs, ",") + "),(0-4)"}, nil
+	case OpSet:
+	default:
+		return nil, ErrError
+	}
+	p, err := cmd.IntParams(0)
+	if err != nil || len(p) < 1 || len(p) > 2 {
+		return nil, at.CMSError("302")
+	}
+	flag := 0
+	if len(p) > 1 {
+		flag = p[1]
+	}
+	if flag == 0 {
+		if _, ok := s.msgs[p[0]]; !ok {
+			return nil, at.CMSError("321")
+		}
+		delete(s.msgs, p[0])
+		return nil, nil
+	}
+	if flag < 0 || flag > 4 {
+		return nil, at.CMSError("302")
+	}
+	// the statuses deleted by each flag
+	deleted := [][]Status{
+		1: {StatusRecRead},
+		2: {StatusRecRead, StatusStoSent},
+		3: {StatusRecRead, StatusStoSent, StatusStoUnsent},
+		4: {StatusRecRead, StatusStoSent, StatusStoUnsent, StatusRecUnread},
+	}[flag]
+	for idx, msg := range s.msgs {
+		for _, st := range deleted {
+			if msg.Status == st {
+				delete(s.msgs, idx)
+				break
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package sim_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/gsm"
+	"github.com/warthog618/modem/sim"
+)
+
+func TestSendPDU(t *testing.T) {
+	var submitted []sim.Submit
+	m := newModem(sim.WithSMSC("+61411000000"),
+		sim.WithSubmitHandler(func(m *sim.Modem, s sim.Submit) error {
+			submitted = append(submitted, s)
+			if s.Number == "+61400000000" {
+				return at.CMSError("500")
+			}
+			return nil
+		}))
+	defer m.Close()
+	tp := submitTPDU(t, "+61412345678", "hello")
+	pdu := pduHex(t, "", tp)
+
+	write(t, m, "AT+CMGS="+strconv.Itoa(len(tp))+"\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, pdu+"\x1a")
+	assert.Equal(t, "\r\n+CMGS: 1\r\n\r\nOK\r\n", readResponse(t, m))
+
+	// bad length
+	write(t, m, "AT+CMGS=3\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, pdu+"\x1a")
+	assert.Equal(t, "\r\n+CMS ERROR: 304\r\n", readResponse(t, m))
+
+	// cancelled
+	write(t, m, "AT+CMGS="+strconv.Itoa(len(tp))+"\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, pdu+"\x1b")
+	write(t, m, "AT\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+
+	// rejected by network
+	tp = submitTPDU(t, "+61400000000", "fail")
+	pdu = pduHex(t, "", tp)
+	write(t, m, "AT+CMGS="+strconv.Itoa(len(tp))+"\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, pdu+"\x1a")
+	assert.Equal(t, "\r\n+CMS ERROR: 500\r\n", readResponse(t, m))
+
+	sent := m.Sent()
+	require.Equal(t, 1, len(sent))
+	assert.Equal(t, 1, sent[0].MR)
+	assert.Equal(t, "+61412345678", sent[0].Number)
+	assert.Equal(t, "+61411000000", sent[0].SMSC)
+	assert.Equal(t, 2, len(submitted))
+}
+
+func TestSendText(t *testing.T) {
+	m := newModem()
+	defer m.Close()
+	write(t, m, "AT+CMGF=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMGS=\"+61412345678\"\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, "hello world\x1a")
+	assert.Equal(t, "\r\n+CMGS: 1\r\n\r\nOK\r\n", readResponse(t, m))
+
+	// UCS2
+	write(t, m, "AT+CSCS=\"UCS2\"\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMGS=\"002B0031\"\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, "00680069\x1a")
+	assert.Equal(t, "\r\n+CMGS: 2\r\n\r\nOK\r\n", readResponse(t, m))
+
+	sent := m.Sent()
+	require.Equal(t, 2, len(sent))
+	assert.Equal(t, "+61412345678", sent[0].Number)
+	assert.Equal(t, "hello world", sent[0].Text)
+	st, err := sms.Unmarshal(sent[0].TPDU, sms.AsMO)
+	require.Nil(t, err)
+	assert.Equal(t, "+61412345678", st.DA.Number())
+	assert.Equal(t, "+1", sent[1].Number)
+	assert.Equal(t, "hi", sent[1].Text)
+}
+
+func TestDeliver(t *testing.T) {
+	m := newModem(sim.WithSMSC("+61411000000"))
+	defer m.Close()
+	tp := deliverTPDU(t, "+61412345678", "hello")
+
+	// stored, no indication
+	require.Nil(t, m.Deliver(tp))
+	msgs := m.Messages("SM")
+	require.Equal(t, 1, len(msgs))
+	assert.Equal(t, sim.StatusRecUnread, msgs[0].Status)
+	assert.Equal(t, tp, msgs[0].TPDU)
+
+	// stored and indicated
+	write(t, m, "AT+CNMI=1,1,0,0,0\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	require.Nil(t, m.Deliver(tp))
+	assert.Equal(t, "\r\n+CMTI: \"SM\",2\r\n", read(t, m))
+
+	// forwarded
+	write(t, m, "AT+CSMS=1;+CNMI=1,2,0,0,0\r")
+	assert.Equal(t, "\r\n+CSMS: 1,1,1\r\n\r\nOK\r\n", readResponse(t, m))
+	require.Nil(t, m.Deliver(tp))
+	pdu := pduHex(t, "+61411000000", tp)
+	assert.Equal(t, "\r\n+CMT: ,"+strconv.Itoa(len(tp))+"\r\n"+pdu+"\r\n", read(t, m))
+	assert.Equal(t, 1, m.PendingAcks())
+	write(t, m, "AT+CNMA\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	assert.Equal(t, 0, m.PendingAcks())
+	assert.Equal(t, 1, m.Acks())
+	write(t, m, "AT+CNMA\r")
+	assert.Equal(t, "\r\n+CMS ERROR: 340\r\n", readResponse(t, m))
+
+	// rejected
+	require.Nil(t, m.Deliver(tp))
+	read(t, m)
+	write(t, m, "AT+CNMA=2,3\r")
+	assert.Equal(t, "\r\n> ", read(t, m))
+	write(t, m, "00d300\x1a")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	assert.Equal(t, 0, m.PendingAcks())
+	assert.Equal(t, []byte{0xd3}, m.Nacks())
+	require.Nil(t, m.Deliver(tp))
+	read(t, m)
+	write(t, m, "AT+CNMA=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	assert.Equal(t, 2, m.Acks())
+
+	// forwarded in text mode
+	write(t, m, "AT+CMGF=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	require.Nil(t, m.Deliver(tp))
+	rsp := read(t, m)
+	assert.True(t, strings.HasPrefix(rsp, "\r\n+CMT: \"+61412345678\",,\""), rsp)
+	assert.True(t, strings.HasSuffix(rsp, "\"\r\nhello\r\n"), rsp)
+
+	// with full header
+	write(t, m, "AT+CSDH=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	require.Nil(t, m.Deliver(tp))
+	rsp = read(t, m)
+	assert.True(t, strings.HasSuffix(rsp, "\",145,0,0,0,\"+61411000000\",145,5\r\nhello\r\n"), rsp)
+
+	// UCS2 is hex encoded
+	require.Nil(t, m.Deliver(deliverTPDU(t, "+61412345678", "\x00h\x00i", sms.AsUCS2)))
+	rsp = read(t, m)
+	assert.True(t, strings.HasSuffix(rsp, "\",145,0,0,8,\"+61411000000\",145,4\r\n00680069\r\n"), rsp)
+}
+
+func TestDeliverReport(t *testing.T) {
+	m := newModem()
+	defer m.Close()
+	sr := tpdu.TPDU{
+		MR:   42,
+		RA:   tpdu.NewAddress(tpdu.FromNumber("+61412345678")),
+		SCTS: tpdu.Timestamp{Time: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)},
+		DT:   tpdu.Timestamp{Time: time.Date(2026, 10, 18, 9, 30, 5, 0, time.UTC)},
+	}
+	sr.SetSmsType(tpdu.SmsStatusReport)
+	tp, err := sr.MarshalBinary()
+	require.Nil(t, err)
+
+	// disabled
+	require.Nil(t, m.DeliverReport(tp))
+	assert.Empty(t, m.Messages("SM"))
+
+	// forwarded
+	write(t, m, "AT+CNMI=1,0,0,1,0\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	require.Nil(t, m.DeliverReport(tp))
+	assert.Equal(t, "\r\n+CDS: "+strconv.Itoa(len(tp))+"\r\n"+pduHex(t, "", tp)+"\r\n", read(t, m))
+
+	// forwarded in text mode
+	write(t, m, "AT+CMGF=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	require.Nil(t, m.DeliverReport(tp))
+	assert.Equal(t, "\r\n+CDS: 2,42,\"+61412345678\",145,\"26/10/18,09:30:00+00\",\"26/10/18,09:30:05+00\",0\r\n",
+		read(t, m))
+
+	// stored
+	write(t, m, "AT+CNMI=1,0,0,2,0\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	require.Nil(t, m.DeliverReport(tp))
+	assert.Equal(t, "\r\n+CDSI: \"SM\",1\r\n", read(t, m))
+	assert.Equal(t, 1, len(m.Messages("SM")))
+}
+
+func TestStorage(t *testing.T) {
+	m := newModem()
+	defer m.Close()
+	tp := deliverTPDU(t, "+61412345678", "hello")
+	idx, err := m.StoreMessage("SM", sim.StatusRecUnread, tp)
+	require.Nil(t, err)
+	assert.Equal(t, 1, idx)
+	idx, err = m.StoreMessage("SM", sim.StatusRecRead, tp)
+	require.Nil(t, err)
+	assert.Equal(t, 2, idx)
+	pdu := pduHex(t, "", tp)
+	l := strconv.Itoa(len(tp))
+
+	write(t, m, "AT+CPMS?\r")
+	assert.Equal(t, "\r\n+CPMS: \"SM\",2,30,\"SM\",2,30,\"SM\",2,30\r\n\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMGL=4\r")
+	assert.Equal(t, "\r\n+CMGL: 1,0,,"+l+"\r\n"+pdu+"\r\n+CMGL: 2,1,,"+l+"\r\n"+pdu+"\r\n\r\nOK\r\n",
+		readResponse(t, m))
+	// listing marks unread as read
+	write(t, m, "AT+CMGL\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMGR=2\r")
+	assert.Equal(t, "\r\n+CMGR: 1,,"+l+"\r\n"+pdu+"\r\n\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMGR=3\r")
+	assert.Equal(t, "\r\n+CMS ERROR: 321\r\n", readResponse(t, m))
+
+	// with full header in text mode
+	write(t, m, "AT+CMGF=1;+CSDH=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMGR=2\r")
+	rsp := readResponse(t, m)
+	assert.True(t, strings.HasPrefix(rsp, "\r\n+CMGR: \"REC READ\",\"+61412345678\",,\""), rsp)
+	assert.True(t, strings.HasSuffix(rsp, ",0,0,\"\",129,5\r\nhello\r\n\r\nOK\r\n"), rsp)
+	write(t, m, "AT+CMGL=\"REC READ\"\r")
+	rsp = readResponse(t, m)
+	assert.True(t, strings.HasPrefix(rsp, "\r\n+CMGL: 1,\"REC READ\",\"+61412345678\",,\""), rsp)
+	assert.True(t, strings.HasSuffix(rsp, "\",145,5\r\nhello\r\n\r\nOK\r\n"), rsp)
+	write(t, m, "AT+CMGF=0\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+
+	write(t, m, "AT+CMGD=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	assert.Equal(t, 1, len(m.Messages("SM")))
+	write(t, m, "AT+CMGD=0,4\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	assert.Equal(t, 0, len(m.Messages("SM")))
+
+	// write and send from storage
+	stp := submitTPDU(t, "+61412345678", "stored")
+	spdu := pduHex(t, "", stp)
+	write(t, m, "AT+CMGW="+strconv.Itoa(len(stp))+"\r")
+	assert.Equal(t, "\r\n> ", readResponse(t, m))
+	write(t, m, spdu+"\x1a")
+	assert.Equal(t, "\r\n+CMGW: 1\r\n\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CMSS=1\r")
+	assert.Equal(t, "\r\n+CMSS: 1\r\n\r\nOK\r\n", readResponse(t, m))
+	msgs := m.Messages("SM")
+	require.Equal(t, 1, len(msgs))
+	assert.Equal(t, sim.StatusStoSent, msgs[0].Status)
+	assert.Equal(t, 1, len(m.Sent()))
+}
+
+func TestPhonebook(t *testing.T) {
+	m := newModem(sim.WithPhonebook("SM",
+		sim.PhonebookEntry{Index: 1, Number: "+61412345678", Name: "Alice"}))
+	defer m.Close()
+	write(t, m, "AT+CPBS?\r")
+	assert.Equal(t, "\r\n+CPBS: \"SM\",1,250\r\n\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CPBW=2,\"0412345678\",129,\"Bob\"\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CPBR=1,10\r")
+	assert.Equal(t, "\r\n+CPBR: 1,\"+61412345678\",145,\"Alice\"\r\n"+
+		"+CPBR: 2,\"0412345678\",129,\"Bob\"\r\n\r\nOK\r\n", readResponse(t, m))
+	write(t, m, "AT+CPBW=1\r")
+	assert.Equal(t, "\r\nOK\r\n", readResponse(t, m))
+	pb := m.Phonebook("SM")
+	require.Equal(t, 1, len(pb))
+	assert.Equal(t, "Bob", pb[0].Name)
+}
+
+func TestWithGSM(t *testing.T) {
+	m := sim.New(sim.WithLatency(time.Millisecond), sim.WithSMSC("+61411000000"))
+	defer m.Close()
+	g := gsm.New(at.New(m, at.WithTimeout(100*time.Millisecond)))
+	require.Nil(t, g.Init())
+	s := m.Settings()
+	assert.Equal(t, 0, s.CMGF)
+	assert.Equal(t, 2, s.CMEE)
+
+	mr, err := g.SendShortMessage("+61412345678", "hello")
+	require.Nil(t, err)
+	assert.Equal(t, "1", mr)
+	mrs, err := g.SendLongMessage("+61412345678", strings.Repeat("long ", 40))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"2", "3"}, mrs)
+	sent := m.Sent()
+	require.Equal(t, 3, len(sent))
+	assert.Equal(t, "+61412345678", sent[0].Number)
+
+	msgs := make(chan gsm.Message, 1)
+	err = g.StartMessageRx(
+		func(msg gsm.Message) { msgs <- msg },
+		func(err error) { t.Errorf("unexpected rx error: %v", err) })
+	require.Nil(t, err)
+	require.Nil(t, m.Deliver(deliverTPDU(t, "+61498765432", "hello back")))
+	select {
+	case msg := <-msgs:
+		assert.Equal(t, "+61498765432", msg.Number)
+		assert.Equal(t, "hello back", msg.Message)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("no message received")
+	}
+	// wait for the ack
+	for i := 0; i < 10 && m.Acks() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, m.Acks())
+	g.StopMessageRx()
+}
+
+// newModem creates a Modem with echo disabled and numeric errors enabled.
+func newModem(options ...sim.Option) *sim.Modem {
+	s := sim.DefaultSettings
+	s.Echo = false
+	s.CMEE = 1
+	return sim.New(append([]sim.Option{sim.WithSettings(s)}, options...)...)
+}
+
+// submitTPDU returns the binary SMS-SUBMIT TPDU of the message.
+func submitTPDU(t *testing.T, number, msg string) []byte {
+	t.Helper()
+	tpdus, err := sms.Encode([]byte(msg), sms.To(number))
+	require.Nil(t, err)
+	tp, err := tpdus[0].MarshalBinary()
+	require.Nil(t, err)
+	return tp
+}
+
+// deliverTPDU returns the binary SMS-DELIVER TPDU of the message.
+func deliverTPDU(t *testing.T, number, msg string, options ...sms.EncoderOption) []byte {
+	t.Helper()
+	options = append([]sms.EncoderOption{sms.AsDeliver, sms.From(number)}, options...)
+	tpdus, err := sms.Encode([]byte(msg), options...)
+	require.Nil(t, err)
+	tpdus[0].SCTS = tpdu.Timestamp{Time: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)}
+	tp, err := tpdus[0].MarshalBinary()
+	require.Nil(t, err)
+	return tp
+}
+
+// pduHex returns the PDU mode hex string of the SMSC address and TPDU.
+func pduHex(t *testing.T, smsc string, tp []byte) string {
+	t.Helper()
+	p := pdumode.PDU{TPDU: tp}
+	if smsc != "" {
+		p.SMSC = pdumode.SMSCAddress{Address: tpdu.NewAddress(tpdu.FromNumber(smsc))}
+	}
+	h, err := p.MarshalHexString()
+	require.Nil(t, err)
+	return strings.ToUpper(h)
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Port is the interface of a serial port whose control methods are passed
+// through by a PortTrace.
+//
+// It is a subset of the go.bug.st/serial Port interface, excluding those
+// methods with serial specific types, such as SetMode, which must be called
+// on the port directly.
+type Port interface {
+	io.ReadWriteCloser
+	Drain() error
+	ResetInputBuffer() error
+	ResetOutputBuffer() error
+	SetDTR(dtr bool) error
+	SetRTS(rts bool) error
+	SetReadTimeout(t time.Duration) error
+	Break(d time.Duration) error
+}
+
+// PortTrace is a trace log on a Port.
+//
+// In addition to the reads and writes, the control methods are passed through
+// to the Port and logged as control events.
+type PortTrace struct {
+	*Trace
+	p Port
+}
+
+// Wrap creates a new trace on the io.ReadWriter, exposing only the
+// capabilities of the io.ReadWriter.
+//
+// If the io.ReadWriter is a Port then a PortTrace is returned, else a Trace.
+func Wrap(rw io.ReadWriter, options ...Option) io.ReadWriter {
+	t := New(rw, options...)
+	if p, ok := rw.(Port); ok {
+		return &PortTrace{Trace: t, p: p}
+	}
+	return t
+}
+
+// WithControlFormat sets the format used for control event logs, such as
+// SetDTR or Break.
+func WithControlFormat(format string) Option {
+	return func(t *Trace) {
+		t.cfmt = format
+	}
+}
+
+// Close flushes any partial lines and closes the wrapped object, if it is an
+// io.Closer.
+func (t *Trace) Close() error {
+	t.Flush()
+	c, ok := t.rw.(io.Closer)
+	if !ok {
+		return nil
+	}
+	err := c.Close()
+	t.logEvent("Close()", err)
+	return err
+}
+
+// Drain waits until all data written to the port has been transmitted.
+func (t *PortTrace) Drain() error {
+	err := t.p.Drain()
+	t.logEvent("Drain()", err)
+	return err
+}
+
+// ResetInputBuffer discards any data received by the port but not yet read.
+func (t *PortTrace) ResetInputBuffer() error {
+	err := t.p.ResetInputBuffer()
+	t.logEvent("ResetInputBuffer()", err)
+	return err
+}
+
+// ResetOutputBuffer discards any data written to the port but not yet
+// transmitted.
+func (t *PortTrace) ResetOutputBuffer() error {
+	err := t.p.ResetOutputBuffer()
+	t.logEvent("ResetOutputBuffer()", err)
+	return err
+}
+
+// SetDTR sets the DTR line of the port.
+func (t *PortTrace) SetDTR(dtr bool) error {
+	err := t.p.SetDTR(dtr)
+	t.logEvent(fmt.Sprintf("SetDTR(%t)", dtr), err)
+	return err
+}
+
+// SetRTS sets the RTS line of the port.
+func (t *PortTrace) SetRTS(rts bool) error {
+	err := t.p.SetRTS(rts)
+	t.logEvent(fmt.Sprintf("SetRTS(%t)", rts), err)
+	return err
+}
+
+// SetReadTimeout sets the read timeout of the port.
+func (t *PortTrace) SetReadTimeout(d time.Duration) error {
+	err := t.p.SetReadTimeout(d)
+	t.logEvent(fmt.Sprintf("SetReadTimeout(%s)", d), err)
+	return err
+}
+
+// Break sends a break on the port for the duration.
+func (t *PortTrace) Break(d time.Duration) error {
+	err := t.p.Break(d)
+	t.logEvent(fmt.Sprintf("Break(%s)", d), err)
+	return err
+}
+
+// logEvent logs a control event, and the error it returned, if any.
+func (t *Trace) logEvent(event string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.slog != nil {
+		attrs := []slog.Attr{slog.String("event", event)}
+		if err != nil {
+			attrs = append(attrs, slog.String("err", err.Error()))
+		}
+		t.slog.LogAttrs(context.Background(), t.wlevel, "control", attrs...)
+		return
+	}
+	if err != nil {
+		event = fmt.Sprintf("%s: %v", event, err)
+	}
+	if t.lineMode && t.timeFormat != "" {
+		t.l.Printf("%s "+t.cfmt, time.Now().Format(t.timeFormat), event)
+	} else {
+		t.l.Printf(t.cfmt, event)
+	}
+}
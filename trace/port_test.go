@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package trace_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/trace"
+)
+
+func TestPort(t *testing.T) {
+	errBreak := errors.New("break failed")
+	p := &mockPort{breakErr: errBreak}
+	b := bytes.Buffer{}
+	l := log.New(&b, "", 0)
+	tr, ok := trace.Wrap(p, trace.WithLogger(l)).(*trace.PortTrace)
+	require.True(t, ok)
+
+	assert.Nil(t, tr.Drain())
+	assert.Nil(t, tr.ResetInputBuffer())
+	assert.Nil(t, tr.ResetOutputBuffer())
+	assert.Nil(t, tr.SetDTR(true))
+	assert.Nil(t, tr.SetRTS(false))
+	assert.Nil(t, tr.SetReadTimeout(time.Second))
+	assert.Equal(t, errBreak, tr.Break(100*time.Millisecond))
+	assert.Nil(t, tr.Close())
+
+	assert.Equal(t, []string{
+		"Drain",
+		"ResetInputBuffer",
+		"ResetOutputBuffer",
+		"SetDTR(true)",
+		"SetRTS(false)",
+		"SetReadTimeout(1s)",
+		"Break(100ms)",
+		"Close",
+	}, p.calls)
+	assert.Equal(t,
+		"c: Drain()\n"+
+			"c: ResetInputBuffer()\n"+
+			"c: ResetOutputBuffer()\n"+
+			"c: SetDTR(true)\n"+
+			"c: SetRTS(false)\n"+
+			"c: SetReadTimeout(1s)\n"+
+			"c: Break(100ms): break failed\n"+
+			"c: Close()\n",
+		b.String())
+}
+
+func TestWrapReadWriter(t *testing.T) {
+	mrw := bytes.NewBufferString("one")
+	b := bytes.Buffer{}
+	l := log.New(&b, "", 0)
+	rw := trace.Wrap(mrw, trace.WithLogger(l))
+	_, ok := rw.(trace.Port)
+	assert.False(t, ok)
+	tr, ok := rw.(*trace.Trace)
+	require.True(t, ok)
+	// not a Closer, so nothing to close
+	assert.Nil(t, tr.Close())
+	assert.Equal(t, "", b.String())
+}
+
+func TestPortLineMode(t *testing.T) {
+	p := &mockPort{}
+	b := bytes.Buffer{}
+	l := log.New(&b, "", 0)
+	tr := trace.Wrap(p, trace.WithLogger(l), trace.WithLineMode(),
+		trace.WithTimeFormat(""), trace.WithControlFormat("C: %s")).(*trace.PortTrace)
+	_, err := tr.Write([]byte("AT"))
+	assert.Nil(t, err)
+	assert.Nil(t, tr.SetDTR(false))
+	// Close flushes the partial line
+	assert.Nil(t, tr.Close())
+	assert.Equal(t, "C: SetDTR(false)\nw: AT\nC: Close()\n", b.String())
+
+	// timestamped
+	b.Reset()
+	tr = trace.Wrap(p, trace.WithLogger(l), trace.WithLineMode()).(*trace.PortTrace)
+	assert.Nil(t, tr.SetRTS(true))
+	assert.Regexp(t, `^\d\d:\d\d:\d\d\.\d{3} c: SetRTS\(true\)\n$`, b.String())
+}
+
+func TestPortSlog(t *testing.T) {
+	p := &mockPort{breakErr: errors.New("break failed")}
+	b := bytes.Buffer{}
+	h := slog.NewTextHandler(&b, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	tr := trace.Wrap(p, trace.WithSlog(slog.New(h))).(*trace.PortTrace)
+	assert.Nil(t, tr.SetDTR(true))
+	assert.NotNil(t, tr.Break(time.Millisecond))
+	assert.Equal(t,
+		"level=DEBUG msg=control event=SetDTR(true)\n"+
+			"level=DEBUG msg=control event=Break(1ms) err=\"break failed\"\n",
+		b.String())
+}
+
+// mockPort records the Port methods called.
+type mockPort struct {
+	bytes.Buffer
+	calls    []string
+	breakErr error
+}
+
+func (p *mockPort) Drain() error {
+	p.calls = append(p.calls, "Drain")
+	return nil
+}
+
+func (p *mockPort) ResetInputBuffer() error {
+	p.calls = append(p.calls, "ResetInputBuffer")
+	return nil
+}
+
+func (p *mockPort) ResetOutputBuffer() error {
+	p.calls = append(p.calls, "ResetOutputBuffer")
+	return nil
+}
+
+func (p *mockPort) SetDTR(dtr bool) error {
+	p.calls = append(p.calls, fmt.Sprintf("SetDTR(%t)", dtr))
+	return nil
+}
+
+func (p *mockPort) SetRTS(rts bool) error {
+	p.calls = append(p.calls, fmt.Sprintf("SetRTS(%t)", rts))
+	return nil
+}
+
+func (p *mockPort) SetReadTimeout(d time.Duration) error {
+	p.calls = append(p.calls, fmt.Sprintf("SetReadTimeout(%s)", d))
+	return nil
+}
+
+func (p *mockPort) Break(d time.Duration) error {
+	p.calls = append(p.calls, fmt.Sprintf("Break(%s)", d))
+	return p.breakErr
+}
+
+func (p *mockPort) Close() error {
+	p.calls = append(p.calls, "Close")
+	return nil
+}
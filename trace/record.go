@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package trace
+
+import (
+	"encoding/hex"
+	"time"
+	"unicode/utf8"
+)
+
+// The directions of a Record.
+const (
+	// DirRead indicates data read from the modem.
+	DirRead = "r"
+
+	// DirWrite indicates data written to the modem.
+	DirWrite = "w"
+)
+
+// Record is a single read or write recorded by WithRecorder.
+//
+// The data is recorded as Text if it is valid UTF-8, else as Hex.
+type Record struct {
+	Dir  string    `json:"dir"`
+	Time time.Time `json:"time"`
+	Text string    `json:"text,omitempty"`
+	Hex  string    `json:"hex,omitempty"`
+}
+
+// NewRecord creates a Record for the data.
+func NewRecord(dir string, t time.Time, data []byte) Record {
+	r := Record{Dir: dir, Time: t}
+	if utf8.Valid(data) {
+		r.Text = string(data)
+	} else {
+		r.Hex = hex.EncodeToString(data)
+	}
+	return r
+}
+
+// Data returns the data contained in the Record.
+func (r Record) Data() ([]byte, error) {
+	if r.Hex != "" {
+		return hex.DecodeString(r.Hex)
+	}
+	return []byte(r.Text), nil
+}
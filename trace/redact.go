@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"regexp"
+)
+
+// Line is a line of trace, as presented to a Redactor.
+type Line struct {
+	// Dir is the direction of the line, DirRead or DirWrite.
+	Dir string
+
+	// Data is the line, including any terminator.
+	Data []byte
+
+	// Cmd is the most recent command line written to the modem.
+	//
+	// For reads this is the command, if any, the line is in response to.
+	Cmd []byte
+
+	// Prev is the previous line in the same direction.
+	Prev []byte
+
+	// Header is the most recent information response or indication line,
+	// such as "+CMGR: ...", read since the most recent command line and not
+	// yet terminated by a final result code.
+	//
+	// For reads this is the header, if any, the line is part of the body of.
+	// It is always nil for writes.
+	Header []byte
+}
+
+// Redactor returns the line with any sensitive data masked.
+//
+// If the line contains no sensitive data the line data is returned unaltered.
+type Redactor func(l Line) []byte
+
+// WithRedaction masks sensitive data in the logged lines.
+//
+// The redactors are applied to each line in turn.
+// If no redactors are provided then RedactPINs, RedactNumbers, RedactPDUs and
+// RedactIdentities are applied.
+//
+// Redaction is also applied to recordings, which then record complete lines
+// rather than the raw reads and writes.
+//
+// Implies WithLineMode.
+func WithRedaction(redactors ...Redactor) Option {
+	if len(redactors) == 0 {
+		redactors = []Redactor{RedactPINs, RedactNumbers, RedactPDUs, RedactIdentities}
+	}
+	return func(t *Trace) {
+		t.lineMode = true
+		t.redactors = append(t.redactors, redactors...)
+	}
+}
+
+// mask is the replacement for redacted values.
+const mask = "***"
+
+// pinCmds maps the commands containing PINs and PUKs to the index of the
+// first parameter to mask.
+var pinCmds = map[string]int{
+	"+CPIN":  0,
+	"+CPIN2": 0,
+	"+CLCK":  2,
+	"+CPWD":  1,
+}
+
+var (
+	setCmdRe = regexp.MustCompile(`(?i)^AT(\+[A-Z0-9]+)=`)
+	paramRe  = regexp.MustCompile(`"[^"]*"|[^,\r\n]+`)
+)
+
+// RedactPINs masks the PINs and PUKs in +CPIN, +CLCK and +CPWD commands.
+func RedactPINs(l Line) []byte {
+	if l.Dir != DirWrite {
+		return l.Data
+	}
+	m := setCmdRe.FindSubmatchIndex(l.Data)
+	if m == nil {
+		return l.Data
+	}
+	first, ok := pinCmds[string(bytes.ToUpper(l.Data[m[2]:m[3]]))]
+	if !ok {
+		return l.Data
+	}
+	prefix := l.Data[:m[1]]
+	params := l.Data[m[1]:]
+	idx := 0
+	masked := paramRe.ReplaceAllFunc(params, func(p []byte) []byte {
+		defer func() { idx++ }()
+		if idx < first {
+			return p
+		}
+		if p[0] == '"' {
+			return []byte(`"` + mask + `"`)
+		}
+		return []byte(mask)
+	})
+	return append(append([]byte{}, prefix...), masked...)
+}
+
+var (
+	numberCmdRe = regexp.MustCompile(`(?i)^AT(\+CMGS|\+CMGW|\+CMGC)=`)
+	dialRe      = regexp.MustCompile(`(?i)^(ATD)[^;\r\n]+`)
+	numberRspRe = regexp.MustCompile(`^\+(CMT|CMGR|CMGL|CDS|CLIP|CCWA|COLP|CNUM|CPBR|CSCA):`)
+	numberRe    = regexp.MustCompile(`"\+?[0-9*#]{3,}"`)
+)
+
+// RedactNumbers masks phone numbers in the commands that send and store
+// messages, in dial commands, and in the responses and indications that
+// report numbers.
+//
+// This applies to numbers in text form.  Addresses within PDUs are part of
+// the PDU header and are not masked.
+func RedactNumbers(l Line) []byte {
+	switch l.Dir {
+	case DirWrite:
+		if numberCmdRe.Match(l.Data) {
+			return numberRe.ReplaceAll(l.Data, []byte(`"`+mask+`"`))
+		}
+		if dialRe.Match(l.Data) {
+			return dialRe.ReplaceAll(l.Data, []byte("${1}"+mask))
+		}
+	case DirRead:
+		if numberRspRe.Match(l.Data) {
+			return numberRe.ReplaceAll(l.Data, []byte(`"`+mask+`"`))
+		}
+	}
+	return l.Data
+}
+
+var (
+	smsCmdRe    = regexp.MustCompile(`(?i)^AT\+(CMGS|CMGW|CMGC)=`)
+	smsHeaderRe = regexp.MustCompile(`^\+(CMT|CMGR|CMGL|CDS):`)
+	hexRe       = regexp.MustCompile(`^[0-9A-Fa-f]+$`)
+	headerRe    = regexp.MustCompile(`^\+[A-Z0-9]+:`)
+)
+
+// RedactPDUs masks the user data of SMS messages.
+//
+// For PDUs the header, including the user data length and any user data
+// header, is retained but the remainder of the user data is masked.
+// For text mode messages the whole message body is masked, including
+// bodies spanning several lines.
+// The body of a read message extends from its header up to the next header,
+// final result code or command line.
+func RedactPDUs(l Line) []byte {
+	var body bool
+	switch l.Dir {
+	case DirWrite:
+		body = bytes.HasSuffix(l.Data, []byte{0x1a}) && smsCmdRe.Match(l.Cmd)
+	case DirRead:
+		body = smsHeaderRe.Match(l.Header) && !isHeaderLine(l.Data) && !isFinalLine(l.Data)
+	}
+	if !body {
+		return l.Data
+	}
+	content := bytes.TrimRight(l.Data, "\r\n\x1a")
+	term := l.Data[len(content):]
+	if len(content) == 0 {
+		return l.Data
+	}
+	var masked []byte
+	if len(content)%2 == 0 && hexRe.Match(content) {
+		masked = maskPDU(content)
+	} else {
+		masked = []byte(mask)
+	}
+	return append(masked, term...)
+}
+
+// isHeaderLine returns true if the read line is an information response or
+// indication, such as "+CMGR: ...".
+func isHeaderLine(line []byte) bool {
+	return headerRe.Match(line)
+}
+
+// maskPDU masks the user data in a hex encoded PDU, prefixed with the SMSC
+// address as per PDU mode.
+//
+// If the PDU cannot be parsed then it is masked completely.
+func maskPDU(h []byte) []byte {
+	b := make([]byte, len(h)/2)
+	if _, err := hex.Decode(b, h); err != nil {
+		return bytes.Repeat([]byte("X"), len(h))
+	}
+	udOffset := pduUDOffset(b)
+	if udOffset < 0 {
+		return bytes.Repeat([]byte("X"), len(h))
+	}
+	// retain the UDH, if present
+	if b[udOffset-1] != 0 && udOffset < len(b) && pduHasUDH(b) {
+		udOffset += 1 + int(b[udOffset])
+	}
+	if udOffset > len(b) {
+		return bytes.Repeat([]byte("X"), len(h))
+	}
+	masked := append([]byte{}, h[:udOffset*2]...)
+	return append(masked, bytes.Repeat([]byte("X"), len(h)-udOffset*2)...)
+}
+
+// pduHasUDH returns true if the TPDU following the SMSC has the UDHI set.
+func pduHasUDH(b []byte) bool {
+	return b[1+int(b[0])]&0x40 != 0
+}
+
+// pduUDOffset returns the offset of the user data in a PDU mode PDU, i.e.
+// following the UDL, or -1 if the PDU is not a DELIVER or SUBMIT or is
+// truncated.
+func pduUDOffset(b []byte) int {
+	i := 1 + int(b[0]) // skip SMSC
+	if i >= len(b) {
+		return -1
+	}
+	fo := b[i]
+	i++
+	addr := func() {
+		if i < len(b) {
+			i += 2 + (int(b[i])+1)/2
+		}
+	}
+	switch fo & 0x03 {
+	case 0: // DELIVER
+		addr()
+		i += 2 + 7 // PID, DCS, SCTS
+	case 1: // SUBMIT
+		i++ // MR
+		addr()
+		i += 2 // PID, DCS
+		switch (fo >> 3) & 0x03 {
+		case 2:
+			i++
+		case 1, 3:
+			i += 7
+		}
+	default:
+		return -1
+	}
+	i++ // UDL
+	if i > len(b) {
+		return -1
+	}
+	return i
+}
+
+var (
+	identityCmdRe = regexp.MustCompile(`(?i)^AT(\+CIMI|\+CGSN|\+GSN|\+CCID|\+ICCID|\^ICCID|\+QCCID)`)
+	identityRe    = regexp.MustCompile(`[0-9A-Fa-f]{6,}`)
+	imeiRe        = regexp.MustCompile(`(?i)(IMEI(SV)?:\s*)[0-9]+`)
+)
+
+// RedactIdentities masks the IMSI, IMEI and ICCID in responses to the
+// commands that request them, and in labelled lines such as those returned by
+// ATI.
+func RedactIdentities(l Line) []byte {
+	if l.Dir != DirRead {
+		return l.Data
+	}
+	if identityCmdRe.Match(l.Cmd) {
+		return identityRe.ReplaceAll(l.Data, []byte(mask))
+	}
+	return imeiRe.ReplaceAll(l.Data, []byte("${1}"+mask))
+}
+
+// RedactRegexp returns a Redactor that replaces matches of the regexp with the
+// replacement, as per regexp.ReplaceAll, in lines in either direction.
+func RedactRegexp(re *regexp.Regexp, repl string) Redactor {
+	return func(l Line) []byte {
+		return re.ReplaceAll(l.Data, []byte(repl))
+	}
+}
@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package trace_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/trace"
+)
+
+func TestWithRedaction(t *testing.T) {
+	submit := "000101099121436587f900000cf4f29c0e6a97e7f3f0b90c"
+	submitUDH := "004102099121436587f90000270500030102028855101d1d7683f2ef3aa81dce83d2ee343d1d66b3f3a0321e5e1ed301"
+	deliver := "00040B911234567890F000000250100173832305C8329BFD06"
+	patterns := []struct {
+		name      string
+		redactors []trace.Redactor
+		ops       []string
+		log       []string
+	}{
+		{
+			"cpin",
+			[]trace.Redactor{trace.RedactPINs},
+			[]string{">AT+CPIN=\"1234\"\r\n", ">AT+CPIN=\"12345678\",\"4321\"\r\n", ">AT+CPIN?\r\n"},
+			[]string{
+				`w: AT+CPIN="***"\r\n`,
+				`w: AT+CPIN="***","***"\r\n`,
+				`w: AT+CPIN?\r\n`,
+			},
+		},
+		{
+			"clck cpwd",
+			[]trace.Redactor{trace.RedactPINs},
+			[]string{">AT+CLCK=\"SC\",1,\"1234\"\r\n", ">at+cpwd=\"SC\",\"1234\",\"4321\"\r\n", ">AT+CLCK=\"SC\",2\r\n"},
+			[]string{
+				`w: AT+CLCK="SC",1,"***"\r\n`,
+				`w: at+cpwd="SC","***","***"\r\n`,
+				`w: AT+CLCK="SC",2\r\n`,
+			},
+		},
+		{
+			"unquoted pin",
+			[]trace.Redactor{trace.RedactPINs},
+			[]string{">AT+CPIN=1234\r\n"},
+			[]string{`w: AT+CPIN=***\r\n`},
+		},
+		{
+			"numbers",
+			[]trace.Redactor{trace.RedactNumbers},
+			[]string{
+				">AT+CMGS=\"+61412345678\"\r",
+				">ATD+61412345678;\r\n",
+				">AT+CMGS=23\r",
+				"<\r\n+CMT: \"+61412345678\",,\"26/10/18,09:15:02+40\"\r\n",
+				"<+CLIP: \"0412345678\",129\r\n",
+			},
+			[]string{
+				`w: AT+CMGS="***"\r`,
+				`w: ATD***;\r\n`,
+				`w: AT+CMGS=23\r`,
+				`r: \r\n`,
+				`r: +CMT: "***",,"26/10/18,09:15:02+40"\r\n`,
+				`r: +CLIP: "***",129\r\n`,
+			},
+		},
+		{
+			"submit",
+			[]trace.Redactor{trace.RedactPDUs},
+			[]string{">AT+CMGS=23\r", ">" + submit + "\x1a", ">AT+CMGS=39\r", ">" + submitUDH + "\x1a"},
+			[]string{
+				`w: AT+CMGS=23\r`,
+				`w: 000101099121436587f900000c` + strings.Repeat("X", 22) + `\x1a`,
+				`w: AT+CMGS=39\r`,
+				`w: ` + submitUDH[:38] + strings.Repeat("X", len(submitUDH)-38) + `\x1a`,
+			},
+		},
+		{
+			"deliver",
+			[]trace.Redactor{trace.RedactPDUs},
+			[]string{">AT+CMGR=3\r\n", "<\r\n+CMGR: 0,,24\r\n" + deliver + "\r\n\r\nOK\r\n"},
+			[]string{
+				`w: AT+CMGR=3\r\n`,
+				`r: \r\n`,
+				`r: +CMGR: 0,,24\r\n`,
+				`r: ` + deliver[:len(deliver)-10] + `XXXXXXXXXX\r\n`,
+				`r: \r\n`,
+				`r: OK\r\n`,
+			},
+		},
+		{
+			"bad pdu",
+			[]trace.Redactor{trace.RedactPDUs},
+			[]string{"<+CMT: ,24\r\n0003ABCD\r\n"},
+			[]string{
+				`r: +CMT: ,24\r\n`,
+				`r: XXXXXXXX\r\n`,
+			},
+		},
+		{
+			"text body",
+			[]trace.Redactor{trace.RedactPDUs},
+			[]string{
+				">AT+CMGS=\"+61412345678\"\r",
+				">hello world\x1a",
+				"<+CMT: \"+61412345678\",,\"26/10/18,09:15:02+40\"\r\nsecret\r\n",
+			},
+			[]string{
+				`w: AT+CMGS="+61412345678"\r`,
+				`w: ***\x1a`,
+				`r: +CMT: "+61412345678",,"26/10/18,09:15:02+40"\r\n`,
+				`r: ***\r\n`,
+			},
+		},
+		{
+			"multi-line text body",
+			[]trace.Redactor{trace.RedactPDUs},
+			[]string{
+				">AT+CMGL=\"ALL\"\r\n",
+				"<\r\n+CMGL: 1,\"REC READ\",\"+61412345678\"\r\nfirst line\r\n\r\nsecond line\r\n" +
+					"+CMGL: 2,\"REC READ\",\"+61412345678\"\r\nanother\r\n\r\nOK\r\n",
+				">AT+CSQ\r\n",
+				"<\r\n+CMT: \"+61412345678\",,\"26/10/18,09:15:02+40\"\r\nline one\r\nline two\r\n+CSQ: 10,2\r\nOK\r\n",
+				"<\r\n12345\r\n",
+			},
+			[]string{
+				`w: AT+CMGL="ALL"\r\n`,
+				`r: \r\n`,
+				`r: +CMGL: 1,"REC READ","+61412345678"\r\n`,
+				`r: ***\r\n`,
+				`r: \r\n`,
+				`r: ***\r\n`,
+				`r: +CMGL: 2,"REC READ","+61412345678"\r\n`,
+				`r: ***\r\n`,
+				`r: \r\n`,
+				`r: OK\r\n`,
+				`w: AT+CSQ\r\n`,
+				`r: \r\n`,
+				`r: +CMT: "+61412345678",,"26/10/18,09:15:02+40"\r\n`,
+				`r: ***\r\n`,
+				`r: ***\r\n`,
+				`r: +CSQ: 10,2\r\n`,
+				`r: OK\r\n`,
+				`r: \r\n`,
+				`r: 12345\r\n`,
+			},
+		},
+		{
+			"identities",
+			[]trace.Redactor{trace.RedactIdentities},
+			[]string{
+				">AT+CIMI\r\n",
+				"<\r\n505013435063789\r\n\r\nOK\r\n",
+				">AT+CGSN\r\n",
+				"<\r\n+CGSN: 356938035643809\r\n\r\nOK\r\n",
+				">ATI\r\n",
+				"<\r\nModel: E173\r\nIMEI: 356938035643809\r\n\r\nOK\r\n",
+			},
+			[]string{
+				`w: AT+CIMI\r\n`,
+				`r: \r\n`,
+				`r: ***\r\n`,
+				`r: \r\n`,
+				`r: OK\r\n`,
+				`w: AT+CGSN\r\n`,
+				`r: \r\n`,
+				`r: +CGSN: ***\r\n`,
+				`r: \r\n`,
+				`r: OK\r\n`,
+				`w: ATI\r\n`,
+				`r: \r\n`,
+				`r: Model: E173\r\n`,
+				`r: IMEI: ***\r\n`,
+				`r: \r\n`,
+				`r: OK\r\n`,
+			},
+		},
+		{
+			"regexp",
+			[]trace.Redactor{trace.RedactRegexp(regexp.MustCompile(`(\+CUSD=1,)"[^"]*"`), `$1"***"`)},
+			[]string{">AT+CUSD=1,\"*123#\"\r\n", "<\r\n+CUSD: 0,\"*123#\",15\r\n"},
+			[]string{
+				`w: AT+CUSD=1,"***"\r\n`,
+				`r: \r\n`,
+				`r: +CUSD: 0,"*123#",15\r\n`,
+			},
+		},
+		{
+			"defaults",
+			nil,
+			[]string{">AT+CPIN=\"1234\"\r\n", ">AT+CMGS=\"+61412345678\"\r", ">hello\x1a"},
+			[]string{
+				`w: AT+CPIN="***"\r\n`,
+				`w: AT+CMGS="***"\r`,
+				`w: ***\x1a`,
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			mrw := &scriptRW{}
+			b := bytes.Buffer{}
+			l := log.New(&b, "", 0)
+			tr := trace.New(mrw, trace.WithLogger(l), trace.WithTimeFormat(""),
+				trace.WithRedaction(p.redactors...))
+			require.NotNil(t, tr)
+			i := make([]byte, 256)
+			for _, op := range p.ops {
+				var err error
+				if op[0] == '>' {
+					_, err = tr.Write([]byte(op[1:]))
+				} else {
+					mrw.reads = append(mrw.reads, op[1:])
+					_, err = tr.Read(i)
+				}
+				assert.Nil(t, err)
+			}
+			tr.Flush()
+			assert.Equal(t, strings.Join(p.log, "\n")+"\n", b.String())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestRedactionHexDump(t *testing.T) {
+	mrw := &scriptRW{reads: []string{"+CMT: ,24\r\n0003ABCDEF0123456789\r\n"}}
+	b := bytes.Buffer{}
+	l := log.New(&b, "", 0)
+	tr := trace.New(mrw, trace.WithLogger(l), trace.WithTimeFormat(""),
+		trace.WithHexDump(), trace.WithRedaction())
+	i := make([]byte, 256)
+	_, err := tr.Read(i)
+	assert.Nil(t, err)
+	assert.Equal(t, "r: +CMT: ,24\\r\\n\nr: XXXXXXXXXXXXXXXXXXXX\\r\\n\n", b.String())
+}
+
+func TestRedactionRecorder(t *testing.T) {
+	mrw := &scriptRW{reads: []string{"\r\nOK\r\n"}}
+	rec := bytes.Buffer{}
+	tr := trace.New(mrw, trace.WithLogger(log.New(&bytes.Buffer{}, "", 0)),
+		trace.WithRecorder(&rec), trace.WithRedaction(trace.RedactPINs))
+	_, err := tr.Write([]byte("AT+CPIN="))
+	assert.Nil(t, err)
+	_, err = tr.Write([]byte("\"1234\"\r\n"))
+	assert.Nil(t, err)
+	i := make([]byte, 256)
+	_, err = tr.Read(i)
+	assert.Nil(t, err)
+
+	dec := json.NewDecoder(&rec)
+	var texts []string
+	for dec.More() {
+		var r trace.Record
+		require.Nil(t, dec.Decode(&r))
+		texts = append(texts, r.Dir+": "+r.Text)
+	}
+	assert.Equal(t, []string{`w: AT+CPIN="***"` + "\r\n", "r: \r\n", "r: OK\r\n"}, texts)
+}
+
+// scriptRW returns each of the reads in turn, and discards writes.
+type scriptRW struct {
+	reads []string
+}
+
+func (s *scriptRW) Read(p []byte) (int, error) {
+	if len(s.reads) == 0 {
+		return 0, nil
+	}
+	n := copy(p, s.reads[0])
+	s.reads = s.reads[1:]
+	return n, nil
+}
+
+func (s *scriptRW) Write(p []byte) (int, error) {
+	return len(p), nil
+}
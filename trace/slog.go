@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+)
+
+// WithSlog logs trace lines as structured records to the slog.Logger, rather
+// than to the Logger.
+//
+// Each record has the attributes:
+//
+//   - "dir" the direction of the line, DirRead or DirWrite
+//   - "bytes" the length of the line, in bytes
+//   - "line" the line, escaped as per Escape
+//
+// and, while an AT command is in flight:
+//
+//   - "seq" the sequence number of the command, counting the command lines
+//     written through the Trace, starting from 1
+//   - "cmd" the command line
+//
+// so the lines of a command and its response may be correlated.
+// A command is in flight from when the command line is written until a final
+// result code, such as OK or ERROR, is read.
+//
+// If hex dumps are enabled the record also contains a "dump" attribute
+// containing the hex dump of any binary payload.
+//
+// Attributes common to all records, such as the device, may be added to the
+// slog.Logger using With.
+//
+// Implies WithLineMode.
+func WithSlog(l *slog.Logger) Option {
+	return func(t *Trace) {
+		t.lineMode = true
+		t.slog = l
+	}
+}
+
+// WithReadLevel sets the level of the slog records for reads.
+//
+// The default is slog.LevelDebug.
+func WithReadLevel(level slog.Level) Option {
+	return func(t *Trace) {
+		t.rlevel = level
+	}
+}
+
+// WithWriteLevel sets the level of the slog records for writes.
+//
+// The default is slog.LevelDebug.
+func WithWriteLevel(level slog.Level) Option {
+	return func(t *Trace) {
+		t.wlevel = level
+	}
+}
+
+// slogLine logs a line to the slog.Logger.
+//
+// The raw line is used to determine the length, while the data, which may be
+// redacted, is logged.
+func (t *Trace) slogLine(dir string, line, data []byte) {
+	level, msg := t.rlevel, "read"
+	if dir == DirWrite {
+		level, msg = t.wlevel, "write"
+	}
+	ctx := context.Background()
+	if !t.slog.Enabled(ctx, level) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, 6)
+	attrs = append(attrs,
+		slog.String("dir", dir),
+		slog.Int("bytes", len(line)),
+		slog.String("line", Escape(data)))
+	if t.cmdLog != "" {
+		attrs = append(attrs,
+			slog.Uint64("seq", t.cmdSeq),
+			slog.String("cmd", t.cmdLog))
+	}
+	if t.hexDump {
+		if payload := binaryPayload(data); payload != nil {
+			attrs = append(attrs, slog.String("dump", hex.Dump(payload)))
+		}
+	}
+	t.slog.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package trace_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/trace"
+)
+
+func TestWithSlog(t *testing.T) {
+	type rec = map[string]interface{}
+	patterns := []struct {
+		name    string
+		options []trace.Option
+		ops     []string
+		recs    []rec
+	}{
+		{
+			"command",
+			nil,
+			[]string{">ATI\r\n", "<\r\nModel: E173\r\n\r\nOK\r\n", "<\r\nRING\r\n"},
+			[]rec{
+				{"level": "DEBUG", "msg": "write", "dir": "w", "bytes": 5.0, "line": `ATI\r\n`, "seq": 1.0, "cmd": "ATI"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 2.0, "line": `\r\n`, "seq": 1.0, "cmd": "ATI"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 13.0, "line": `Model: E173\r\n`, "seq": 1.0, "cmd": "ATI"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 2.0, "line": `\r\n`, "seq": 1.0, "cmd": "ATI"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 4.0, "line": `OK\r\n`, "seq": 1.0, "cmd": "ATI"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 2.0, "line": `\r\n`},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 6.0, "line": `RING\r\n`},
+			},
+		},
+		{
+			"sms",
+			nil,
+			[]string{">AT+CMGS=6\r", "<\r\n> ", ">00010002812100\x1a", "<\r\n+CMS ERROR: 304\r\n", ">ATZ\r\n"},
+			[]rec{
+				{"level": "DEBUG", "msg": "write", "dir": "w", "bytes": 10.0, "line": `AT+CMGS=6\r`, "seq": 1.0, "cmd": "AT+CMGS=6"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 2.0, "line": `\r\n`, "seq": 1.0, "cmd": "AT+CMGS=6"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 2.0, "line": `> `, "seq": 1.0, "cmd": "AT+CMGS=6"},
+				{"level": "DEBUG", "msg": "write", "dir": "w", "bytes": 15.0, "line": `00010002812100\x1a`, "seq": 1.0, "cmd": "AT+CMGS=6"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 2.0, "line": `\r\n`, "seq": 1.0, "cmd": "AT+CMGS=6"},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 17.0, "line": `+CMS ERROR: 304\r\n`, "seq": 1.0, "cmd": "AT+CMGS=6"},
+				{"level": "DEBUG", "msg": "write", "dir": "w", "bytes": 5.0, "line": `ATZ\r\n`, "seq": 2.0, "cmd": "ATZ"},
+			},
+		},
+		{
+			"levels",
+			[]trace.Option{trace.WithReadLevel(slog.LevelInfo), trace.WithWriteLevel(slog.LevelWarn)},
+			[]string{">ATZ\r\n", "<OK\r\n"},
+			[]rec{
+				{"level": "WARN", "msg": "write", "dir": "w", "bytes": 5.0, "line": `ATZ\r\n`, "seq": 1.0, "cmd": "ATZ"},
+				{"level": "INFO", "msg": "read", "dir": "r", "bytes": 4.0, "line": `OK\r\n`, "seq": 1.0, "cmd": "ATZ"},
+			},
+		},
+		{
+			"disabled",
+			[]trace.Option{trace.WithReadLevel(slog.LevelDebug - 1)},
+			[]string{">ATZ\r\n", "<OK\r\n", ">ATZ\r\n"},
+			[]rec{
+				{"level": "DEBUG", "msg": "write", "dir": "w", "bytes": 5.0, "line": `ATZ\r\n`, "seq": 1.0, "cmd": "ATZ"},
+				{"level": "DEBUG", "msg": "write", "dir": "w", "bytes": 5.0, "line": `ATZ\r\n`, "seq": 2.0, "cmd": "ATZ"},
+			},
+		},
+		{
+			"redacted",
+			[]trace.Option{trace.WithRedaction()},
+			[]string{">AT+CPIN=\"1234\"\r\n", "<OK\r\n"},
+			[]rec{
+				{"level": "DEBUG", "msg": "write", "dir": "w", "bytes": 16.0, "line": `AT+CPIN="***"\r\n`, "seq": 1.0, "cmd": `AT+CPIN="***"`},
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 4.0, "line": `OK\r\n`, "seq": 1.0, "cmd": `AT+CPIN="***"`},
+			},
+		},
+		{
+			"hex dump",
+			[]trace.Option{trace.WithHexDump()},
+			[]string{"<\xff\r\n"},
+			[]rec{
+				{"level": "DEBUG", "msg": "read", "dir": "r", "bytes": 3.0, "line": `\xff\r\n`,
+					"dump": "00000000  ff                                                |.|\n"},
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b := bytes.Buffer{}
+			h := slog.NewJSONHandler(&b, &slog.HandlerOptions{
+				Level: slog.LevelDebug,
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			})
+			mrw := &scriptRW{}
+			tr := trace.New(mrw, append(p.options, trace.WithSlog(slog.New(h)))...)
+			require.NotNil(t, tr)
+			i := make([]byte, 256)
+			for _, op := range p.ops {
+				var err error
+				if op[0] == '>' {
+					_, err = tr.Write([]byte(op[1:]))
+				} else {
+					mrw.reads = append(mrw.reads, op[1:])
+					_, err = tr.Read(i)
+				}
+				assert.Nil(t, err)
+			}
+			dec := json.NewDecoder(&b)
+			recs := []rec{}
+			for dec.More() {
+				var r rec
+				require.Nil(t, dec.Decode(&r))
+				recs = append(recs, r)
+			}
+			assert.Equal(t, p.recs, recs)
+		}
+		t.Run(p.name, f)
+	}
+}
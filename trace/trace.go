@@ -7,19 +7,58 @@
 package trace
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Trace is a trace log on an io.ReadWriter.
 //
 // All reads and writes are written to the logger.
+//
+// Trace also passes through Close to the wrapped object, and logs it as a
+// control event.  Use Wrap to also pass through the control methods of a
+// Port.
 type Trace struct {
 	rw   io.ReadWriter
 	l    Logger
 	wfmt string
 	rfmt string
+	cfmt string
+
+	// line mode
+	lineMode   bool
+	hexDump    bool
+	timeFormat string
+	mu         sync.Mutex
+	rbuf       []byte
+	wbuf       []byte
+
+	// redaction
+	redactors []Redactor
+	cmd       []byte
+	rprev     []byte
+	wprev     []byte
+	header    []byte
+
+	// structured logging
+	slog   *slog.Logger
+	rlevel slog.Level
+	wlevel slog.Level
+	cmdSeq uint64
+	cmdLog string
+
+	// recording
+	rmu sync.Mutex
+	rec *json.Encoder
 }
 
 // Logger defines the interface used to log trace messages.
@@ -33,9 +72,13 @@ type Option func(*Trace)
 // New creates a new trace on the io.ReadWriter.
 func New(rw io.ReadWriter, options ...Option) *Trace {
 	t := &Trace{
-		rw:   rw,
-		wfmt: "w: %s",
-		rfmt: "r: %s",
+		rw:         rw,
+		wfmt:       "w: %s",
+		rfmt:       "r: %s",
+		cfmt:       "c: %s",
+		timeFormat: DefaultTimeFormat,
+		rlevel:     slog.LevelDebug,
+		wlevel:     slog.LevelDebug,
 	}
 	for _, option := range options {
 		option(t)
@@ -46,6 +89,9 @@ func New(rw io.ReadWriter, options ...Option) *Trace {
 	return t
 }
 
+// DefaultTimeFormat is the layout used to timestamp lines in line mode.
+const DefaultTimeFormat = "15:04:05.000"
+
 // WithReadFormat sets the format used for read logs.
 func WithReadFormat(format string) Option {
 	return func(t *Trace) {
@@ -69,10 +115,74 @@ func WithLogger(l Logger) Option {
 	}
 }
 
+// WithLineMode logs complete lines, rather than the raw chunks returned by
+// each read and write.
+//
+// Lines are terminated by a newline, or by the ctrl-Z or escape characters
+// that terminate an SMS PDU. Writes may also be terminated by a bare carriage
+// return, as is the first line of an SMS command. The SMS prompt, which is not
+// newline terminated, is logged as a line of its own.
+//
+// Each line is prefixed with a timestamp, and the control characters within it
+// are escaped, e.g. "AT\r\n", so the line is logged as a string rather than
+// raw bytes.
+func WithLineMode() Option {
+	return func(t *Trace) {
+		t.lineMode = true
+	}
+}
+
+// WithTimeFormat sets the layout, as per time.Format, used to timestamp lines
+// in line mode.
+//
+// An empty layout disables timestamps.
+// The default is DefaultTimeFormat, which has millisecond precision.
+func WithTimeFormat(layout string) Option {
+	return func(t *Trace) {
+		t.timeFormat = layout
+	}
+}
+
+// WithHexDump adds a hexdump of binary payloads to the lines logged in line
+// mode.
+//
+// Lines containing non-printable characters are dumped as is, while lines
+// containing long hex strings, such as PDUs, are decoded and then dumped.
+//
+// Implies WithLineMode.
+func WithHexDump() Option {
+	return func(t *Trace) {
+		t.lineMode = true
+		t.hexDump = true
+	}
+}
+
+// WithRecorder records all reads and writes to the writer, as JSON lines.
+//
+// Each line is a Record, so the recorded session may be played back using the
+// replay package.
+//
+// If combined with WithRedaction then the redacted lines are recorded, rather
+// than the raw reads and writes, so sensitive data is not recorded either.
+// Such recordings are only replayable if the commands written during replay
+// are redacted to match.
+func WithRecorder(w io.Writer) Option {
+	return func(t *Trace) {
+		t.rec = json.NewEncoder(w)
+	}
+}
+
 func (t *Trace) Read(p []byte) (n int, err error) {
 	n, err = t.rw.Read(p)
 	if n > 0 {
-		t.l.Printf(t.rfmt, p[:n])
+		if len(t.redactors) == 0 {
+			t.record(DirRead, p[:n])
+		}
+		if t.lineMode {
+			t.logLines(&t.rbuf, p[:n], DirRead)
+		} else {
+			t.l.Printf(t.rfmt, p[:n])
+		}
 	}
 	return n, err
 }
@@ -80,7 +190,218 @@ func (t *Trace) Read(p []byte) (n int, err error) {
 func (t *Trace) Write(p []byte) (n int, err error) {
 	n, err = t.rw.Write(p)
 	if n > 0 {
-		t.l.Printf(t.wfmt, p[:n])
+		if len(t.redactors) == 0 {
+			t.record(DirWrite, p[:n])
+		}
+		if t.lineMode {
+			t.logLines(&t.wbuf, p[:n], DirWrite)
+		} else {
+			t.l.Printf(t.wfmt, p[:n])
+		}
 	}
 	return n, err
 }
+
+// Flush logs any partial lines buffered in line mode.
+func (t *Trace) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.rbuf) > 0 {
+		t.logLine(DirRead, t.rbuf)
+		t.rbuf = t.rbuf[:0]
+	}
+	if len(t.wbuf) > 0 {
+		t.logLine(DirWrite, t.wbuf)
+		t.wbuf = t.wbuf[:0]
+	}
+}
+
+// record writes the data to the recorder, if any.
+func (t *Trace) record(dir string, data []byte) {
+	if t.rec == nil {
+		return
+	}
+	t.rmu.Lock()
+	defer t.rmu.Unlock()
+	if err := t.rec.Encode(NewRecord(dir, time.Now(), data)); err != nil {
+		t.l.Printf("record: %v", err)
+	}
+}
+
+// prompt is the SMS prompt, which is returned by the modem without a trailing
+// newline.
+var prompt = []byte("> ")
+
+// logLines appends the data to the buffer and logs any complete lines.
+func (t *Trace) logLines(buf *[]byte, p []byte, dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	terms := "\n\x1a\x1b"
+	if dir == DirWrite {
+		terms += "\r"
+	}
+	b := append(*buf, p...)
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, terms)
+		if i >= 0 && b[i] == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+			i++
+		}
+		if i < 0 {
+			if dir == DirRead && bytes.Equal(b, prompt) {
+				t.logLine(dir, b)
+				b = b[len(b):]
+			}
+			break
+		}
+		t.logLine(dir, b[:i+1])
+		b = b[i+1:]
+	}
+	*buf = append((*buf)[:0], b...)
+}
+
+// logLine logs a complete line, and its hexdump if applicable.
+//
+// If redacting, the redacted line is also recorded.
+func (t *Trace) logLine(dir string, line []byte) {
+	data := t.redact(dir, line)
+	if len(t.redactors) > 0 {
+		t.record(dir, data)
+	}
+	if dir == DirWrite && isCmdLine(line) {
+		t.cmdSeq++
+		t.cmdLog = string(bytes.TrimRight(data, "\r\n"))
+	}
+	if t.slog != nil {
+		t.slogLine(dir, line, data)
+	} else {
+		t.printLine(dir, data)
+	}
+	if dir == DirRead && isFinalLine(line) {
+		t.cmdLog = ""
+	}
+}
+
+// printLine logs a line to the Logger.
+func (t *Trace) printLine(dir string, line []byte) {
+	format := t.rfmt
+	if dir == DirWrite {
+		format = t.wfmt
+	}
+	s := Escape(line)
+	if t.timeFormat != "" {
+		t.l.Printf("%s "+format, time.Now().Format(t.timeFormat), s)
+	} else {
+		t.l.Printf(format, s)
+	}
+	if !t.hexDump {
+		return
+	}
+	if data := binaryPayload(line); data != nil {
+		for _, dl := range strings.SplitAfter(strings.TrimSuffix(hex.Dump(data), "\n"), "\n") {
+			t.l.Printf("  %s", strings.TrimSuffix(dl, "\n"))
+		}
+	}
+}
+
+// redact applies the redactors to the line, and updates the context used by
+// subsequent redactions.
+func (t *Trace) redact(dir string, line []byte) []byte {
+	if len(t.redactors) == 0 {
+		return line
+	}
+	l := Line{Dir: dir, Data: line, Cmd: t.cmd, Prev: t.rprev, Header: t.header}
+	if dir == DirWrite {
+		l.Prev = t.wprev
+		l.Header = nil
+	}
+	for _, r := range t.redactors {
+		l.Data = r(l)
+	}
+	prev := append([]byte{}, line...)
+	if dir == DirWrite {
+		t.wprev = prev
+		if isCmdLine(line) {
+			t.cmd = prev
+			t.header = nil
+		}
+	} else if len(bytes.TrimSpace(line)) > 0 {
+		t.rprev = prev
+		switch {
+		case isHeaderLine(line):
+			t.header = prev
+		case isFinalLine(line):
+			t.header = nil
+		}
+	}
+	return l.Data
+}
+
+// isCmdLine returns true if the written line is a command line.
+func isCmdLine(line []byte) bool {
+	return len(line) >= 2 && bytes.EqualFold(line[:2], []byte("AT"))
+}
+
+// isFinalLine returns true if the read line is a final result code,
+// terminating the response to a command.
+func isFinalLine(line []byte) bool {
+	l := string(bytes.TrimSpace(line))
+	switch {
+	case l == "OK",
+		strings.HasPrefix(l, "ERROR"),
+		strings.HasPrefix(l, "+CME ERROR:"),
+		strings.HasPrefix(l, "+CMS ERROR:"),
+		strings.HasPrefix(l, "CONNECT"),
+		l == "BUSY",
+		l == "NO ANSWER",
+		l == "NO CARRIER",
+		l == "NO DIALTONE":
+		return true
+	}
+	return false
+}
+
+// minHexDump is the minimum length of a hex string, in characters, that is
+// considered to be a binary payload.
+const minHexDump = 16
+
+// binaryPayload returns the binary payload contained in the line, if any.
+func binaryPayload(line []byte) []byte {
+	l := bytes.TrimRight(line, "\r\n\x1a\x1b")
+	if len(l) >= minHexDump && len(l)%2 == 0 {
+		if data, err := hex.DecodeString(string(l)); err == nil {
+			return data
+		}
+	}
+	for _, c := range l {
+		if c < ' ' || c > '~' {
+			return l
+		}
+	}
+	return nil
+}
+
+// Escape returns the data as a string with control and other non-printable
+// characters escaped.
+//
+// The common control characters are escaped as per Go string literals, e.g.
+// "\r", "\n", while others are escaped in hex, e.g. "\x1a", "\x1b".
+func Escape(data []byte) string {
+	var sb strings.Builder
+	for _, c := range data {
+		switch {
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c == '\\':
+			sb.WriteString(`\\`)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
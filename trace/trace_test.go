@@ -6,7 +6,9 @@ package trace_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -77,3 +79,196 @@ func TestWriteFormat(t *testing.T) {
 	assert.Equal(t, 3, n)
 	assert.Equal(t, []byte("W: [116 119 111]\n"), b.Bytes())
 }
+
+func TestLineMode(t *testing.T) {
+	patterns := []struct {
+		name   string
+		reads  []string
+		writes []string
+		log    string
+	}{
+		{
+			"split",
+			[]string{"\r\n+CSQ: 1", "2,99\r\n\r\nOK", "\r\n"},
+			nil,
+			"r: \\r\\n\nr: +CSQ: 12,99\\r\\n\nr: \\r\\n\nr: OK\\r\\n\n",
+		},
+		{
+			"merged",
+			[]string{"\r\nOK\r\n\r\n+CMTI: \"SM\",3\r\n"},
+			nil,
+			"r: \\r\\n\nr: OK\\r\\n\nr: \\r\\n\nr: +CMTI: \"SM\",3\\r\\n\n",
+		},
+		{
+			"prompt",
+			[]string{"\r\n> "},
+			nil,
+			"r: \\r\\n\nr: > \n",
+		},
+		{
+			"sms",
+			nil,
+			[]string{"AT+CMGS=3\r", "0001\x1a"},
+			"w: AT+CMGS=3\\r\nw: 0001\\x1a\n",
+		},
+		{
+			"escape",
+			nil,
+			[]string{"\x1b\r\n\r\n", "ATZ\r\n"},
+			"w: \\x1b\nw: \\r\\n\nw: \\r\\n\nw: ATZ\\r\\n\n",
+		},
+		{
+			"partial",
+			[]string{"\r\nOK"},
+			[]string{"ATI"},
+			"r: \\r\\n\nr: OK\nw: ATI\n",
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			mrw := bytes.NewBufferString(strings.Join(p.reads, ""))
+			b := bytes.Buffer{}
+			l := log.New(&b, "", 0)
+			tr := trace.New(&chunkRW{mrw, p.reads}, trace.WithLogger(l),
+				trace.WithLineMode(), trace.WithTimeFormat(""))
+			require.NotNil(t, tr)
+			i := make([]byte, 64)
+			for range p.reads {
+				_, err := tr.Read(i)
+				assert.Nil(t, err)
+			}
+			for _, w := range p.writes {
+				n, err := tr.Write([]byte(w))
+				assert.Nil(t, err)
+				assert.Equal(t, len(w), n)
+			}
+			tr.Flush()
+			assert.Equal(t, p.log, b.String())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	mrw := bytes.NewBufferString("\r\nOK\r\n")
+	b := bytes.Buffer{}
+	l := log.New(&b, "", 0)
+	// default
+	tr := trace.New(mrw, trace.WithLogger(l), trace.WithLineMode())
+	require.NotNil(t, tr)
+	i := make([]byte, 10)
+	_, err := tr.Read(i)
+	assert.Nil(t, err)
+	assert.Regexp(t, `^\d\d:\d\d:\d\d\.\d{3} r: \\r\\n\n\d\d:\d\d:\d\d\.\d{3} r: OK\\r\\n\n$`, b.String())
+
+	// custom
+	b.Reset()
+	mrw = bytes.NewBufferString("\r\nOK\r\n")
+	tr = trace.New(mrw, trace.WithLogger(l), trace.WithLineMode(),
+		trace.WithTimeFormat("2006"))
+	_, err = tr.Read(i)
+	assert.Nil(t, err)
+	assert.Regexp(t, `^\d{4} r: \\r\\n\n\d{4} r: OK\\r\\n\n$`, b.String())
+}
+
+func TestHexDump(t *testing.T) {
+	patterns := []struct {
+		name string
+		data string
+		log  string
+	}{
+		{
+			"text",
+			"+CMGS: 42\r\n",
+			"r: +CMGS: 42\\r\\n\n",
+		},
+		{
+			"short hex",
+			"12345678\r\n",
+			"r: 12345678\\r\\n\n",
+		},
+		{
+			"pdu",
+			"0001000b916407281553f80000\r\n",
+			"r: 0001000b916407281553f80000\\r\\n\n" +
+				"  00000000  00 01 00 0b 91 64 07 28  15 53 f8 00 00           |.....d.(.S...|\n",
+		},
+		{
+			"binary",
+			"ab\x00\xffcd\r\n",
+			"r: ab\\x00\\xffcd\\r\\n\n" +
+				"  00000000  61 62 00 ff 63 64                                 |ab..cd|\n",
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			mrw := bytes.NewBufferString(p.data)
+			b := bytes.Buffer{}
+			l := log.New(&b, "", 0)
+			tr := trace.New(mrw, trace.WithLogger(l), trace.WithHexDump(),
+				trace.WithTimeFormat(""))
+			require.NotNil(t, tr)
+			i := make([]byte, 64)
+			_, err := tr.Read(i)
+			assert.Nil(t, err)
+			assert.Equal(t, p.log, b.String())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, `AT\r\n`, trace.Escape([]byte("AT\r\n")))
+	assert.Equal(t, `\x1a\x1b\t\\\x00\xff`, trace.Escape([]byte("\x1a\x1b\t\\\x00\xff")))
+	assert.Equal(t, `> `, trace.Escape([]byte("> ")))
+}
+
+// chunkRW returns reads in the given chunks.
+type chunkRW struct {
+	*bytes.Buffer
+	chunks []string
+}
+
+func (c *chunkRW) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return c.Buffer.Read(p)
+	}
+	n, err := c.Buffer.Read(p[:len(c.chunks[0])])
+	c.chunks = c.chunks[1:]
+	return n, err
+}
+
+func TestWithRecorder(t *testing.T) {
+	mrw := bytes.NewBufferString("\r\n\xffOK\r\n")
+	b := bytes.Buffer{}
+	l := log.New(&b, "", 0)
+	rec := bytes.Buffer{}
+	tr := trace.New(mrw, trace.WithLogger(l), trace.WithRecorder(&rec))
+	require.NotNil(t, tr)
+	_, err := tr.Write([]byte("ATI\r\n"))
+	assert.Nil(t, err)
+	i := make([]byte, 7)
+	_, err = tr.Read(i)
+	assert.Nil(t, err)
+
+	dec := json.NewDecoder(&rec)
+	var r trace.Record
+	require.Nil(t, dec.Decode(&r))
+	assert.Equal(t, trace.DirWrite, r.Dir)
+	assert.Equal(t, "ATI\r\n", r.Text)
+	assert.Equal(t, "", r.Hex)
+	assert.False(t, r.Time.IsZero())
+	data, err := r.Data()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("ATI\r\n"), data)
+
+	r = trace.Record{}
+	require.Nil(t, dec.Decode(&r))
+	assert.Equal(t, trace.DirRead, r.Dir)
+	assert.Equal(t, "", r.Text)
+	assert.Equal(t, "0d0aff4f4b0d0a", r.Hex)
+	data, err = r.Data()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("\r\n\xffOK\r\n"), data)
+	assert.False(t, dec.More())
+}
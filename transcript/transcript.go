@@ -0,0 +1,331 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+// Package transcript provides a mock modem that plays the modem side of a
+// conversation written as a text transcript.
+//
+// A transcript contains one step per line, with the direction of the step
+// given by its prefix:
+//
+//	# comment
+//	> AT+CMGF=0
+//	< OK
+//	< +CMT: ,24
+//	< 00040B911234567890F000000250100173832305C8329BFD06
+//	> AT+CNMA
+//	< OK
+//
+// Lines starting with "> " are written by the host to the modem, and lines
+// starting with "< " are returned by the modem.
+// The payload may contain the escapes used by trace.Escape, e.g. "\r",
+// "\x1a".
+// Writes are terminated with "\r\n" unless the payload already ends with
+// "\r", "\n", ctrl-Z or escape, as per the SMS commands, e.g.
+// "> AT+CMGS=23\r" and "> 0011...\x1a".
+// Reads are terminated with "\r\n" unless the payload is the SMS prompt, ">",
+// or already ends with "\n".
+//
+// Lines logged by trace.WithLineMode, with their default "w: " and "r: "
+// formats, are also accepted, with any leading timestamp ignored, so a trace
+// captured from a modem may be pasted directly into a transcript.
+// The payload of such lines is used exactly as logged.
+//
+// Blank lines, and lines starting with "#", are ignored.
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/warthog618/modem/internal/player"
+	"github.com/warthog618/modem/trace"
+)
+
+// Transcript is an io.ReadWriter that plays the modem side of a transcript.
+//
+// Reads return the steps read from the modem, in order, but only once all the
+// writes preceding them in the transcript have been made.
+// Writes must match the steps written to the modem, though they need not be
+// chunked the same as the transcript.
+type Transcript struct {
+	name  string
+	steps []Step
+	p     *player.Player
+}
+
+// Step is one line of a transcript.
+type Step struct {
+	// Line is the line number of the step in the transcript.
+	Line int
+
+	// Dir is the direction of the step, either trace.DirRead or
+	// trace.DirWrite.
+	Dir string
+
+	// Data is the data read from or written to the modem.
+	Data []byte
+}
+
+func (s Step) String() string {
+	prefix := "<"
+	if s.Dir == trace.DirWrite {
+		prefix = ">"
+	}
+	return fmt.Sprintf("%s %s", prefix, trace.Escape(s.Data))
+}
+
+var (
+	// ErrClosed indicates the Transcript has been closed.
+	ErrClosed = player.ErrClosed
+
+	// ErrUnexpectedWrite indicates a write did not match the transcript.
+	ErrUnexpectedWrite = player.ErrUnexpectedWrite
+
+	// ErrIncomplete indicates the transcript was not completely played.
+	ErrIncomplete = player.ErrIncomplete
+)
+
+// New loads the transcript from the named file and returns a Transcript that
+// is verified once the test completes.
+//
+// The test fails immediately if the transcript cannot be loaded, and fails
+// with a description of the mismatch if the transcript is not played as
+// written.
+func New(tb testing.TB, path string) *Transcript {
+	tb.Helper()
+	t, err := Load(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		t.Close()
+		if err := t.Verify(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return t
+}
+
+// Load reads the transcript from the named file.
+func Load(path string) (*Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(path, f)
+}
+
+// traceRe matches a line logged by trace.WithLineMode.
+var traceRe = regexp.MustCompile(`(?:^|\s)([rw]): (.*)$`)
+
+// Parse reads the transcript from r.
+//
+// The name identifies the transcript in errors.
+func Parse(name string, r io.Reader) (*Transcript, error) {
+	t := &Transcript{name: name}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		l := strings.TrimRight(s.Text(), "\r")
+		trimmed := strings.TrimSpace(l)
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+		step := Step{Line: line}
+		switch {
+		case trimmed == ">" || strings.HasPrefix(trimmed, "> "):
+			step.Dir = trace.DirWrite
+			step.Data = writeData(unescape(payload(l)))
+		case trimmed == "<" || strings.HasPrefix(trimmed, "< "):
+			step.Dir = trace.DirRead
+			step.Data = readData(unescape(payload(l)))
+		default:
+			m := traceRe.FindStringSubmatch(l)
+			if m == nil {
+				return nil, fmt.Errorf("%s:%d: unrecognised step %q", name, line, l)
+			}
+			step.Dir = trace.DirRead
+			if m[1] == "w" {
+				step.Dir = trace.DirWrite
+			}
+			step.Data = unescape(m[2])
+		}
+		if len(step.Data) == 0 {
+			continue
+		}
+		t.steps = append(t.steps, step)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	entries := make([]player.Entry, len(t.steps))
+	for i, step := range t.steps {
+		entries[i] = player.Entry{Dir: step.Dir, Data: step.Data}
+	}
+	t.p = player.New(entries,
+		player.WithMismatch(t.mismatch),
+		player.WithIncomplete(t.incomplete))
+	return t, nil
+}
+
+// Steps returns the steps of the transcript.
+func (t *Transcript) Steps() []Step {
+	return append([]Step{}, t.steps...)
+}
+
+// Done returns a channel that is closed once the transcript has been
+// completely played.
+//
+// This allows tests to wait for asynchronous exchanges, such as those
+// triggered by indications, to complete.
+func (t *Transcript) Done() <-chan struct{} {
+	return t.p.Done()
+}
+
+// Read returns the next step read from the modem.
+//
+// Read blocks until the step becomes available, and returns io.EOF once the
+// Transcript is closed.
+// Once the transcript is exhausted Read blocks until the Transcript is closed.
+func (t *Transcript) Read(p []byte) (int, error) {
+	return t.p.Read(p)
+}
+
+// Write checks the data matches the next step written to the modem.
+//
+// Returns ErrUnexpectedWrite if the data does not match the transcript, in
+// which case the remainder of the transcript is not played.
+func (t *Transcript) Write(p []byte) (int, error) {
+	return t.p.Write(p)
+}
+
+// Close ends the playback, unblocking any pending reads.
+func (t *Transcript) Close() error {
+	return t.p.Close()
+}
+
+// Verify returns an error if a write did not match the transcript or if the
+// transcript has not been completely played.
+//
+// The error describes the mismatch, or lists the steps remaining, with their
+// line numbers in the transcript.
+func (t *Transcript) Verify() error {
+	return t.p.Verify()
+}
+
+// mismatch describes a write that does not match the transcript.
+func (t *Transcript) mismatch(idx int, remaining, got []byte) string {
+	var sb strings.Builder
+	if idx < len(t.steps) {
+		fmt.Fprintf(&sb, "%s:%d", t.name, t.steps[idx].Line)
+	} else {
+		fmt.Fprintf(&sb, "%s: after end of transcript", t.name)
+	}
+	if idx > 0 {
+		fmt.Fprintf(&sb, "\n  previous: %s", t.steps[idx-1])
+	}
+	if idx < len(t.steps) {
+		step := t.steps[idx]
+		step.Data = remaining
+		fmt.Fprintf(&sb, "\n  expected: %s", step)
+	} else {
+		sb.WriteString("\n  expected: nothing")
+	}
+	fmt.Fprintf(&sb, "\n  got:      %s", Step{Dir: trace.DirWrite, Data: got})
+	return sb.String()
+}
+
+// incomplete lists the steps remaining to be played.
+func (t *Transcript) incomplete(idx int, remaining []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d of %d steps not played:", t.name, len(t.steps)-idx, len(t.steps))
+	for i := idx; i < len(t.steps); i++ {
+		step := t.steps[i]
+		if i == idx {
+			step.Data = remaining
+		}
+		fmt.Fprintf(&sb, "\n  %d: %s", step.Line, step)
+	}
+	return sb.String()
+}
+
+// payload returns the payload of a "> " or "< " step.
+func payload(l string) string {
+	l = strings.TrimLeft(l, " \t")
+	if len(l) < 2 {
+		return ""
+	}
+	return l[2:]
+}
+
+// writeData adds the line terminator to a write payload, if necessary.
+func writeData(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	switch data[len(data)-1] {
+	case '\r', '\n', 0x1a, 0x1b:
+		return data
+	}
+	return append(data, "\r\n"...)
+}
+
+// readData adds the line terminator to a read payload, if necessary.
+func readData(data []byte) []byte {
+	switch {
+	case len(data) == 0:
+		return data
+	case string(data) == ">":
+		return []byte("> ")
+	case data[len(data)-1] == '\n', string(data) == "> ":
+		return data
+	}
+	return append(data, "\r\n"...)
+}
+
+// unescape reverses trace.Escape.
+//
+// Backslashes not forming a recognised escape are retained.
+func unescape(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			b = append(b, c)
+			continue
+		}
+		switch s[i+1] {
+		case 'r':
+			b = append(b, '\r')
+		case 'n':
+			b = append(b, '\n')
+		case 't':
+			b = append(b, '\t')
+		case '\\':
+			b = append(b, '\\')
+		case 'x':
+			if i+3 < len(s) {
+				if v, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+					b = append(b, byte(v))
+					i += 3
+					continue
+				}
+			}
+			b = append(b, c)
+			continue
+		default:
+			b = append(b, c)
+			continue
+		}
+		i++
+	}
+	return b
+}
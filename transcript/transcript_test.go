@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2026 Kent Gibson <warthog618@gmail.com>.
+
+package transcript_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/warthog618/modem/at"
+	"github.com/warthog618/modem/trace"
+	"github.com/warthog618/modem/transcript"
+)
+
+func TestParse(t *testing.T) {
+	patterns := []struct {
+		name  string
+		text  string
+		steps []transcript.Step
+		err   bool
+	}{
+		{"empty", "", nil, false},
+		{"comments", "# comment\n\n  # indented\n", nil, false},
+		{
+			"write",
+			"> AT+CMGF=0",
+			[]transcript.Step{{Line: 1, Dir: trace.DirWrite, Data: []byte("AT+CMGF=0\r\n")}},
+			false,
+		},
+		{
+			"write terminated",
+			"> AT+CMGS=6\\r\n> 0001\\x1a",
+			[]transcript.Step{
+				{Line: 1, Dir: trace.DirWrite, Data: []byte("AT+CMGS=6\r")},
+				{Line: 2, Dir: trace.DirWrite, Data: []byte("0001\x1a")},
+			},
+			false,
+		},
+		{
+			"read",
+			"< OK\r\n< >\n< raw\\n",
+			[]transcript.Step{
+				{Line: 1, Dir: trace.DirRead, Data: []byte("OK\r\n")},
+				{Line: 2, Dir: trace.DirRead, Data: []byte("> ")},
+				{Line: 3, Dir: trace.DirRead, Data: []byte("raw\n")},
+			},
+			false,
+		},
+		{
+			"trace",
+			"w: ATI\\r\\n\n09:30:00.000 r: \\r\\nOK\\r\\n",
+			[]transcript.Step{
+				{Line: 1, Dir: trace.DirWrite, Data: []byte("ATI\r\n")},
+				{Line: 2, Dir: trace.DirRead, Data: []byte("\r\nOK\r\n")},
+			},
+			false,
+		},
+		{
+			"escapes",
+			"> a\\\\b\\x1\\q",
+			[]transcript.Step{{Line: 1, Dir: trace.DirWrite, Data: []byte("a\\b\\x1\\q\r\n")}},
+			false,
+		},
+		{"unrecognised", "AT+CMGF=0", nil, true},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			tr, err := transcript.Parse("test", strings.NewReader(p.text))
+			if p.err {
+				assert.NotNil(t, err)
+				assert.Nil(t, tr)
+				return
+			}
+			require.Nil(t, err)
+			if p.steps == nil {
+				assert.Empty(t, tr.Steps())
+			} else {
+				assert.Equal(t, p.steps, tr.Steps())
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tr, err := transcript.Load("testdata/cmgs.txt")
+	require.Nil(t, err)
+	assert.Equal(t, 5, len(tr.Steps()))
+
+	tr, err = transcript.Load("testdata/nonexistent.txt")
+	assert.NotNil(t, err)
+	assert.Nil(t, tr)
+}
+
+func TestNew(t *testing.T) {
+	tr := transcript.New(t, "testdata/cmgs.txt")
+	a := at.New(tr, at.WithTimeout(100*time.Millisecond))
+	info, err := a.SMSCommand("+CMGS=6", "00010000000000")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"+CMGS: 42"}, info)
+}
+
+func TestPasted(t *testing.T) {
+	tr := transcript.New(t, "testdata/pasted.txt")
+	a := at.New(tr, at.WithTimeout(100*time.Millisecond))
+	info, err := a.Command("+CGMI")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Quectel"}, info)
+}
+
+func TestUnexpectedWrite(t *testing.T) {
+	patterns := []struct {
+		name string
+		text string
+		cmd  string
+		diff string
+	}{
+		{
+			"mismatch",
+			"> AT\n< OK\n> AT+CGMI\n< Quectel\n< OK\n",
+			"+CGMM",
+			"test:3\n" +
+				"  previous: < OK\\r\\n\n" +
+				"  expected: > AT+CGMI\\r\\n\n" +
+				"  got:      > AT+CGMM\\r\\n",
+		},
+		{
+			"order",
+			"> AT\n< OK\n< +CMTI: \"SM\",1\n> AT+CGMI\n< OK\n",
+			"+CGMI",
+			"test:3\n" +
+				"  previous: < OK\\r\\n\n" +
+				"  expected: < +CMTI: \"SM\",1\\r\\n\n" +
+				"  got:      > AT+CGMI\\r\\n",
+		},
+		{
+			"extra",
+			"> AT\n< OK\n",
+			"+CGMI",
+			"test: after end of transcript\n" +
+				"  previous: < OK\\r\\n\n" +
+				"  expected: nothing\n" +
+				"  got:      > AT+CGMI\\r\\n",
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			tr, err := transcript.Parse("test", strings.NewReader(p.text))
+			require.Nil(t, err)
+			_, err = tr.Write([]byte("AT\r\n"))
+			require.Nil(t, err)
+			b := make([]byte, 10)
+			n, err := tr.Read(b)
+			require.Nil(t, err)
+			assert.Equal(t, "OK\r\n", string(b[:n]))
+			_, err = tr.Write([]byte("AT" + p.cmd + "\r\n"))
+			require.NotNil(t, err)
+			assert.True(t, errors.Is(err, transcript.ErrUnexpectedWrite))
+			assert.Equal(t, "unexpected write: "+p.diff, err.Error())
+			assert.Equal(t, err, tr.Verify())
+			// subsequent writes fail
+			_, err = tr.Write([]byte("AT\r\n"))
+			assert.True(t, errors.Is(err, transcript.ErrUnexpectedWrite))
+			assert.Nil(t, tr.Close())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tr, err := transcript.Parse("test", strings.NewReader("> AT\n< OK\n> AT+CGMI\n< OK\n"))
+	require.Nil(t, err)
+	_, err = tr.Write([]byte("AT\r\n"))
+	require.Nil(t, err)
+	err = tr.Verify()
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, transcript.ErrIncomplete))
+	assert.Equal(t, "incomplete: test: 3 of 4 steps not played:\n"+
+		"  2: < OK\\r\\n\n"+
+		"  3: > AT+CGMI\\r\\n\n"+
+		"  4: < OK\\r\\n", err.Error())
+}
+
+func TestDone(t *testing.T) {
+	tr, err := transcript.Parse("test", strings.NewReader(""))
+	require.Nil(t, err)
+	select {
+	case <-tr.Done():
+	default:
+		t.Error("empty transcript not done")
+	}
+
+	tr, err = transcript.Parse("test", strings.NewReader("> AT\n< OK\n"))
+	require.Nil(t, err)
+	_, err = tr.Write([]byte("AT\r\n"))
+	require.Nil(t, err)
+	select {
+	case <-tr.Done():
+		t.Error("transcript done early")
+	default:
+	}
+	b := make([]byte, 10)
+	_, err = tr.Read(b)
+	require.Nil(t, err)
+	select {
+	case <-tr.Done():
+	default:
+		t.Error("transcript not done")
+	}
+}
+
+func TestClose(t *testing.T) {
+	tr, err := transcript.Parse("test", strings.NewReader("> AT\n< OK\n"))
+	require.Nil(t, err)
+	done := make(chan error)
+	go func() {
+		b := make([]byte, 10)
+		_, err := tr.Read(b)
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Error("read did not block")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Nil(t, tr.Close())
+	select {
+	case err := <-done:
+		assert.Equal(t, io.EOF, err)
+	case <-time.After(100 * time.Millisecond):
+		t.Error("read still blocked")
+	}
+	_, err = tr.Write([]byte("AT\r\n"))
+	assert.Equal(t, transcript.ErrClosed, err)
+}